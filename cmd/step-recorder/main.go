@@ -5,3 +5,26 @@ package main
 // The actual implementations are in:
 // - main_windows.go (for Windows)
 // - main_linux.go (for Linux and other non-Windows platforms)
+
+import (
+	"log"
+
+	"github.com/gustaf/go-test/pkg/config"
+)
+
+// loadSettings reads the settings file and returns the settings with the
+// path they should be saved to. A file that cannot be read gives the
+// defaults and no path, so GoStep still starts and does not overwrite it.
+func loadSettings() (*config.Settings, string) {
+	path, err := config.DefaultPath()
+	if err != nil {
+		log.Printf("Using the default settings: %v", err)
+		return config.DefaultSettings(), ""
+	}
+	settings, err := config.LoadSettings(path)
+	if err != nil {
+		log.Printf("Using the default settings, failed to read %s: %v", path, err)
+		return config.DefaultSettings(), ""
+	}
+	return settings, path
+}
@@ -4,19 +4,216 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/bundle"
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/highlight"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/output"
+	"github.com/gustaf/go-test/pkg/recorder"
 )
 
 func main() {
+	// The flags start from the settings file and override it for this run.
+	settings, _ := loadSettings()
+
+	format := flag.String("format", settings.OutputFormat, "output format: html or pdf")
+	flag.StringVar(&settings.OutputDir, "o", settings.OutputDir, "output directory")
+	saveBundle := flag.Bool("bundle", false, "also save the recording as a "+bundle.Extension+" session that -open can export again")
+	openPath := flag.String("open", "", "export a saved "+bundle.Extension+" session instead of recording")
+	recoverAll := flag.Bool("recover", false, "export the recordings a previous run did not finish instead of recording")
+	flag.BoolVar(&settings.CaptureKeystrokes, "keys", settings.CaptureKeystrokes, "record typed text and shortcuts")
+	flag.StringVar(&settings.InputBackend, "input", settings.InputBackend, "input backend: native (XInput2) or fallback (RECORD)")
+	flag.StringVar(&settings.CaptureScope, "scope", settings.CaptureScope, "capture scope: display, window, region or all")
+	flag.IntVar(&settings.FrameBufferInterval, "buffer", settings.FrameBufferInterval, "capture the screen every this many milliseconds so clicks show the screen from before the press, e.g. 150 (0 disables it)")
+	flag.BoolVar(&settings.CaptureAfterFrame, "after", settings.CaptureAfterFrame, "also capture each step once the screen settles and outline what changed")
+	flag.IntVar(&settings.AfterFrameDelay, "after-delay", settings.AfterFrameDelay, "milliseconds to wait before the -after screenshot")
+	flag.BoolVar(&settings.MergeNoOpSteps, "merge", settings.MergeNoOpSteps, "merge clicks and scrolls that changed nothing on screen into the next step")
+	flag.IntVar(&settings.MergeThreshold, "merge-threshold", settings.MergeThreshold, "how many of the 4096 perceptual hash cells may differ for -merge to treat screenshots as unchanged")
+	flag.BoolVar(&settings.ShowCursor, "cursor", settings.ShowCursor, "draw the mouse pointer into screenshots")
+	flag.StringVar(&settings.HighlightStyle, "highlight", settings.HighlightStyle, "highlight style: "+strings.Join(highlight.Styles(), ", "))
+	flag.Func("region", "capture region for -scope region, as x,y,width,height", func(s string) error {
+		region, err := config.ParseRegion(s)
+		settings.CaptureRegion = region
+		return err
+	})
+	flag.Func("redact-title", "redact windows whose title matches this regular expression (repeatable)", func(s string) error {
+		settings.RedactionRules = append(settings.RedactionRules, config.RedactionRule{WindowTitle: s})
+		return nil
+	})
+	flag.Func("redact-region", "redact this x,y,width,height desktop region in every step (repeatable)", func(s string) error {
+		region, err := config.ParseRegion(s)
+		if err != nil {
+			return err
+		}
+		settings.RedactionRules = append(settings.RedactionRules, config.RedactionRule{Region: &region})
+		return nil
+	})
+	flag.Parse()
+
 	fmt.Println("GoStep")
 	fmt.Println("----------------")
-	fmt.Println("This application is designed for Windows and requires Windows-specific libraries.")
-	fmt.Println("The Linux version is provided only for build compatibility and CI/CD purposes.")
-	fmt.Println("")
-	fmt.Println("To use this application, please build and run it on a Windows system.")
+
+	if *openPath != "" {
+		session, err := recorder.NewSession(settings)
+		if err != nil {
+			fmt.Printf("Failed to open session: %v\n", err)
+			os.Exit(1)
+		}
+		recording, err := bundle.Load(*openPath, session)
+		if err == nil {
+			err = export(recording, time.Now(), *format, settings.OutputDir, false)
+		}
+		session.Remove()
+		if err != nil {
+			fmt.Printf("Failed to export %s: %v\n", *openPath, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	unfinished, err := recorder.FindUnfinished(settings)
+	if err != nil {
+		log.Printf("Failed to look for unfinished recordings: %v", err)
+	}
+	if *recoverAll {
+		// Recovered reports are named after when their recording started.
+		for _, u := range unfinished {
+			if err := export(u.Recording, u.Recording.Started, *format, settings.OutputDir, *saveBundle); err != nil {
+				fmt.Printf("Failed to export %s: %v\n", u.Session.Dir(), err)
+				os.Exit(1)
+			}
+			u.Session.Remove()
+		}
+		if len(unfinished) == 0 {
+			fmt.Println("No unfinished recordings.")
+		}
+		return
+	}
+	if len(unfinished) > 0 {
+		fmt.Printf("Found %d unfinished recordings from an earlier run; run with -recover to export them.\n", len(unfinished))
+	}
+
+	rec := recorder.NewRecorder(settings)
+	if err := rec.Start(); err != nil {
+		fmt.Printf("Failed to start recording: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Recording... Click anywhere to capture. Press Ctrl+C to stop.")
+
+	stopChan := make(chan struct{}, 1)
+	err = rec.ListenHotkeys(func(action recorder.HotkeyAction) {
+		switch action {
+		case recorder.HotkeyStartStop:
+			select {
+			case stopChan <- struct{}{}:
+			default:
+			}
+		case recorder.HotkeyPauseResume:
+			if rec.IsPaused() {
+				fmt.Println("Paused.")
+			} else {
+				fmt.Println("Resumed.")
+			}
+		case recorder.HotkeyCaptureStep:
+			fmt.Println("Step captured.")
+		}
+	})
+	if err != nil {
+		log.Printf("Global hotkeys unavailable: %v", err)
+	} else {
+		printHotkeys(settings)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-sigChan:
+	case <-stopChan:
+	}
 	fmt.Println("")
-	fmt.Println("For more information, see the README.md file.")
+	rec.StopHotkeys()
+
+	if err := rec.Stop(); err != nil {
+		fmt.Printf("Failed to stop recording: %v\n", err)
+		os.Exit(1)
+	}
+
+	defer rec.Session().Remove()
+
+	if missed := rec.Stats().String(); missed != "" {
+		fmt.Printf("Warning: some input could not be captured as it happened: %s\n", missed)
+	}
+
+	if merged := rec.Merged(); merged > 0 {
+		fmt.Printf("Merged %d steps that changed nothing on screen\n", merged)
+	}
+
+	recording := rec.Recording()
+	if len(recording.Steps) == 0 {
+		fmt.Println("No steps recorded.")
+		return
+	}
+
+	if err := export(recording, time.Now(), *format, settings.OutputDir, *saveBundle); err != nil {
+		fmt.Printf("Failed to save recording: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// export writes recording to a new report in dir, named after at, and to
+// a bundle with the same name when withBundle is set.
+func export(recording *model.Recording, at time.Time, format, dir string, withBundle bool) error {
+	ext := strings.ToLower(format)
+	name := fmt.Sprintf("recording_%s", at.Format("2006-01-02_150405"))
+	outputPath := filepath.Join(dir, name+"."+ext)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var err error
+	switch ext {
+	case "html":
+		err = output.SaveHTML(recording, outputPath)
+	case "pdf":
+		err = output.SavePDF(recording, outputPath)
+	default:
+		err = fmt.Errorf("unsupported output format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved %d steps to %s\n", len(recording.Steps), outputPath)
+
+	if withBundle {
+		bundlePath := filepath.Join(dir, name+bundle.Extension)
+		if err := bundle.Save(bundlePath, recording); err != nil {
+			return err
+		}
+		fmt.Printf("Saved the session to %s\n", bundlePath)
+	}
+	return nil
+}
 
-	os.Exit(0)
+func printHotkeys(settings *config.Settings) {
+	hotkeys := []struct{ label, key string }{
+		{"stop", settings.HotkeyStartStop},
+		{"pause/resume", settings.HotkeyPauseResume},
+		{"capture a step", settings.HotkeyCaptureStep},
+	}
+	for _, h := range hotkeys {
+		if h.key != "" {
+			fmt.Printf("  %-15s %s\n", h.label, h.key)
+		}
+	}
 }
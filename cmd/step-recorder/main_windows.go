@@ -9,8 +9,8 @@ import (
 	"os"
 	"runtime"
 
-	"github.com/gustaf/go-test/pkg/config"
 	"github.com/gustaf/go-test/pkg/gui"
+	"github.com/gustaf/go-test/pkg/recorder"
 )
 
 func init() {
@@ -19,6 +19,10 @@ func init() {
 }
 
 func main() {
+	// Screen positions must be in physical pixels before the GUI toolkit
+	// creates its first window.
+	recorder.SetDPIAware()
+
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Recovered from panic: %v", r)
@@ -28,7 +32,8 @@ func main() {
 	}()
 
 	// Create and show the main window
-	if err := gui.NewRecorderWindow(config.DefaultSettings()); err != nil {
+	settings, settingsPath := loadSettings()
+	if err := gui.NewRecorderWindow(settings, settingsPath); err != nil {
 		log.Printf("Failed to create main window: %v", err)
 		fmt.Printf("Failed to create application window: %v\n", err)
 		os.Exit(1)
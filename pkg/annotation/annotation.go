@@ -0,0 +1,148 @@
+// Package annotation holds the vector marks placed over a step's
+// screenshot. They are kept apart from the screenshot so they can be
+// moved, restyled or removed while editing, and are only drawn into the
+// pixels by Render when a step is exported.
+package annotation
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/gustaf/go-test/pkg/highlight"
+)
+
+// Kind identifies what an annotation draws.
+type Kind string
+
+const (
+	// KindClick marks a click with a highlight style.
+	KindClick Kind = "click"
+	// KindArrow points from From to At.
+	KindArrow Kind = "arrow"
+	// KindRect outlines Rect.
+	KindRect Kind = "rect"
+	// KindCallout shows Text in a box at From, with a line to At.
+	KindCallout Kind = "callout"
+	// KindBadge shows Text, usually a number, in a disc centred on At.
+	KindBadge Kind = "badge"
+)
+
+// Annotation is one mark on a screenshot. Positions are in screenshot
+// pixels. The embedded options give its colour, size, stroke, opacity and
+// the scale of the display it was recorded on.
+type Annotation struct {
+	Kind Kind `json:"kind"`
+	// At is the point the annotation marks: the click position, the tip
+	// of an arrow, the point a callout refers to or the centre of a badge.
+	At image.Point `json:"at"`
+	// From is the tail of an arrow and the top left corner of a callout.
+	From image.Point `json:"from"`
+	// Rect is the rectangle of a KindRect annotation. On click markers it
+	// is the window the click went to, which the window style outlines.
+	Rect image.Rectangle `json:"rect"`
+	// Text is the text of a callout or the label of a badge.
+	Text string `json:"text,omitempty"`
+	// Style is the highlight style of a click marker.
+	Style string `json:"style,omitempty"`
+	highlight.Options
+}
+
+// Click returns a click marker at at, drawn in style.
+func Click(at image.Point, window image.Rectangle, style string, opts highlight.Options) Annotation {
+	return Annotation{Kind: KindClick, At: at, Rect: window, Style: style, Options: opts}
+}
+
+// Arrow returns an arrow pointing from from to to.
+func Arrow(from, to image.Point, opts highlight.Options) Annotation {
+	return Annotation{Kind: KindArrow, From: from, At: to, Options: opts}
+}
+
+// Moved returns the annotation shifted by d.
+func (a Annotation) Moved(d image.Point) Annotation {
+	a.At = a.At.Add(d)
+	a.From = a.From.Add(d)
+	if a.Kind == KindRect {
+		a.Rect = a.Rect.Add(d)
+	}
+	return a
+}
+
+// Bounds returns the area the annotation covers, roughly; it is meant for
+// picking annotations under the pointer.
+func (a Annotation) Bounds() image.Rectangle {
+	reach := int(a.scaled(max(a.Size, a.Stroke)))
+	point := func(p image.Point) image.Rectangle {
+		return image.Rect(p.X-reach, p.Y-reach, p.X+reach, p.Y+reach)
+	}
+
+	switch a.Kind {
+	case KindArrow:
+		return point(a.At).Union(point(a.From))
+	case KindRect:
+		return a.Rect.Canon()
+	case KindCallout:
+		return a.calloutBox().Union(point(a.At))
+	}
+	return point(a.At)
+}
+
+// String describes the annotation for lists in the editor.
+func (a Annotation) String() string {
+	switch a.Kind {
+	case KindClick:
+		return fmt.Sprintf("Click marker at (%d, %d)", a.At.X, a.At.Y)
+	case KindArrow:
+		return fmt.Sprintf("Arrow to (%d, %d)", a.At.X, a.At.Y)
+	case KindRect:
+		return fmt.Sprintf("Rectangle %dx%d at (%d, %d)", a.Rect.Dx(), a.Rect.Dy(), a.Rect.Min.X, a.Rect.Min.Y)
+	case KindCallout:
+		return fmt.Sprintf("Callout %q", a.Text)
+	case KindBadge:
+		return fmt.Sprintf("Badge %s", a.Text)
+	}
+	return string(a.Kind)
+}
+
+// Render returns a copy of img with the annotations drawn on it, in order.
+// img itself is left untouched.
+func Render(img image.Image, annotations []Annotation) (image.Image, error) {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	for _, a := range annotations {
+		if err := a.draw(dst); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func (a Annotation) draw(dst draw.Image) error {
+	switch a.Kind {
+	case KindClick:
+		r, err := highlight.Lookup(a.Style)
+		if err != nil {
+			return err
+		}
+		r.Render(dst, highlight.Target{Points: []image.Point{a.At}, Window: a.Rect}, a.Options)
+	case KindArrow:
+		highlight.DrawArrow(dst, a.From, a.At, a.Options)
+	case KindRect:
+		highlight.DrawFrame(dst, a.Rect, a.Options)
+	case KindCallout:
+		a.drawCallout(dst)
+	case KindBadge:
+		a.drawBadge(dst)
+	default:
+		return fmt.Errorf("unknown annotation kind %q", a.Kind)
+	}
+	return nil
+}
+
+// scaled returns a logical length in physical pixels.
+func (a Annotation) scaled(v int) float64 {
+	if a.Scale <= 0 {
+		return float64(v)
+	}
+	return float64(v) * a.Scale
+}
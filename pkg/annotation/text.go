@@ -0,0 +1,142 @@
+package annotation
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/gustaf/go-test/pkg/highlight"
+)
+
+const (
+	// calloutTextSize and calloutPadding are in logical pixels.
+	calloutTextSize = 14
+	calloutPadding  = 6
+)
+
+var (
+	regular = mustParse(goregular.TTF)
+	bold    = mustParse(gobold.TTF)
+
+	facesMu sync.Mutex
+	faces   = map[faceKey]font.Face{}
+)
+
+type faceKey struct {
+	font *opentype.Font
+	size float64
+}
+
+func mustParse(ttf []byte) *opentype.Font {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// face returns f at size pixels. Faces are cached, since annotations are
+// drawn at a handful of sizes.
+func face(f *opentype.Font, size float64) font.Face {
+	size = math.Round(size)
+	facesMu.Lock()
+	defer facesMu.Unlock()
+
+	key := faceKey{f, size}
+	if fc, ok := faces[key]; ok {
+		return fc
+	}
+	fc, err := opentype.NewFace(f, &opentype.FaceOptions{Size: max(size, 1), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	faces[key] = fc
+	return fc
+}
+
+// calloutLayout returns the callout text split into lines, its face and the
+// size of its box.
+func (a Annotation) calloutLayout() ([]string, font.Face, image.Point) {
+	fc := face(regular, a.scaled(calloutTextSize))
+	lines := strings.Split(a.Text, "\n")
+	pad := int(a.scaled(calloutPadding))
+	lineHeight := fc.Metrics().Height.Ceil()
+
+	width := 0
+	for _, line := range lines {
+		width = max(width, font.MeasureString(fc, line).Ceil())
+	}
+	return lines, fc, image.Pt(width+2*pad, len(lines)*lineHeight+2*pad)
+}
+
+func (a Annotation) calloutBox() image.Rectangle {
+	_, _, size := a.calloutLayout()
+	return image.Rectangle{Min: a.From, Max: a.From.Add(size)}
+}
+
+// drawCallout draws a line from the nearest point of the box to At, then
+// the box and its text over it.
+func (a Annotation) drawCallout(dst draw.Image) {
+	lines, fc, size := a.calloutLayout()
+	box := image.Rectangle{Min: a.From, Max: a.From.Add(size)}
+
+	if !a.At.In(box) {
+		edge := image.Pt(
+			min(max(a.At.X, box.Min.X), box.Max.X-1),
+			min(max(a.At.Y, box.Min.Y), box.Max.Y-1),
+		)
+		highlight.DrawLine(dst, edge, a.At, a.Options)
+		dot := a.Options
+		dot.Size = max(dot.Stroke*2, 1)
+		highlight.DrawDisc(dst, a.At, dot)
+	}
+	highlight.FillRect(dst, box, a.Options)
+
+	pad := int(a.scaled(calloutPadding))
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(contrast(a.Color)), Face: fc}
+	for i, line := range lines {
+		d.Dot = fixed.P(box.Min.X+pad, box.Min.Y+pad+i*fc.Metrics().Height.Ceil()+fc.Metrics().Ascent.Ceil())
+		d.DrawString(line)
+	}
+}
+
+// drawBadge draws Text centred in a disc of radius Size around At.
+func (a Annotation) drawBadge(dst draw.Image) {
+	highlight.DrawDisc(dst, a.At, a.Options)
+
+	fc := face(bold, a.scaled(a.Size))
+	if a.Text == "" {
+		return
+	}
+	width := font.MeasureString(fc, a.Text)
+	m := fc.Metrics()
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(contrast(a.Color)),
+		Face: fc,
+		Dot: fixed.Point26_6{
+			X: fixed.I(a.At.X) - width/2,
+			Y: fixed.I(a.At.Y) + (m.Ascent-m.Descent)/2,
+		},
+	}
+	d.DrawString(a.Text)
+}
+
+// contrast returns black or white, whichever reads better on c.
+func contrast(c color.NRGBA) color.Color {
+	luma := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+	if luma > 150 {
+		return color.Black
+	}
+	return color.White
+}
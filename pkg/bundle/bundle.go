@@ -0,0 +1,176 @@
+// Package bundle saves a recording as a .gostep file and opens it again,
+// so a session can be edited and exported after the preview is closed.
+//
+// A bundle is a zip archive holding manifest.json, the recording encoded
+// by model.MarshalRecording, and the screenshots it names as they were
+// captured, before anything was drawn over them.
+package bundle
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// Extension is the file extension of session bundles.
+const Extension = ".gostep"
+
+// manifestName is the name of the manifest inside the archive.
+const manifestName = "manifest.json"
+
+// Save writes rec to a bundle at path, replacing any file there. The
+// bundle is written to a temporary file first, so a failed save leaves an
+// existing bundle intact.
+func Save(path string, rec *model.Recording) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	tmp := f.Name()
+
+	if err := write(f, rec); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save bundle: %w", err)
+	}
+	return nil
+}
+
+func write(w io.Writer, rec *model.Recording) error {
+	zw := zip.NewWriter(w)
+
+	names := make(map[*store.Image]string)
+	add := func(img *store.Image, name string) error {
+		names[img] = name
+		return addImage(zw, name, img)
+	}
+	for i, step := range rec.Steps {
+		if step.Screenshot == nil {
+			return fmt.Errorf("step %d has no screenshot", i+1)
+		}
+		prefix := fmt.Sprintf("steps/%04d", i+1)
+		if err := add(step.Screenshot, prefix+".png"); err != nil {
+			return err
+		}
+		if step.AfterScreenshot != nil {
+			if err := add(step.AfterScreenshot, prefix+"_after.png"); err != nil {
+				return err
+			}
+		}
+	}
+
+	manifest, err := model.MarshalRecording(rec, func(img *store.Image) string { return names[img] })
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	mw, err := zw.Create(manifestName)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if _, err := mw.Write(manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// addImage copies a stored screenshot into the archive. PNG data is
+// already compressed, so it is stored rather than deflated again.
+func addImage(zw *zip.Writer, name string, img *store.Image) error {
+	src, err := img.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return nil
+}
+
+// Load opens the bundle at path. Its screenshots are copied into session,
+// which then holds them the same way it holds those of a new recording.
+func Load(path string, session *store.Session) (*model.Recording, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	data, err := readFile(files, manifestName)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := model.UnmarshalRecording(data, func(name string) (*store.Image, error) {
+		return readImage(files, name, session)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", manifestName, err)
+	}
+	return rec, nil
+}
+
+func open(files map[string]*zip.File, name string) (io.ReadCloser, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("bundle is missing %s", name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return r, nil
+}
+
+func readFile(files map[string]*zip.File, name string) ([]byte, error) {
+	r, err := open(files, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func readImage(files map[string]*zip.File, name string, session *store.Session) (*store.Image, error) {
+	r, err := open(files, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return session.PutPNG(r)
+}
@@ -0,0 +1,213 @@
+package bundle
+
+import (
+	"archive/zip"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/highlight"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+func newSession(t *testing.T) *store.Session {
+	t.Helper()
+	session, err := store.NewSession(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// shot stores a w by h screenshot whose pixels depend on their position,
+// so a copy can be told from a different picture of the same size.
+func shot(t *testing.T, session *store.Session, w, h int) *store.Image {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(w), A: 255})
+		}
+	}
+	stored, err := session.Put(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func samePixels(t *testing.T, got, want *store.Image) bool {
+	t.Helper()
+	a, err := got.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := want.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if a.At(x, y) != b.At(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestSaveLoad(t *testing.T) {
+	session := newSession(t)
+	started := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	opts := highlight.Options{Color: color.NRGBA{R: 255, A: 255}, Size: 20, Stroke: 3, Opacity: 0.9, Scale: 1.5}
+	rec := &model.Recording{
+		Started: started,
+		Ended:   started.Add(time.Minute),
+		Capture: model.Capture{Scope: "display", Cursor: true, HighlightStyle: "circle", FrameBuffer: 150 * time.Millisecond, MergeThreshold: 3},
+		Steps: []model.Step{
+			{
+				Screenshot:  shot(t, session, 40, 30),
+				Description: "Click OK",
+				Timestamp:   started.Add(time.Second),
+				Action:      "Mouse Click",
+				Coordinates: image.Pt(12, 8),
+				Scale:       1.5,
+				Highlighted: true,
+				Window:      model.Window{Title: "Dialog", Process: "app.exe", PID: 42, Bounds: image.Rect(0, 0, 40, 30)},
+				CaptureArea: image.Rect(0, 0, 40, 30),
+				Annotations: []annotation.Annotation{annotation.Click(image.Pt(12, 8), image.Rect(0, 0, 40, 30), "circle", opts)},
+				Redactions:  []redact.Redaction{{Rect: image.Rect(1, 1, 5, 5), Method: redact.Fill}},
+			},
+			{
+				Screenshot:      shot(t, session, 20, 10),
+				AfterScreenshot: shot(t, session, 20, 11),
+				Description:     "Scroll",
+				Timestamp:       started.Add(2 * time.Second),
+				Action:          "Scroll down 1 notch",
+				Paused:          3 * time.Second,
+				Changes:         []image.Rectangle{image.Rect(0, 0, 20, 5)},
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "session"+Extension)
+	if err := Save(path, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Load(path, newSession(t))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(got.Steps) != len(rec.Steps) {
+		t.Fatalf("loaded %d steps, want %d", len(got.Steps), len(rec.Steps))
+	}
+	for i, step := range got.Steps {
+		want := rec.Steps[i]
+		if !samePixels(t, step.Screenshot, want.Screenshot) {
+			t.Errorf("step %d: screenshot differs", i+1)
+		}
+		if (step.AfterScreenshot == nil) != (want.AfterScreenshot == nil) ||
+			step.AfterScreenshot != nil && !samePixels(t, step.AfterScreenshot, want.AfterScreenshot) {
+			t.Errorf("step %d: after screenshot differs", i+1)
+		}
+		step.Screenshot, step.AfterScreenshot = nil, nil
+		want.Screenshot, want.AfterScreenshot = nil, nil
+		if !reflect.DeepEqual(step, want) {
+			t.Errorf("step %d = %+v, want %+v", i+1, step, want)
+		}
+	}
+	got.Steps, rec.Steps = nil, nil
+	if !reflect.DeepEqual(got, rec) {
+		t.Errorf("recording = %+v, want %+v", got, rec)
+	}
+}
+
+func TestSaveReplaces(t *testing.T) {
+	session := newSession(t)
+	path := filepath.Join(t.TempDir(), "session"+Extension)
+	for _, description := range []string{"first", "second"} {
+		rec := &model.Recording{Steps: []model.Step{{Screenshot: shot(t, session, 4, 4), Description: description}}}
+		if err := Save(path, rec); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	got, err := Load(path, newSession(t))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got.Steps) != 1 || got.Steps[0].Description != "second" {
+		t.Errorf("steps = %+v, want the second save", got.Steps)
+	}
+	if tmp, _ := filepath.Glob(path + ".*.tmp"); len(tmp) > 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestSaveNoScreenshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session"+Extension)
+	if err := Save(path, &model.Recording{Steps: []model.Step{{Description: "a"}}}); err == nil {
+		t.Fatal("Save succeeded without a screenshot")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("failed save left %s: %v", path, err)
+	}
+}
+
+// writeZip writes an archive holding files, by name, to a new bundle.
+func writeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bad"+Extension)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"no manifest", map[string]string{}, "missing manifest.json"},
+		{"not JSON", map[string]string{manifestName: "nope"}, "failed to read manifest.json"},
+		{"no version", map[string]string{manifestName: `{"steps":[]}`}, "not a GoStep recording"},
+		{"newer version", map[string]string{manifestName: `{"version":99,"steps":[]}`}, "newer GoStep"},
+		{"missing screenshot", map[string]string{manifestName: `{"version":1,"steps":[{"screenshot":"steps/0001.png"}]}`}, "missing steps/0001.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeZip(t, tt.files), newSession(t))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,26 +2,195 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
+// Input backends for Settings.InputBackend.
+const (
+	// InputNative reads input through OS event hooks: low-level mouse and
+	// keyboard hooks on Windows, XInput2 raw events on X11.
+	InputNative = "native"
+	// InputFallback polls GetAsyncKeyState on Windows and uses the RECORD
+	// extension on X11.
+	InputFallback = "fallback"
+)
+
+// Capture scopes for Settings.CaptureScope.
+const (
+	// ScopeDisplay captures the whole display the event happened on.
+	ScopeDisplay = "display"
+	// ScopeWindow captures only the active window.
+	ScopeWindow = "window"
+	// ScopeRegion captures the fixed CaptureRegion.
+	ScopeRegion = "region"
+	// ScopeAllDisplays captures every display, stitched into one image
+	// that keeps their layout on the virtual desktop.
+	ScopeAllDisplays = "all"
+)
+
+// FrameBufferInterval is the interval the frame buffer runs at when it is
+// turned on. It is off by default: it captures the whole virtual desktop
+// several times a second, even while nothing happens.
+const FrameBufferInterval = 150
+
+// How GoStep's own windows appear in screenshots, for Settings.OwnWindows.
+const (
+	// OwnWindowsShow captures them like any other window.
+	OwnWindowsShow = "show"
+	// OwnWindowsMask fills them with a solid colour.
+	OwnWindowsMask = "mask"
+	// OwnWindowsHide keeps them out of the capture, so the windows behind
+	// them show. Only Windows 10 2004 and later support this; elsewhere
+	// they are masked.
+	OwnWindowsHide = "hide"
+)
+
+// Region is a rectangle on the virtual desktop, which spans all displays
+// and may have a negative origin.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// RedactionRule hides part of every matching screenshot. Exactly one of
+// WindowTitle and Region is set.
+type RedactionRule struct {
+	// WindowTitle is a regular expression. Every window on screen with a
+	// matching title is redacted, whether it is active or not.
+	WindowTitle string `json:"window_title,omitempty"`
+	// Region is a fixed rectangle on the virtual desktop that is redacted
+	// in every step.
+	Region *Region `json:"region,omitempty"`
+	// Method is "pixelate" (the default) or "fill".
+	Method string `json:"method,omitempty"`
+}
+
 type Settings struct {
 	OutputFormat     string `json:"output_format"` // "html" or "pdf"
 	OutputDir        string `json:"output_dir"`
 	MainWindowWidth  int    `json:"main_window_width"`
 	MainWindowHeight int    `json:"main_window_height"`
+
+	// CaptureKeystrokes turns typed text and shortcuts into steps. Off by
+	// default; only pointer gestures are recorded otherwise.
+	//
+	// Typing into password fields is masked. On Windows they are found
+	// through UI Automation, which browsers, WPF and UWP applications
+	// support; where it is unavailable, typing is only recorded in standard
+	// edit controls. X11 has no notion of a password field, so on Linux
+	// only KeystrokeDenyList protects them.
+	CaptureKeystrokes bool `json:"capture_keystrokes"`
+	// KeystrokeDenyList holds regular expressions matched against the
+	// focused window title. Typing into a matching window is masked.
+	KeystrokeDenyList []string `json:"keystroke_deny_list"`
+
+	// Global hotkeys, written like "Ctrl+Alt+R". An empty value leaves
+	// the action unbound.
+	HotkeyStartStop   string `json:"hotkey_start_stop"`
+	HotkeyPauseResume string `json:"hotkey_pause_resume"`
+	HotkeyCaptureStep string `json:"hotkey_capture_step"`
+
+	// InputBackend is InputNative or InputFallback. The backend is chosen
+	// when the recorder is created.
+	InputBackend string `json:"input_backend"`
+
+	// CaptureScope is ScopeDisplay, ScopeWindow, ScopeRegion or
+	// ScopeAllDisplays. CaptureRegion is the region ScopeRegion captures.
+	// Steps whose window or region cannot be captured fall back to the
+	// display.
+	CaptureScope  string `json:"capture_scope"`
+	CaptureRegion Region `json:"capture_region"`
+
+	// HighlightStyle names the highlight drawn on clicked steps: circle,
+	// halo, arrow, window or spotlight. HighlightColor is "#rrggbb" or
+	// "#rrggbbaa". Size and stroke are in logical pixels and grow with the
+	// display scale; opacity runs from 0 to 1.
+	HighlightStyle   string  `json:"highlight_style"`
+	HighlightColor   string  `json:"highlight_color"`
+	HighlightSize    int     `json:"highlight_size"`
+	HighlightStroke  int     `json:"highlight_stroke"`
+	HighlightOpacity float64 `json:"highlight_opacity"`
+
+	// ShowCursor draws the mouse pointer into screenshots, as a layer of
+	// its own under the highlight. Screen capture leaves it out otherwise.
+	ShowCursor bool `json:"show_cursor"`
+
+	// RedactionRules are applied to every step as it is recorded.
+	RedactionRules []RedactionRule `json:"redaction_rules"`
+
+	// OwnWindows is OwnWindowsShow, OwnWindowsMask or OwnWindowsHide.
+	// Clicks and scrolling on GoStep's own windows are never recorded.
+	OwnWindows string `json:"own_windows"`
+
+	// FrameBufferInterval is how often, in milliseconds, the screen is
+	// captured into a short buffer so that clicks are shown as the screen
+	// was just before the button went down. 0, the default, disables the
+	// buffer and captures once the button is down.
+	FrameBufferInterval int `json:"frame_buffer_interval_ms"`
+	// CaptureAfterFrame also stores a screenshot of each step taken
+	// AfterFrameDelay milliseconds later, once the screen has settled, and
+	// outlines what changed between the two in reports.
+	CaptureAfterFrame bool `json:"capture_after_frame"`
+	AfterFrameDelay   int  `json:"after_frame_delay_ms"`
+
+	// MergeNoOpSteps drops clicks and scrolls whose screenshot matches the
+	// next step's, in the same window, when the recording stops.
+	// MergeThreshold is how many cells of the 64x64 perceptual hash may
+	// differ for screenshots to still match; raise it to merge steps that
+	// changed a little.
+	MergeNoOpSteps bool `json:"merge_no_op_steps"`
+	MergeThreshold int  `json:"merge_threshold"`
 }
 
 func DefaultSettings() *Settings {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("USERPROFILE")
+	}
+
 	return &Settings{
 		OutputFormat:     "html",
-		OutputDir:        filepath.Join(os.Getenv("USERPROFILE"), "Documents", "GoStep"),
+		OutputDir:        filepath.Join(home, "Documents", "GoStep"),
 		MainWindowWidth:  800,
 		MainWindowHeight: 600,
+		KeystrokeDenyList: []string{
+			`(?i)password|passwort|mot de passe`,
+			`(?i)sign[ -]?in|log[ -]?in|credential`,
+			`(?i)keepass|1password|bitwarden|lastpass`,
+		},
+		HotkeyStartStop:   "Ctrl+Alt+R",
+		HotkeyPauseResume: "Ctrl+Alt+P",
+		HotkeyCaptureStep: "Ctrl+Alt+S",
+		InputBackend:      InputNative,
+		CaptureScope:      ScopeDisplay,
+		HighlightStyle:    "circle",
+		HighlightColor:    "#ff0000",
+		HighlightSize:     20,
+		HighlightStroke:   3,
+		HighlightOpacity:  0.9,
+		OwnWindows:        OwnWindowsMask,
+		AfterFrameDelay:   500,
+	}
+}
+
+// DefaultPath returns where the settings file is kept: settings.json in
+// a GoStep directory under the user's configuration directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, "GoStep", "settings.json"), nil
 }
 
+// LoadSettings reads the settings file at configPath. A missing file gives
+// the default settings.
 func LoadSettings(configPath string) (*Settings, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
@@ -31,12 +200,14 @@ func LoadSettings(configPath string) (*Settings, error) {
 		return nil, err
 	}
 
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
+	// Start from the defaults so fields missing from older files keep
+	// sensible values.
+	settings := DefaultSettings()
+	if err := json.Unmarshal(data, settings); err != nil {
 		return nil, err
 	}
 
-	return &settings, nil
+	return settings, nil
 }
 
 func (s *Settings) SaveSettings(configPath string) error {
@@ -52,3 +223,28 @@ func (s *Settings) SaveSettings(configPath string) error {
 
 	return os.WriteFile(configPath, data, 0644)
 }
+
+// ParseRegion parses a region written as "x,y,width,height".
+func ParseRegion(s string) (Region, error) {
+	var values [4]int
+	parts := strings.Split(s, ",")
+	if len(parts) != len(values) {
+		return Region{}, fmt.Errorf("region %q is not x,y,width,height", s)
+	}
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return Region{}, fmt.Errorf("region %q is not x,y,width,height", s)
+		}
+		values[i] = v
+	}
+	if values[2] <= 0 || values[3] <= 0 {
+		return Region{}, fmt.Errorf("region %q has no area", s)
+	}
+	return Region{X: values[0], Y: values[1], Width: values[2], Height: values[3]}, nil
+}
+
+// String formats the region the way ParseRegion reads it.
+func (r Region) String() string {
+	return fmt.Sprintf("%d,%d,%d,%d", r.X, r.Y, r.Width, r.Height)
+}
@@ -0,0 +1,126 @@
+// Package cursor draws the mouse pointer into screenshots, which screen
+// capture leaves out. The pointer is kept as its own layer on a step, so
+// it can be removed without touching the click highlight.
+package cursor
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Shape is a pointer image. Hotspot is the pixel of Image that points at
+// the pointer position.
+type Shape struct {
+	Image   *image.RGBA `json:"image"`
+	Hotspot image.Point `json:"hotspot"`
+}
+
+// Layer is a pointer drawn over a screenshot, with the hotspot of its
+// shape at At, in screenshot pixels.
+type Layer struct {
+	Shape
+	At image.Point `json:"at"`
+}
+
+// arrowOutline is the classic arrow pointer in 96 DPI pixels, with its tip,
+// the hotspot, at the origin.
+var arrowOutline = []point{
+	{0, 0}, {0, 17}, {4.2, 13.1}, {7, 19.5}, {9.4, 18.5}, {6.7, 12.3}, {12, 12.3},
+}
+
+// arrowBorder is the width of the black border around the white arrow, in
+// 96 DPI pixels.
+const arrowBorder = 1.2
+
+// Arrow returns the bundled arrow pointer for a display with the given
+// ratio of physical to 96 DPI pixels. It is used where the platform cannot
+// report the pointer's real shape.
+func Arrow(scale float64) Shape {
+	if scale <= 0 {
+		scale = 1
+	}
+	outline := make([]point, len(arrowOutline))
+	var size point
+	for i, p := range arrowOutline {
+		outline[i] = point{p.x * scale, p.y * scale}
+		size.x, size.y = math.Max(size.x, outline[i].x), math.Max(size.y, outline[i].y)
+	}
+	border := arrowBorder * scale
+
+	// Leave room for the border around the outline, which is centred on
+	// it, and for antialiasing.
+	pad := int(math.Ceil(border)) + 1
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(size.x))+2*pad, int(math.Ceil(size.y))+2*pad))
+	const samples = 4
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			var black, white int
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					p := point{
+						float64(x-pad) + (float64(sx)+0.5)/samples,
+						float64(y-pad) + (float64(sy)+0.5)/samples,
+					}
+					d := distance(p, outline)
+					switch {
+					case inside(p, outline) && d > border/2:
+						white++
+					case d <= border/2:
+						black++
+					}
+				}
+			}
+			n := samples * samples
+			alpha := uint8((black + white) * 255 / n)
+			grey := uint8(white * 255 / n)
+			img.SetRGBA(x, y, color.RGBA{R: grey, G: grey, B: grey, A: alpha})
+		}
+	}
+	return Shape{Image: img, Hotspot: image.Pt(pad, pad)}
+}
+
+// Bounds returns the area of the screenshot the pointer covers.
+func (l Layer) Bounds() image.Rectangle {
+	return l.Image.Bounds().Sub(l.Image.Bounds().Min).Add(l.At.Sub(l.Hotspot))
+}
+
+// Render returns a copy of img with the pointer of l drawn on it, or img
+// itself if l is nil.
+func Render(img image.Image, l *Layer) image.Image {
+	if l == nil || l.Image == nil {
+		return img
+	}
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	draw.Draw(dst, l.Bounds().Add(img.Bounds().Min), l.Image, l.Image.Bounds().Min, draw.Over)
+	return dst
+}
+
+type point struct{ x, y float64 }
+
+// inside reports whether p is inside the polygon, by the even-odd rule.
+func inside(p point, polygon []point) bool {
+	in := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+	return in
+}
+
+// distance returns how far p is from the nearest edge of the polygon.
+func distance(p point, polygon []point) float64 {
+	nearest := math.Inf(1)
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[j], polygon[i]
+		dx, dy := b.x-a.x, b.y-a.y
+		t := ((p.x-a.x)*dx + (p.y-a.y)*dy) / (dx*dx + dy*dy)
+		t = math.Max(0, math.Min(1, t))
+		nearest = math.Min(nearest, math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy)))
+	}
+	return nearest
+}
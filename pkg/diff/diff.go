@@ -0,0 +1,131 @@
+// Package diff compares screenshots: it finds the parts of a screen that
+// changed between two screenshots of the same area, and hashes screenshots
+// so that near-identical ones can be recognised.
+package diff
+
+import (
+	"image"
+	"image/draw"
+	"sort"
+)
+
+const (
+	// cellSize is the side of the square cells, in pixels, that changes
+	// are tracked in. A changed cell is outlined as a whole.
+	cellSize = 8
+	// tolerance is how far a colour channel may move, out of 255, before
+	// the pixel counts as changed. It absorbs dithering and subpixel
+	// rendering differences.
+	tolerance = 16
+	// joinCells is how many unchanged cells may separate changed cells
+	// that still belong to the same region, so that a redrawn line of
+	// text is one region rather than one per letter.
+	joinCells = 2
+)
+
+// Regions returns the bounding boxes of the areas that differ between
+// before and after, in pixels relative to the top left corner of after.
+// Nearby changes are joined into one box and overlapping boxes are
+// merged. Images of different sizes are treated as changed everywhere.
+func Regions(before, after image.Image) []image.Rectangle {
+	size := after.Bounds().Size()
+	whole := image.Rectangle{Max: size}
+	if before.Bounds().Size() != size {
+		return []image.Rectangle{whole}
+	}
+
+	a, b := toRGBA(before), toRGBA(after)
+	cols := (size.X + cellSize - 1) / cellSize
+	rows := (size.Y + cellSize - 1) / cellSize
+	changed := make([]bool, cols*rows)
+	for y := 0; y < size.Y; y++ {
+		pa := a.Pix[y*a.Stride : y*a.Stride+size.X*4]
+		pb := b.Pix[y*b.Stride : y*b.Stride+size.X*4]
+		for x := 0; x < size.X; x++ {
+			cell := (y/cellSize)*cols + x/cellSize
+			if changed[cell] {
+				// Skip to the next cell on this row.
+				x = (x/cellSize+1)*cellSize - 1
+				continue
+			}
+			i := x * 4
+			if differs(pa[i], pb[i]) || differs(pa[i+1], pb[i+1]) || differs(pa[i+2], pb[i+2]) {
+				changed[cell] = true
+			}
+		}
+	}
+
+	// Flood fill over changed cells, treating cells up to joinCells apart
+	// as neighbours.
+	seen := make([]bool, len(changed))
+	var regions []image.Rectangle
+	for start := range changed {
+		if !changed[start] || seen[start] {
+			continue
+		}
+		seen[start] = true
+		queue := []int{start}
+		box := image.Rectangle{}
+		for len(queue) > 0 {
+			cell := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			cx, cy := cell%cols, cell/cols
+			box = box.Union(image.Rect(cx*cellSize, cy*cellSize, (cx+1)*cellSize, (cy+1)*cellSize))
+
+			for ny := max(cy-joinCells, 0); ny <= min(cy+joinCells, rows-1); ny++ {
+				for nx := max(cx-joinCells, 0); nx <= min(cx+joinCells, cols-1); nx++ {
+					n := ny*cols + nx
+					if changed[n] && !seen[n] {
+						seen[n] = true
+						queue = append(queue, n)
+					}
+				}
+			}
+		}
+		regions = append(regions, box.Intersect(whole))
+	}
+	return merge(regions)
+}
+
+func differs(a, b uint8) bool {
+	if a > b {
+		return a-b > tolerance
+	}
+	return b-a > tolerance
+}
+
+// merge joins overlapping rectangles until none overlap, and sorts them
+// from top to bottom and left to right.
+func merge(rects []image.Rectangle) []image.Rectangle {
+	for joined := true; joined; {
+		joined = false
+		for i := 0; i < len(rects) && !joined; i++ {
+			for j := i + 1; j < len(rects); j++ {
+				if rects[i].Overlaps(rects[j]) {
+					rects[i] = rects[i].Union(rects[j])
+					rects = append(rects[:j], rects[j+1:]...)
+					joined = true
+					break
+				}
+			}
+		}
+	}
+	sort.Slice(rects, func(i, j int) bool {
+		if rects[i].Min.Y != rects[j].Min.Y {
+			return rects[i].Min.Y < rects[j].Min.Y
+		}
+		return rects[i].Min.X < rects[j].Min.X
+	})
+	return rects
+}
+
+// toRGBA returns img as an *image.RGBA with its origin at (0, 0), copying
+// it if needed.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rectangle{Max: img.Bounds().Size()})
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,67 @@
+package diff
+
+import "image"
+
+const (
+	// hashGrid is the number of cells along each side of a Hash.
+	hashGrid = 64
+	// hashTolerance is how far the brightness of a cell may move, out of
+	// 255, before it counts as changed. A blinking text cursor stays just
+	// under it on a full HD screen; a ticked checkbox or a typed letter
+	// does not.
+	hashTolerance = 8
+)
+
+// Hash is a perceptual hash of a screenshot: the mean brightness of each
+// cell of a hashGrid by hashGrid grid laid over it. Unlike a cryptographic
+// hash it changes little when the picture changes little, and Distance
+// tells how much.
+type Hash []uint8
+
+// NewHash computes the hash of img.
+func NewHash(img image.Image) Hash {
+	rgba := toRGBA(img)
+	size := rgba.Rect.Size()
+	if size.X == 0 || size.Y == 0 {
+		return nil
+	}
+
+	var sums, counts [hashGrid * hashGrid]uint64
+	for y := 0; y < size.Y; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		cy := y * hashGrid / size.Y
+		for x := 0; x < size.X; x++ {
+			p := row[x*4 : x*4+3]
+			// ITU-R BT.601 luma in fixed point.
+			luma := (299*uint64(p[0]) + 587*uint64(p[1]) + 114*uint64(p[2])) / 1000
+			cell := cy*hashGrid + x*hashGrid/size.X
+			sums[cell] += luma
+			counts[cell]++
+		}
+	}
+
+	h := make(Hash, hashGrid*hashGrid)
+	for i := range h {
+		if counts[i] > 0 {
+			h[i] = uint8(sums[i] / counts[i])
+		}
+	}
+	return h
+}
+
+// Distance returns how many cells of a and b differ in brightness by more
+// than hashTolerance. Images that were not both hashed are as different as
+// can be.
+func Distance(a, b Hash) int {
+	if len(a) != len(b) || len(a) == 0 {
+		return hashGrid * hashGrid
+	}
+	n := 0
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d > hashTolerance || d < -hashTolerance {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package diff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// filled returns a w by h image of one grey.
+func filled(w, h int, grey uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{grey}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestDistance(t *testing.T) {
+	base := filled(640, 512, 128)
+	// One hash cell of a 640x512 image is 10x8 pixels.
+	cell := image.Rect(0, 0, 10, 8)
+
+	tests := []struct {
+		name  string
+		other func() image.Image
+		want  int
+	}{
+		{"same", func() image.Image { return filled(640, 512, 128) }, 0},
+		{"within tolerance", func() image.Image { return filled(640, 512, 128+hashTolerance) }, 0},
+		{"beyond tolerance", func() image.Image { return filled(640, 512, 128+hashTolerance+1) }, hashGrid * hashGrid},
+		{"one cell", func() image.Image {
+			img := filled(640, 512, 128)
+			draw.Draw(img, cell, image.NewUniform(color.White), image.Point{}, draw.Src)
+			return img
+		}, 1},
+		{"scaled", func() image.Image { return filled(1280, 1024, 128) }, 0},
+		{"empty", func() image.Image { return image.NewRGBA(image.Rectangle{}) }, hashGrid * hashGrid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Distance(NewHash(base), NewHash(tt.other())); got != tt.want {
+				t.Errorf("Distance = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceUnhashed(t *testing.T) {
+	h := NewHash(filled(64, 64, 0))
+	for _, tt := range []struct {
+		name string
+		a, b Hash
+	}{
+		{"both nil", nil, nil},
+		{"one nil", h, nil},
+		{"different lengths", h, h[:10]},
+	} {
+		if got := Distance(tt.a, tt.b); got != hashGrid*hashGrid {
+			t.Errorf("%s: Distance = %d, want %d", tt.name, got, hashGrid*hashGrid)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package edit
+
+import (
+	"fmt"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+)
+
+// Insert adds step at index, moving the step there and those after it
+// down, and returns the ID of the new step.
+func (e *Editor) Insert(index int, step model.Step) (ID, error) {
+	if index < 0 || index > len(e.steps) {
+		return 0, fmt.Errorf("cannot insert a step at %d of %d", index+1, len(e.steps)+1)
+	}
+	en := entry{id: e.newID(), step: step}
+	e.run(&insertStep{index: index, entry: en})
+	return en.id, nil
+}
+
+// Delete removes the step with the given ID.
+func (e *Editor) Delete(id ID) error {
+	i, err := e.find(id)
+	if err != nil {
+		return err
+	}
+	e.run(&deleteStep{index: i, entry: e.steps[i]})
+	return nil
+}
+
+// Move moves the step with the given ID to index, shifting the steps in
+// between.
+func (e *Editor) Move(id ID, index int) error {
+	from, err := e.find(id)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(e.steps) {
+		return fmt.Errorf("cannot move a step to %d of %d", index+1, len(e.steps))
+	}
+	if from != index {
+		e.run(&moveStep{from: from, to: index})
+	}
+	return nil
+}
+
+// SetDescription changes the description of the step with the given ID.
+func (e *Editor) SetDescription(id ID, text string) error {
+	return e.update(id, "Edit description of", func(step *model.Step) {
+		step.Description = text
+	})
+}
+
+// Annotate replaces the annotations, redactions and pointer of the step
+// with the given ID.
+func (e *Editor) Annotate(id ID, annotations []annotation.Annotation, redactions []redact.Redaction, pointer *cursor.Layer) error {
+	return e.update(id, "Annotate", func(step *model.Step) {
+		step.Annotations = annotations
+		step.Redactions = redactions
+		step.Cursor = pointer
+	})
+}
+
+// update records change to the step with the given ID as one edit, named
+// verb followed by the step.
+func (e *Editor) update(id ID, verb string, change func(*model.Step)) error {
+	i, err := e.find(id)
+	if err != nil {
+		return err
+	}
+	before := e.steps[i].step
+	after := before
+	change(&after)
+	e.run(&replaceStep{id: id, verb: verb, number: i + 1, before: before, after: after})
+	return nil
+}
+
+type insertStep struct {
+	index int
+	entry entry
+}
+
+func (c *insertStep) do(e *Editor) {
+	e.steps = append(e.steps, entry{})
+	copy(e.steps[c.index+1:], e.steps[c.index:])
+	e.steps[c.index] = c.entry
+}
+
+func (c *insertStep) undo(e *Editor) {
+	e.steps = append(e.steps[:c.index], e.steps[c.index+1:]...)
+}
+
+func (c *insertStep) String() string {
+	return fmt.Sprintf("Insert step %d", c.index+1)
+}
+
+// deleteStep is an insertStep the other way around.
+type deleteStep insertStep
+
+func (c *deleteStep) do(e *Editor) {
+	(*insertStep)(c).undo(e)
+}
+
+func (c *deleteStep) undo(e *Editor) {
+	(*insertStep)(c).do(e)
+}
+
+func (c *deleteStep) String() string {
+	return fmt.Sprintf("Delete step %d", c.index+1)
+}
+
+type moveStep struct {
+	from, to int
+}
+
+func (c *moveStep) do(e *Editor) {
+	move(e.steps, c.from, c.to)
+}
+
+func (c *moveStep) undo(e *Editor) {
+	move(e.steps, c.to, c.from)
+}
+
+func (c *moveStep) String() string {
+	return fmt.Sprintf("Move step %d", c.from+1)
+}
+
+// move moves steps[from] to index to, shifting the steps in between.
+func move(steps []entry, from, to int) {
+	en := steps[from]
+	if from < to {
+		copy(steps[from:to], steps[from+1:to+1])
+	} else {
+		copy(steps[to+1:from+1], steps[to:from])
+	}
+	steps[to] = en
+}
+
+// replaceStep swaps a step for a changed copy. The step is found by its ID
+// rather than its position, which other edits may have changed.
+type replaceStep struct {
+	id     ID
+	verb   string
+	number int
+
+	before, after model.Step
+}
+
+func (c *replaceStep) do(e *Editor) {
+	c.set(e, c.after)
+}
+
+func (c *replaceStep) undo(e *Editor) {
+	c.set(e, c.before)
+}
+
+func (c *replaceStep) set(e *Editor, step model.Step) {
+	i := e.Index(c.id)
+	e.steps[i].step = step
+	e.steps[i].rev++
+}
+
+func (c *replaceStep) String() string {
+	return fmt.Sprintf("%s step %d", c.verb, c.number)
+}
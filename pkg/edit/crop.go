@@ -0,0 +1,118 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// Crop cuts the screenshots of the step with the given ID down to r, in
+// screenshot pixels. The cropped screenshots are stored in session; the
+// originals are kept, so the crop can be undone. Annotations, redactions,
+// the pointer and the changed areas move with the pixels under them, and
+// CaptureArea shrinks to match, so Coordinates still point at the same
+// place on the desktop.
+func (e *Editor) Crop(id ID, r image.Rectangle, session *store.Session) error {
+	i, err := e.find(id)
+	if err != nil {
+		return err
+	}
+	step := e.steps[i].step
+	if step.Screenshot == nil {
+		return fmt.Errorf("step %d has no screenshot", i+1)
+	}
+	bounds := step.Screenshot.Bounds()
+	r = r.Canon().Intersect(bounds)
+	if r.Empty() {
+		return errors.New("the crop area is outside the screenshot")
+	}
+	if r == bounds {
+		return nil
+	}
+
+	cropped, err := crop(step, r, session)
+	if err != nil {
+		return err
+	}
+	e.run(&replaceStep{id: id, verb: "Crop", number: i + 1, before: step, after: cropped})
+	return nil
+}
+
+// crop returns step with its screenshots cut down to r, which lies inside
+// them.
+func crop(step model.Step, r image.Rectangle, session *store.Session) (model.Step, error) {
+	var err error
+	if step.Screenshot, err = cropImage(step.Screenshot, r, session); err != nil {
+		return step, err
+	}
+	if step.AfterScreenshot != nil {
+		if step.AfterScreenshot, err = cropImage(step.AfterScreenshot, r, session); err != nil {
+			return step, err
+		}
+	}
+	step.CaptureArea = r.Add(step.CaptureArea.Min)
+
+	d := r.Min.Mul(-1)
+	annotations := make([]annotation.Annotation, len(step.Annotations))
+	for i, a := range step.Annotations {
+		a = a.Moved(d)
+		// Moved leaves the window of a click marker alone; here it has to
+		// move with the screenshot too.
+		if a.Kind != annotation.KindRect && !a.Rect.Empty() {
+			a.Rect = a.Rect.Add(d)
+		}
+		annotations[i] = a
+	}
+	step.Annotations = annotations
+
+	step.Redactions = movedRedactions(step.Redactions, d)
+	step.AfterRedactions = movedRedactions(step.AfterRedactions, d)
+
+	if step.Cursor != nil {
+		pointer := *step.Cursor
+		pointer.At = pointer.At.Add(d)
+		step.Cursor = &pointer
+	}
+
+	var changes []image.Rectangle
+	for _, c := range step.Changes {
+		if c = c.Intersect(r); !c.Empty() {
+			changes = append(changes, c.Add(d))
+		}
+	}
+	step.Changes = changes
+	return step, nil
+}
+
+func movedRedactions(redactions []redact.Redaction, d image.Point) []redact.Redaction {
+	if redactions == nil {
+		return nil
+	}
+	moved := make([]redact.Redaction, len(redactions))
+	for i, rd := range redactions {
+		rd.Rect = rd.Rect.Add(d)
+		moved[i] = rd
+	}
+	return moved
+}
+
+// cropImage stores the part r of img in session as a new screenshot.
+func cropImage(img *store.Image, r image.Rectangle, session *store.Session) (*store.Image, error) {
+	src, err := img.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load screenshot: %w", err)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Src)
+	cropped, err := session.Put(dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store cropped screenshot: %w", err)
+	}
+	return cropped, nil
+}
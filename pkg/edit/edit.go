@@ -0,0 +1,177 @@
+// Package edit changes a recording after it was recorded. Every change is
+// a command that can be undone and redone, without limit, and steps are
+// addressed by IDs that stay the same however they are reordered, so a
+// user interface never acts on the wrong step.
+package edit
+
+import (
+	"fmt"
+
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// ID identifies a step for as long as the Editor exists.
+type ID int
+
+// Editor holds a recording being edited, and the history of its edits.
+// It is not safe for concurrent use.
+type Editor struct {
+	rec    model.Recording
+	steps  []entry
+	nextID ID
+
+	undo []command
+	redo []command
+}
+
+// entry is a step with its ID. rev counts the changes made to the step,
+// so a view can tell which steps to redraw.
+type entry struct {
+	id   ID
+	rev  int
+	step model.Step
+}
+
+// command is one edit. do applies it and undo reverts it; each is only
+// called on the state the other left behind.
+type command interface {
+	do(e *Editor)
+	undo(e *Editor)
+	// String describes the edit, e.g. "Delete step 3".
+	String() string
+}
+
+// NewEditor starts editing rec. The editor works on its own copy of the
+// step list; rec itself is left unchanged.
+func NewEditor(rec *model.Recording) *Editor {
+	e := &Editor{rec: *rec}
+	e.rec.Steps = nil
+	for _, step := range rec.Steps {
+		e.steps = append(e.steps, entry{id: e.newID(), step: step})
+	}
+	return e
+}
+
+func (e *Editor) newID() ID {
+	e.nextID++
+	return e.nextID
+}
+
+// Recording returns the recording as edited so far.
+func (e *Editor) Recording() *model.Recording {
+	rec := e.rec
+	rec.Steps = e.Steps()
+	return &rec
+}
+
+// Steps returns the steps in order.
+func (e *Editor) Steps() []model.Step {
+	steps := make([]model.Step, len(e.steps))
+	for i, en := range e.steps {
+		steps[i] = en.step
+	}
+	return steps
+}
+
+// IDs returns the IDs of the steps in order.
+func (e *Editor) IDs() []ID {
+	ids := make([]ID, len(e.steps))
+	for i, en := range e.steps {
+		ids[i] = en.id
+	}
+	return ids
+}
+
+// Len returns the number of steps.
+func (e *Editor) Len() int {
+	return len(e.steps)
+}
+
+// Index returns the position of the step with the given ID, or -1 if
+// there is no such step.
+func (e *Editor) Index(id ID) int {
+	for i, en := range e.steps {
+		if en.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Step returns the step with the given ID.
+func (e *Editor) Step(id ID) (model.Step, bool) {
+	i := e.Index(id)
+	if i < 0 {
+		return model.Step{}, false
+	}
+	return e.steps[i].step, true
+}
+
+// Revision returns a number that changes whenever the step with the given
+// ID does, including when an edit to it is undone.
+func (e *Editor) Revision(id ID) int {
+	if i := e.Index(id); i >= 0 {
+		return e.steps[i].rev
+	}
+	return 0
+}
+
+// run applies c and records it for Undo. A new edit discards the edits
+// that could have been redone.
+func (e *Editor) run(c command) {
+	c.do(e)
+	e.undo = append(e.undo, c)
+	e.redo = nil
+}
+
+// CanUndo reports whether there is an edit to undo, and describes it.
+func (e *Editor) CanUndo() (string, bool) {
+	if len(e.undo) == 0 {
+		return "", false
+	}
+	return e.undo[len(e.undo)-1].String(), true
+}
+
+// CanRedo reports whether there is an undone edit to redo, and describes
+// it.
+func (e *Editor) CanRedo() (string, bool) {
+	if len(e.redo) == 0 {
+		return "", false
+	}
+	return e.redo[len(e.redo)-1].String(), true
+}
+
+// Undo reverts the last edit. It reports false if there is none.
+func (e *Editor) Undo() bool {
+	if len(e.undo) == 0 {
+		return false
+	}
+	c := e.undo[len(e.undo)-1]
+	e.undo = e.undo[:len(e.undo)-1]
+	c.undo(e)
+	e.redo = append(e.redo, c)
+	return true
+}
+
+// Redo applies the last undone edit again. It reports false if there is
+// none.
+func (e *Editor) Redo() bool {
+	if len(e.redo) == 0 {
+		return false
+	}
+	c := e.redo[len(e.redo)-1]
+	e.redo = e.redo[:len(e.redo)-1]
+	c.do(e)
+	e.undo = append(e.undo, c)
+	return true
+}
+
+// find returns the index of the step with the given ID, or an error
+// naming it.
+func (e *Editor) find(id ID) (int, error) {
+	i := e.Index(id)
+	if i < 0 {
+		return -1, fmt.Errorf("no step with ID %d", id)
+	}
+	return i, nil
+}
@@ -0,0 +1,389 @@
+package edit
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/highlight"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+var annotationOptions = highlight.Options{Color: color.NRGBA{R: 255, A: 255}, Size: 20, Stroke: 3, Opacity: 1, Scale: 1}
+
+// newTestEditor edits a recording of steps described "a", "b", "c" and so
+// on.
+func newTestEditor(n int) *Editor {
+	rec := &model.Recording{}
+	for i := range n {
+		rec.Steps = append(rec.Steps, model.Step{Description: string(rune('a' + i))})
+	}
+	return NewEditor(rec)
+}
+
+func descriptions(e *Editor) []string {
+	var names []string
+	for _, step := range e.Steps() {
+		names = append(names, step.Description)
+	}
+	return names
+}
+
+func TestEdits(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit changes an editor of steps a, b, c, whose IDs are given.
+		edit func(e *Editor, ids []ID) error
+		want []string
+	}{
+		{
+			name: "insert at the start",
+			edit: func(e *Editor, ids []ID) error {
+				_, err := e.Insert(0, model.Step{Description: "x"})
+				return err
+			},
+			want: []string{"x", "a", "b", "c"},
+		},
+		{
+			name: "insert in the middle",
+			edit: func(e *Editor, ids []ID) error {
+				_, err := e.Insert(1, model.Step{Description: "x"})
+				return err
+			},
+			want: []string{"a", "x", "b", "c"},
+		},
+		{
+			name: "insert at the end",
+			edit: func(e *Editor, ids []ID) error {
+				_, err := e.Insert(3, model.Step{Description: "x"})
+				return err
+			},
+			want: []string{"a", "b", "c", "x"},
+		},
+		{
+			name: "delete the first",
+			edit: func(e *Editor, ids []ID) error { return e.Delete(ids[0]) },
+			want: []string{"b", "c"},
+		},
+		{
+			name: "delete the middle",
+			edit: func(e *Editor, ids []ID) error { return e.Delete(ids[1]) },
+			want: []string{"a", "c"},
+		},
+		{
+			name: "delete the last",
+			edit: func(e *Editor, ids []ID) error { return e.Delete(ids[2]) },
+			want: []string{"a", "b"},
+		},
+		{
+			name: "move the first to the end",
+			edit: func(e *Editor, ids []ID) error { return e.Move(ids[0], 2) },
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "move the last to the start",
+			edit: func(e *Editor, ids []ID) error { return e.Move(ids[2], 0) },
+			want: []string{"c", "a", "b"},
+		},
+		{
+			name: "move the middle down",
+			edit: func(e *Editor, ids []ID) error { return e.Move(ids[1], 2) },
+			want: []string{"a", "c", "b"},
+		},
+		{
+			name: "set a description",
+			edit: func(e *Editor, ids []ID) error { return e.SetDescription(ids[1], "x") },
+			want: []string{"a", "x", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEditor(3)
+			if err := tt.edit(e, e.IDs()); err != nil {
+				t.Fatalf("edit: %v", err)
+			}
+			if got := descriptions(e); !slices.Equal(got, tt.want) {
+				t.Fatalf("after the edit: %q, want %q", got, tt.want)
+			}
+
+			if !e.Undo() {
+				t.Fatal("Undo reported nothing to undo")
+			}
+			if got, want := descriptions(e), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+				t.Errorf("after Undo: %q, want %q", got, want)
+			}
+			if e.Undo() {
+				t.Error("Undo undid more than the one edit")
+			}
+
+			if !e.Redo() {
+				t.Fatal("Redo reported nothing to redo")
+			}
+			if got := descriptions(e); !slices.Equal(got, tt.want) {
+				t.Errorf("after Redo: %q, want %q", got, tt.want)
+			}
+			if e.Redo() {
+				t.Error("Redo redid more than the one edit")
+			}
+		})
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	e := newTestEditor(3)
+	ids := e.IDs()
+	missing := ids[2] + 1
+
+	tests := []struct {
+		name string
+		edit func() error
+	}{
+		{"insert before the start", func() error { _, err := e.Insert(-1, model.Step{}); return err }},
+		{"insert past the end", func() error { _, err := e.Insert(4, model.Step{}); return err }},
+		{"delete a missing step", func() error { return e.Delete(missing) }},
+		{"move a missing step", func() error { return e.Move(missing, 0) }},
+		{"move past the end", func() error { return e.Move(ids[0], 3) }},
+		{"describe a missing step", func() error { return e.SetDescription(missing, "x") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.edit(); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+	if _, ok := e.CanUndo(); ok {
+		t.Error("a failed edit can be undone")
+	}
+	if got, want := descriptions(e), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	e := newTestEditor(2)
+	id := e.IDs()[1]
+	annotations := []annotation.Annotation{annotation.Arrow(image.Pt(1, 2), image.Pt(3, 4), annotationOptions)}
+	redactions := []redact.Redaction{{Rect: image.Rect(0, 0, 5, 5), Method: redact.Pixelate}}
+	pointer := &cursor.Layer{At: image.Pt(7, 8)}
+
+	if err := e.Annotate(id, annotations, redactions, pointer); err != nil {
+		t.Fatalf("Annotate: %v", err)
+	}
+	step, _ := e.Step(id)
+	if !slices.Equal(step.Annotations, annotations) || !slices.Equal(step.Redactions, redactions) || step.Cursor != pointer {
+		t.Errorf("step = %+v, want the new annotations, redactions and pointer", step)
+	}
+	if desc, _ := e.CanUndo(); desc != "Annotate step 2" {
+		t.Errorf("CanUndo = %q, want %q", desc, "Annotate step 2")
+	}
+
+	e.Undo()
+	step, _ = e.Step(id)
+	if step.Annotations != nil || step.Redactions != nil || step.Cursor != nil {
+		t.Errorf("after Undo: step = %+v, want no annotations", step)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := newTestEditor(3)
+	ids := e.IDs()
+
+	e.SetDescription(ids[0], "x")
+	e.Delete(ids[2])
+	e.Undo()
+	if desc, ok := e.CanRedo(); !ok || desc != "Delete step 3" {
+		t.Errorf("CanRedo = %q, %v, want %q", desc, ok, "Delete step 3")
+	}
+
+	// A new edit starts a new branch of history.
+	e.Move(ids[0], 1)
+	if _, ok := e.CanRedo(); ok {
+		t.Error("a new edit left the redo stack")
+	}
+	if e.Redo() {
+		t.Error("Redo after a new edit")
+	}
+
+	for e.Undo() {
+	}
+	if got, want := descriptions(e), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("after undoing everything: %q, want %q", got, want)
+	}
+	for e.Redo() {
+	}
+	if got, want := descriptions(e), []string{"b", "x", "c"}; !slices.Equal(got, want) {
+		t.Errorf("after redoing everything: %q, want %q", got, want)
+	}
+}
+
+func TestIDsAndRevisions(t *testing.T) {
+	e := newTestEditor(3)
+	ids := e.IDs()
+	if len(ids) != 3 || ids[0] == ids[1] || ids[1] == ids[2] || ids[0] == ids[2] {
+		t.Fatalf("IDs = %v, want three distinct IDs", ids)
+	}
+
+	e.SetDescription(ids[1], "x")
+	rev := e.Revision(ids[1])
+	if rev == 0 {
+		t.Error("Revision did not change with the description")
+	}
+
+	e.Move(ids[0], 2)
+	e.Move(ids[2], 0)
+	if got, want := e.IDs(), []ID{ids[2], ids[1], ids[0]}; !slices.Equal(got, want) {
+		t.Errorf("IDs after moves = %v, want %v", got, want)
+	}
+	for i, id := range ids {
+		step, ok := e.Step(id)
+		if !ok || step.Description != []string{"a", "x", "c"}[i] {
+			t.Errorf("step %d moved away from its ID: %+v", id, step)
+		}
+	}
+	if got := e.Revision(ids[1]); got != rev {
+		t.Errorf("Revision after moves = %d, want %d", got, rev)
+	}
+	if got := e.Revision(ids[0]); got != 0 {
+		t.Errorf("Revision of a moved step = %d, want 0", got)
+	}
+
+	inserted, _ := e.Insert(0, model.Step{Description: "y"})
+	if slices.Contains(ids, inserted) {
+		t.Errorf("inserted step reuses ID %d", inserted)
+	}
+	e.Delete(ids[1])
+	e.Undo()
+	if i := e.Index(ids[1]); i < 0 {
+		t.Error("undoing a delete lost the step's ID")
+	}
+
+	for e.Undo() {
+	}
+	if got := e.Revision(ids[1]); got == rev {
+		t.Error("Revision did not change when the description was undone")
+	}
+	if got, want := e.IDs(), ids; !slices.Equal(got, want) {
+		t.Errorf("IDs after undoing everything = %v, want %v", got, want)
+	}
+}
+
+func TestCrop(t *testing.T) {
+	session, err := store.NewSession(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	put := func(c color.Color) *store.Image {
+		t.Helper()
+		img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+		for y := range 80 {
+			for x := range 100 {
+				img.Set(x, y, c)
+			}
+		}
+		// Mark the pixel that ends up at the cropped origin.
+		img.Set(10, 20, color.Black)
+		stored, err := session.Put(img)
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		return stored
+	}
+
+	step := model.Step{
+		Screenshot:      put(color.White),
+		AfterScreenshot: put(color.Gray{Y: 128}),
+		CaptureArea:     image.Rect(1000, 500, 1100, 580),
+		Annotations: []annotation.Annotation{
+			annotation.Click(image.Pt(50, 40), image.Rect(5, 5, 95, 75), "window", annotationOptions),
+			annotation.Arrow(image.Pt(15, 25), image.Pt(60, 50), annotationOptions),
+			{Kind: annotation.KindRect, Rect: image.Rect(20, 30, 40, 50)},
+		},
+		Redactions:      []redact.Redaction{{Rect: image.Rect(30, 30, 50, 40), Method: redact.Pixelate}},
+		AfterRedactions: []redact.Redaction{{Rect: image.Rect(0, 0, 100, 30), Method: redact.Fill}},
+		Cursor:          &cursor.Layer{At: image.Pt(50, 40)},
+		Changes: []image.Rectangle{
+			image.Rect(20, 30, 30, 40), // inside
+			image.Rect(0, 0, 20, 30),   // partly inside
+			image.Rect(0, 0, 5, 5),     // outside
+		},
+	}
+	e := NewEditor(&model.Recording{Steps: []model.Step{step}})
+	id := e.IDs()[0]
+
+	if err := e.Crop(id, image.Rect(90, 70, 10, 20), session); err != nil {
+		t.Fatalf("Crop: %v", err)
+	}
+	got, _ := e.Step(id)
+
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"screenshot", got.Screenshot.Bounds(), image.Rect(0, 0, 80, 50)},
+		{"after screenshot", got.AfterScreenshot.Bounds(), image.Rect(0, 0, 80, 50)},
+		{"capture area", got.CaptureArea, image.Rect(1010, 520, 1090, 570)},
+		{"click", got.Annotations[0].At, image.Pt(40, 20)},
+		{"click window", got.Annotations[0].Rect, image.Rect(-5, -15, 85, 55)},
+		{"arrow tail", got.Annotations[1].From, image.Pt(5, 5)},
+		{"arrow tip", got.Annotations[1].At, image.Pt(50, 30)},
+		{"rectangle", got.Annotations[2].Rect, image.Rect(10, 10, 30, 30)},
+		{"redaction", got.Redactions[0].Rect, image.Rect(20, 10, 40, 20)},
+		{"after redaction", got.AfterRedactions[0].Rect, image.Rect(-10, -20, 90, 10)},
+		{"cursor", got.Cursor.At, image.Pt(40, 20)},
+		{"changes", len(got.Changes), 2},
+		{"change inside", got.Changes[0], image.Rect(10, 10, 20, 20)},
+		{"change partly inside", got.Changes[1], image.Rect(0, 0, 10, 10)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	img, err := got.Screenshot.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0 {
+		t.Error("cropped screenshot does not start at the crop area")
+	}
+
+	// The original is left alone, and comes back on Undo.
+	e.Undo()
+	undone, _ := e.Step(id)
+	if undone.Screenshot != step.Screenshot || undone.CaptureArea != step.CaptureArea || undone.Cursor.At != step.Cursor.At {
+		t.Errorf("after Undo: step = %+v, want the original", undone)
+	}
+}
+
+func TestCropNothing(t *testing.T) {
+	session, err := store.NewSession(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	shot, err := session.Put(image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	e := NewEditor(&model.Recording{Steps: []model.Step{{Screenshot: shot}, {}}})
+	ids := e.IDs()
+
+	if err := e.Crop(ids[0], image.Rect(-5, -5, 20, 20), session); err != nil {
+		t.Errorf("cropping to more than the screenshot: %v", err)
+	}
+	if _, ok := e.CanUndo(); ok {
+		t.Error("cropping to the whole screenshot was recorded as an edit")
+	}
+	if err := e.Crop(ids[0], image.Rect(20, 20, 30, 30), session); err == nil {
+		t.Error("cropping outside the screenshot: no error")
+	}
+	if err := e.Crop(ids[1], image.Rect(0, 0, 5, 5), session); err == nil {
+		t.Error("cropping a step without a screenshot: no error")
+	}
+}
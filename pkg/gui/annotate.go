@@ -0,0 +1,387 @@
+//go:build windows
+// +build windows
+
+package gui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/highlight"
+	"github.com/gustaf/go-test/pkg/redact"
+)
+
+// Annotation editor tools.
+const (
+	toolMove    = "Move"
+	toolClick   = "Click marker"
+	toolArrow   = "Arrow"
+	toolRect    = "Rectangle"
+	toolCallout = "Callout"
+	toolBadge   = "Badge"
+	toolRedact  = "Redact"
+)
+
+var annotationColors = []struct {
+	name  string
+	color color.NRGBA
+}{
+	{"Red", color.NRGBA{R: 255, A: 255}},
+	{"Orange", color.NRGBA{R: 255, G: 140, A: 255}},
+	{"Yellow", color.NRGBA{R: 255, G: 214, A: 255}},
+	{"Green", color.NRGBA{R: 46, G: 160, B: 67, A: 255}},
+	{"Blue", color.NRGBA{R: 33, G: 118, B: 255, A: 255}},
+	{"Black", color.NRGBA{A: 255}},
+}
+
+// annotationCanvas shows a screenshot with its annotations and turns taps
+// and drags on it into edits with the selected tool.
+type annotationCanvas struct {
+	widget.BaseWidget
+
+	raster      *canvas.Image
+	base        image.Image
+	annotations []annotation.Annotation
+	redactions  []redact.Redaction
+	// pointer is the recorded mouse pointer, or nil if there is none or it
+	// was removed.
+	pointer *cursor.Layer
+	// style is used for new annotations.
+	style    annotation.Annotation
+	tool     string
+	onChange func()
+	// onCallout asks for the text of a callout placed at a point.
+	onCallout func(at image.Point)
+
+	dragging  bool
+	dragStart image.Point
+	dragEnd   image.Point
+	moving    int
+	moved     annotation.Annotation
+}
+
+func newAnnotationCanvas(base image.Image, annotations []annotation.Annotation, redactions []redact.Redaction, pointer *cursor.Layer, style annotation.Annotation) *annotationCanvas {
+	c := &annotationCanvas{
+		raster:      canvas.NewImageFromImage(base),
+		base:        base,
+		annotations: append([]annotation.Annotation(nil), annotations...),
+		redactions:  append([]redact.Redaction(nil), redactions...),
+		pointer:     pointer,
+		style:       style,
+		tool:        toolMove,
+		moving:      -1,
+	}
+	c.raster.FillMode = canvas.ImageFillContain
+	c.raster.SetMinSize(fyne.NewSize(640, 400))
+	c.ExtendBaseWidget(c)
+	c.redraw()
+	return c
+}
+
+func (c *annotationCanvas) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(c.raster)
+}
+
+// toImage converts a position on the widget to screenshot pixels, taking
+// the scaling and letterboxing of the image into account.
+func (c *annotationCanvas) toImage(pos fyne.Position) image.Point {
+	b := c.base.Bounds()
+	size := c.Size()
+	scale := min(size.Width/float32(b.Dx()), size.Height/float32(b.Dy()))
+	if scale <= 0 {
+		return b.Min
+	}
+	offX := (size.Width - float32(b.Dx())*scale) / 2
+	offY := (size.Height - float32(b.Dy())*scale) / 2
+	return image.Pt(
+		b.Min.X+int((pos.X-offX)/scale),
+		b.Min.Y+int((pos.Y-offY)/scale),
+	)
+}
+
+func (c *annotationCanvas) Tapped(ev *fyne.PointEvent) {
+	at := c.toImage(ev.Position)
+	switch c.tool {
+	case toolClick:
+		a := c.style
+		a.Kind, a.At, a.Rect = annotation.KindClick, at, image.Rectangle{}
+		c.add(a)
+	case toolBadge:
+		a := c.style
+		a.Kind, a.At, a.Text = annotation.KindBadge, at, strconv.Itoa(c.count(annotation.KindBadge)+1)
+		c.add(a)
+	case toolCallout:
+		if c.onCallout != nil {
+			c.onCallout(at)
+		}
+	}
+}
+
+func (c *annotationCanvas) Dragged(ev *fyne.DragEvent) {
+	pos := c.toImage(ev.Position)
+	if !c.dragging {
+		c.dragging = true
+		c.dragStart = c.toImage(ev.Position.Subtract(ev.Dragged))
+		c.moving = -1
+		if c.tool == toolMove {
+			c.moving = c.pick(c.dragStart)
+			if c.moving >= 0 {
+				c.moved = c.annotations[c.moving]
+			}
+		}
+	}
+	c.dragEnd = pos
+
+	if c.tool == toolMove && c.moving >= 0 {
+		c.annotations[c.moving] = c.moved.Moved(c.dragEnd.Sub(c.dragStart))
+	}
+	c.redraw()
+}
+
+func (c *annotationCanvas) DragEnd() {
+	if !c.dragging {
+		return
+	}
+	c.dragging = false
+
+	switch {
+	case c.tool == toolMove && c.moving >= 0:
+		c.moving = -1
+		c.changed()
+	case c.dragStart == c.dragEnd:
+		c.redraw()
+	case c.tool == toolArrow || c.tool == toolRect:
+		c.add(c.dragged())
+	case c.tool == toolRedact:
+		c.redactions = append(c.redactions, c.draggedRedaction())
+		c.changed()
+	default:
+		c.redraw()
+	}
+}
+
+// draggedRedaction returns the redaction spanned by the current drag.
+func (c *annotationCanvas) draggedRedaction() redact.Redaction {
+	return redact.Redaction{
+		Rect:   image.Rectangle{Min: c.dragStart, Max: c.dragEnd}.Canon(),
+		Method: redact.Pixelate,
+	}
+}
+
+// dragged returns the arrow or rectangle spanned by the current drag.
+func (c *annotationCanvas) dragged() annotation.Annotation {
+	a := c.style
+	if c.tool == toolArrow {
+		a.Kind, a.From, a.At = annotation.KindArrow, c.dragStart, c.dragEnd
+	} else {
+		a.Kind, a.Rect = annotation.KindRect, image.Rectangle{Min: c.dragStart, Max: c.dragEnd}.Canon()
+	}
+	return a
+}
+
+// pick returns the index of the topmost annotation at p, or -1.
+func (c *annotationCanvas) pick(p image.Point) int {
+	for i := len(c.annotations) - 1; i >= 0; i-- {
+		if p.In(c.annotations[i].Bounds()) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *annotationCanvas) count(kind annotation.Kind) int {
+	n := 0
+	for _, a := range c.annotations {
+		if a.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *annotationCanvas) add(a annotation.Annotation) {
+	c.annotations = append(c.annotations, a)
+	c.changed()
+}
+
+func (c *annotationCanvas) remove(i int) {
+	c.annotations = append(c.annotations[:i], c.annotations[i+1:]...)
+	c.changed()
+}
+
+func (c *annotationCanvas) removeRedaction(i int) {
+	c.redactions = append(c.redactions[:i], c.redactions[i+1:]...)
+	c.changed()
+}
+
+func (c *annotationCanvas) changed() {
+	c.redraw()
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+// redraw renders the redactions and annotations over the screenshot,
+// including the one being dragged out.
+func (c *annotationCanvas) redraw() {
+	annotations, redactions := c.annotations, c.redactions
+	if c.dragging && c.dragStart != c.dragEnd {
+		switch c.tool {
+		case toolArrow, toolRect:
+			annotations = append(annotations[:len(annotations):len(annotations)], c.dragged())
+		case toolRedact:
+			redactions = append(redactions[:len(redactions):len(redactions)], c.draggedRedaction())
+		}
+	}
+	img, err := annotation.Render(cursor.Render(redact.Apply(c.base, redactions), c.pointer), annotations)
+	if err != nil {
+		log.Printf("Failed to draw annotations: %v", err)
+		return
+	}
+	c.raster.Image = img
+	c.raster.Refresh()
+}
+
+// showAnnotationEditor opens a window for editing the annotations,
+// redactions and recorded pointer of a screenshot. onSave receives all
+// three when the user saves; the pointer is nil once removed.
+func showAnnotationEditor(app fyne.App, base image.Image, annotations []annotation.Annotation, redactions []redact.Redaction, pointer *cursor.Layer, settings *config.Settings, onSave func([]annotation.Annotation, []redact.Redaction, *cursor.Layer)) {
+	win := app.NewWindow("Annotate / Redact")
+	win.Resize(fyne.NewSize(1000, 700))
+
+	style := newAnnotationStyle(annotations, settings)
+	editor := newAnnotationCanvas(base, annotations, redactions, pointer, style)
+
+	list := container.NewVBox()
+	refreshList := func() {
+		list.RemoveAll()
+		for i, a := range editor.annotations {
+			colors := make([]string, len(annotationColors))
+			for j, c := range annotationColors {
+				colors[j] = c.name
+			}
+			colorSelect := widget.NewSelect(colors, nil)
+			for _, c := range annotationColors {
+				if c.color == a.Color {
+					colorSelect.SetSelected(c.name)
+				}
+			}
+			colorSelect.OnChanged = func(name string) {
+				for _, c := range annotationColors {
+					if c.name == name {
+						editor.annotations[i].Color = c.color
+					}
+				}
+				editor.redraw()
+			}
+
+			row := container.NewHBox(widget.NewLabel(a.String()), colorSelect)
+			if a.Kind == annotation.KindClick {
+				styleSelect := widget.NewSelect(highlight.Styles(), nil)
+				styleSelect.SetSelected(a.Style)
+				styleSelect.OnChanged = func(name string) {
+					editor.annotations[i].Style = name
+					editor.redraw()
+				}
+				row.Add(styleSelect)
+			}
+			row.Add(widget.NewButton("Remove", func() { editor.remove(i) }))
+			list.Add(row)
+		}
+		for i, r := range editor.redactions {
+			method := widget.NewSelect([]string{string(redact.Pixelate), string(redact.Fill)}, nil)
+			method.SetSelected(string(r.Method))
+			method.OnChanged = func(name string) {
+				editor.redactions[i].Method = redact.Method(name)
+				editor.redraw()
+			}
+			list.Add(container.NewHBox(
+				widget.NewLabel(r.String()),
+				method,
+				widget.NewButton("Remove", func() { editor.removeRedaction(i) }),
+			))
+		}
+		if editor.pointer != nil {
+			list.Add(container.NewHBox(
+				widget.NewLabel(fmt.Sprintf("Mouse pointer at (%d, %d)", editor.pointer.At.X, editor.pointer.At.Y)),
+				widget.NewButton("Remove", func() {
+					editor.pointer = nil
+					editor.changed()
+				}),
+			))
+		}
+		list.Refresh()
+	}
+	editor.onChange = refreshList
+	editor.onCallout = func(at image.Point) {
+		entry := widget.NewMultiLineEntry()
+		entry.SetPlaceHolder("Callout text")
+		dialog.ShowCustomConfirm("Add Callout", "Add", "Cancel", entry, func(ok bool) {
+			if !ok || entry.Text == "" {
+				return
+			}
+			a := editor.style
+			offset := int(40 * max(a.Scale, 1))
+			a.Kind, a.At, a.From, a.Text = annotation.KindCallout, at, at.Add(image.Pt(offset, offset)), entry.Text
+			editor.add(a)
+		}, win)
+	}
+	refreshList()
+
+	tools := widget.NewRadioGroup([]string{toolMove, toolClick, toolArrow, toolRect, toolCallout, toolBadge, toolRedact}, func(tool string) {
+		editor.tool = tool
+	})
+	tools.Horizontal = true
+	tools.SetSelected(toolMove)
+
+	saveBtn := widget.NewButton("Save", func() {
+		onSave(editor.annotations, editor.redactions, editor.pointer)
+		win.Close()
+	})
+	cancelBtn := widget.NewButton("Cancel", func() { win.Close() })
+
+	win.SetContent(container.NewBorder(
+		container.NewVBox(tools, widget.NewLabel("Drag with Move to reposition an annotation; arrows, rectangles and redactions are drawn by dragging.")),
+		container.NewHBox(saveBtn, cancelBtn),
+		nil,
+		container.NewVScroll(list),
+		editor,
+	))
+	win.Show()
+}
+
+// newAnnotationStyle returns the template for new annotations: the
+// configured highlight look, at the display scale of the existing
+// annotations.
+func newAnnotationStyle(existing []annotation.Annotation, settings *config.Settings) annotation.Annotation {
+	c, err := highlight.ParseColor(settings.HighlightColor)
+	if err != nil {
+		c = annotationColors[0].color
+	}
+	style := annotation.Annotation{
+		Style: settings.HighlightStyle,
+		Options: highlight.Options{
+			Color:   c,
+			Size:    settings.HighlightSize,
+			Stroke:  settings.HighlightStroke,
+			Opacity: settings.HighlightOpacity,
+			Scale:   1,
+		},
+	}
+	if len(existing) > 0 && existing[0].Scale > 0 {
+		style.Scale = existing[0].Scale
+	}
+	return style
+}
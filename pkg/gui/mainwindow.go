@@ -5,9 +5,11 @@ package gui
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -15,39 +17,64 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/bundle"
 	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/edit"
+	"github.com/gustaf/go-test/pkg/model"
 	"github.com/gustaf/go-test/pkg/output"
 	"github.com/gustaf/go-test/pkg/recorder"
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
 )
 
 // RecorderWindow represents the main application window
 type RecorderWindow struct {
-	window     fyne.Window
-	app        fyne.App
-	recorder   *recorder.Recorder
-	settings   *config.Settings
-	isRunning  bool
-	outputPath string
+	window       fyne.Window
+	app          fyne.App
+	recorder     *recorder.Recorder
+	settings     *config.Settings
+	settingsPath string
+	isRunning    atomic.Bool
+	outputPath   string
+
+	// actions runs the commands that start, stop and pause a recording one
+	// at a time, whether they come from a button or from a global hotkey,
+	// which fires on the listener's goroutine. Fyne 2.5 cannot hand work
+	// to its own goroutine, so the recording state is only changed here.
+	actions chan func()
 }
 
-// NewRecorderWindow creates and shows the main application window
-func NewRecorderWindow(settings *config.Settings) error {
+// NewRecorderWindow creates and shows the main application window. Changes
+// made in the settings dialog are saved to settingsPath unless it is "".
+func NewRecorderWindow(settings *config.Settings, settingsPath string) error {
 	app := app.New()
 	window := app.NewWindow("GoStep")
 
 	rw := &RecorderWindow{
-		window:    window,
-		app:       app,
-		settings:  settings,
-		recorder:  recorder.NewRecorder(),
-		isRunning: false,
+		window:       window,
+		app:          app,
+		settings:     settings,
+		settingsPath: settingsPath,
+		recorder:     recorder.NewRecorder(settings),
+		actions:      make(chan func()),
 	}
+	go func() {
+		for action := range rw.actions {
+			action()
+		}
+	}()
 
 	recordBtn := widget.NewButton("Start Recording", nil)
+	pauseBtn := widget.NewButton("Pause", nil)
+	pauseBtn.Disable()
 	status := widget.NewLabel("Ready")
 	outputFormatSelect := widget.NewSelect([]string{"HTML", "PDF"}, nil)
 	outputFormatSelect.SetSelected(settings.OutputFormat)
@@ -56,11 +83,15 @@ func NewRecorderWindow(settings *config.Settings) error {
 	outputLocationLabel.Wrapping = fyne.TextWrapBreak
 
 	settingsBtn := widget.NewButton("Settings", func() {
-		ShowSettingsDialog(window, settings)
+		ShowSettingsDialog(window, settings, rw.saveSettings)
 	})
 
+	openBtn := widget.NewButton("Open Session", rw.openBundle)
+
 	toolbar := container.NewHBox(
 		recordBtn,
+		pauseBtn,
+		openBtn,
 		layout.NewSpacer(),
 		widget.NewLabel("Format:"),
 		outputFormatSelect,
@@ -87,36 +118,97 @@ func NewRecorderWindow(settings *config.Settings) error {
 	outputFormatSelect.OnChanged = func(format string) {
 		settings.OutputFormat = format
 		rw.updateOutputPath()
+		rw.saveSettings()
+	}
+
+	showState := func() {
+		switch {
+		case !rw.isRunning.Load():
+			recordBtn.SetText("Start Recording")
+			pauseBtn.SetText("Pause")
+			pauseBtn.Disable()
+			status.SetText("Ready")
+		case rw.recorder.IsPaused():
+			pauseBtn.SetText("Resume")
+			status.SetText("Paused. Steps so far are kept; resume to continue.")
+		default:
+			recordBtn.SetText("Stop Recording")
+			pauseBtn.SetText("Pause")
+			pauseBtn.Enable()
+			status.SetText("Recording... Click anywhere to capture.")
+		}
 	}
 
-	recordBtn.OnTapped = func() {
-		if !rw.isRunning {
+	startStop := func() {
+		if !rw.isRunning.Load() {
 			if err := rw.startRecording(); err != nil {
 				dialog.ShowError(err, window)
 				return
 			}
-			recordBtn.SetText("Stop Recording")
-			status.SetText("Recording... Click anywhere to capture.")
-			rw.isRunning = true
+			rw.isRunning.Store(true)
 		} else {
+			// Stopped even if the preview cannot open, so the recording
+			// can be started again.
+			rw.isRunning.Store(false)
 			if err := rw.stopRecording(); err != nil {
 				dialog.ShowError(err, window)
-				return
 			}
-			recordBtn.SetText("Start Recording")
-			status.SetText("Ready")
-			rw.isRunning = false
 		}
+		showState()
 	}
 
+	pauseResume := func() {
+		var err error
+		if rw.recorder.IsPaused() {
+			err = rw.recorder.Resume()
+		} else {
+			err = rw.recorder.Pause()
+		}
+		if err != nil {
+			dialog.ShowError(err, window)
+		}
+		showState()
+	}
+
+	recordBtn.OnTapped = func() { rw.actions <- startStop }
+	pauseBtn.OnTapped = func() { rw.actions <- pauseResume }
+
+	// Hotkeys let a recording be started and stopped without clicking the
+	// button, so the click on it does not end up as a step.
+	err := rw.recorder.ListenHotkeys(func(action recorder.HotkeyAction) {
+		switch action {
+		case recorder.HotkeyStartStop:
+			rw.actions <- startStop
+		case recorder.HotkeyPauseResume:
+			// The recorder has already paused or resumed.
+			rw.actions <- showState
+		}
+	})
+	if err != nil {
+		log.Printf("Global hotkeys unavailable: %v", err)
+	}
+	defer rw.recorder.StopHotkeys()
+
 	rw.updateOutputPath()
 
 	window.Show()
+	rw.offerRecovery()
 	app.Run()
 
 	return nil
 }
 
+// saveSettings writes the settings to the settings file, if there is one.
+func (rw *RecorderWindow) saveSettings() {
+	if rw.settingsPath == "" {
+		return
+	}
+	if err := rw.settings.SaveSettings(rw.settingsPath); err != nil {
+		log.Printf("Failed to save settings: %v", err)
+		dialog.ShowError(fmt.Errorf("failed to save settings: %w", err), rw.window)
+	}
+}
+
 func (rw *RecorderWindow) updateOutputPath() {
 	timestamp := time.Now().Format("2006-01-02_150405")
 	ext := "html"
@@ -144,20 +236,138 @@ func (rw *RecorderWindow) stopRecording() error {
 		return err
 	}
 
-	steps := rw.recorder.GetSteps()
-	log.Printf("Retrieved %d steps from recorder", len(steps))
-	if len(steps) == 0 {
+	rec := rw.recorder.Recording()
+	log.Printf("Retrieved %d steps from recorder", len(rec.Steps))
+	if missed := rw.recorder.Stats().String(); missed != "" {
+		dialog.ShowInformation("Missed input",
+			fmt.Sprintf("Some input could not be captured as it happened: %s.", missed), rw.window)
+	}
+	if len(rec.Steps) == 0 {
 		log.Printf("No steps were recorded")
 		return fmt.Errorf("no steps recorded")
 	}
 
-	rw.showImageEditor(steps)
+	var notice string
+	switch merged := rw.recorder.Merged(); merged {
+	case 0:
+	case 1:
+		notice = "1 step that changed nothing on screen was merged into the next one."
+	default:
+		notice = fmt.Sprintf("%d steps that changed nothing on screen were merged into the next ones.", merged)
+	}
+
+	rw.showImageEditor(rec, rw.recorder.Session(), notice)
 	return nil
 }
 
-func (rw *RecorderWindow) showImageEditor(steps []recorder.Step) {
+// offerRecovery asks what to do with each recording that a previous run of
+// GoStep did not finish. A recovered recording opens in the preview like
+// a new one.
+func (rw *RecorderWindow) offerRecovery() {
+	unfinished, err := recorder.FindUnfinished(rw.settings)
+	if err != nil {
+		log.Printf("Failed to look for unfinished recordings: %v", err)
+		return
+	}
+	for _, u := range unfinished {
+		message := widget.NewLabel(fmt.Sprintf(
+			"GoStep closed before it was done with a recording of %d steps from %s. "+
+				"Open it to edit and export it, or discard it for good?",
+			len(u.Recording.Steps), u.Recording.Started.Format("2006-01-02 15:04")))
+		message.Wrapping = fyne.TextWrapWord
+		dlg := dialog.NewCustomConfirm("Recover recording", "Open", "Discard", message, func(open bool) {
+			if open {
+				rw.showImageEditor(u.Recording, u.Session, fmt.Sprintf("Recovered %d steps from an unfinished recording.", len(u.Recording.Steps)))
+				return
+			}
+			if err := u.Session.Remove(); err != nil {
+				log.Printf("Failed to remove session directory: %v", err)
+			}
+		}, rw.window)
+		dlg.Resize(fyne.NewSize(420, 200))
+		dlg.Show()
+	}
+}
+
+// openBundle asks for a saved session and opens it in the preview, where
+// it can be edited and exported like a new recording.
+func (rw *RecorderWindow) openBundle() {
+	dlg := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, rw.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		path := reader.URI().Path()
+		reader.Close()
+
+		session, err := recorder.NewSession(rw.settings)
+		if err != nil {
+			dialog.ShowError(err, rw.window)
+			return
+		}
+		rec, err := bundle.Load(path, session)
+		if err != nil {
+			session.Remove()
+			dialog.ShowError(fmt.Errorf("failed to open %s: %w", filepath.Base(path), err), rw.window)
+			return
+		}
+		log.Printf("Opened %d steps from %s", len(rec.Steps), path)
+		rw.showImageEditor(rec, session, "")
+	}, rw.window)
+	dlg.SetFilter(storage.NewExtensionFileFilter([]string{bundle.Extension}))
+	if dir, err := storage.ListerForURI(storage.NewFileURI(rw.settings.OutputDir)); err == nil {
+		dlg.SetLocation(dir)
+	}
+	dlg.Show()
+}
+
+// saveBundle asks where to save rec as a session bundle and saves it.
+func (rw *RecorderWindow) saveBundle(rec *model.Recording, parent fyne.Window) {
+	dlg := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, parent)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		path := writer.URI().Path()
+		writer.Close()
+
+		if err := bundle.Save(path, rec); err != nil {
+			dialog.ShowError(fmt.Errorf("failed to save session: %w", err), parent)
+			return
+		}
+		log.Printf("Saved %d steps to %s", len(rec.Steps), path)
+	}, parent)
+	dlg.SetFilter(storage.NewExtensionFileFilter([]string{bundle.Extension}))
+	dlg.SetFileName(fmt.Sprintf("recording_%s%s", time.Now().Format("2006-01-02_150405"), bundle.Extension))
+	if dir, err := storage.ListerForURI(storage.NewFileURI(rw.settings.OutputDir)); err == nil {
+		dlg.SetLocation(dir)
+	}
+	dlg.Show()
+}
+
+// showImageEditor opens the preview of a recording. A non-empty notice is
+// shown above the steps. Every change made in the preview goes through an
+// edit.Editor, so it can be undone.
+func (rw *RecorderWindow) showImageEditor(rec *model.Recording, session *store.Session, notice string) {
 	previewWindow := rw.app.NewWindow("Preview Recording")
 	previewWindow.Resize(fyne.NewSize(800, 600))
+	// The screenshots live in the session directory until the preview is
+	// closed; they are copied into each report exported and into a saved
+	// session bundle. Cropped screenshots are added to it, and the
+	// originals kept for undo.
+	previewWindow.SetOnClosed(func() {
+		if err := session.Remove(); err != nil {
+			log.Printf("Failed to remove session directory: %v", err)
+		}
+	})
+
+	editor := edit.NewEditor(rec)
 
 	vbox := container.NewVBox()
 
@@ -166,22 +376,23 @@ func (rw *RecorderWindow) showImageEditor(steps []recorder.Step) {
 
 	wrapper := container.NewPadded(scrollContainer)
 
-	stepWidgets := make([]*fyne.Container, len(steps))
-
-	saveBtn := widget.NewButton("Save", func() {
+	// The preview stays open after an export, so the steps can be edited
+	// and exported again.
+	saveBtn := widget.NewButton("Export", func() {
 		if err := os.MkdirAll(filepath.Dir(rw.outputPath), 0755); err != nil {
 			dialog.ShowError(fmt.Errorf("failed to create output directory: %w", err), previewWindow)
 			return
 		}
 
+		rec := editor.Recording()
 		switch rw.settings.OutputFormat {
 		case "HTML":
-			if err := output.SaveHTML(steps, rw.outputPath); err != nil {
+			if err := output.SaveHTML(rec, rw.outputPath); err != nil {
 				dialog.ShowError(fmt.Errorf("failed to save HTML: %w", err), previewWindow)
 				return
 			}
 		case "PDF":
-			if err := output.SavePDF(steps, rw.outputPath); err != nil {
+			if err := output.SavePDF(rec, rw.outputPath); err != nil {
 				dialog.ShowError(fmt.Errorf("failed to save PDF: %w", err), previewWindow)
 				return
 			}
@@ -189,139 +400,274 @@ func (rw *RecorderWindow) showImageEditor(steps []recorder.Step) {
 			dialog.ShowError(fmt.Errorf("unsupported output format: %s", rw.settings.OutputFormat), previewWindow)
 			return
 		}
-		previewWindow.Close()
+		dialog.ShowInformation("Exported", fmt.Sprintf("Saved %d steps to %s", len(rec.Steps), rw.outputPath), previewWindow)
+		rw.updateOutputPath()
 	})
 
+	saveSessionBtn := widget.NewButton("Save Session", func() {
+		rw.saveBundle(editor.Recording(), previewWindow)
+	})
+
+	undoBtn := widget.NewButtonWithIcon("Undo", theme.ContentUndoIcon(), nil)
+	redoBtn := widget.NewButtonWithIcon("Redo", theme.ContentRedoIcon(), nil)
+
+	totalLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+
 	toolbar := container.NewHBox(
 		saveBtn,
+		saveSessionBtn,
+		undoBtn,
+		redoBtn,
 		layout.NewSpacer(),
-		widget.NewLabelWithStyle(
-			fmt.Sprintf("Total Steps: %d", len(steps)),
-			fyne.TextAlignLeading,
-			fyne.TextStyle{Bold: true},
-		),
+		totalLabel,
 	)
 
-	for i, step := range steps {
-		img := canvas.NewImageFromImage(step.Screenshot)
-		img.FillMode = canvas.ImageFillContain
-		img.SetMinSize(fyne.NewSize(400, 300))
-
-		imgContainer := container.NewHBox(layout.NewSpacer(), img, layout.NewSpacer())
-		imgContainer.Resize(fyne.NewSize(750, 525))
-
-		buttonSize := fyne.NewSize(100, 32)
-
-		deleteBtn := widget.NewButton("Delete", nil)
-		deleteBtn.Resize(buttonSize)
-
-		moveUpBtn := widget.NewButton("Move Up", nil)
-		moveUpBtn.Resize(buttonSize)
-
-		moveDownBtn := widget.NewButton("Move Down", nil)
-		moveDownBtn.Resize(buttonSize)
-
-		descLabel := widget.NewTextGrid()
-		descLabel.SetText(step.Description)
-
-		editBtn := widget.NewButton("Edit Description", nil)
-		editBtn.OnTapped = func() {
-			entry := widget.NewMultiLineEntry()
-			entry.SetText(descLabel.Text())
-			entry.SetPlaceHolder("Add description...")
-			entry.Wrapping = fyne.TextWrapWord
-			entry.Resize(fyne.NewSize(600, 400))
-
-			entryContainer := container.NewPadded(
-				container.NewScroll(entry),
-			)
-			entryContainer.Resize(fyne.NewSize(600, 400))
-
-			dialog := dialog.NewCustomConfirm("Edit Description", "Save", "Cancel",
-				entryContainer,
-				func(save bool) {
-					if save {
-						descLabel.SetText(entry.Text)
-						steps[i].Description = entry.Text
-					}
-				},
-				previewWindow,
-			)
-			dialog.Resize(fyne.NewSize(700, 500))
-			dialog.Show()
+	// Rows are kept by step ID and only rebuilt when their step changed, so
+	// reordering steps does not reload every screenshot.
+	rows := make(map[edit.ID]*stepRow)
+	var refresh func()
+	// apply shows the steps after an edit, or why it failed.
+	apply := func(err error) {
+		if err != nil {
+			dialog.ShowError(err, previewWindow)
 		}
+		refresh()
+	}
+	refresh = func() {
+		ids := editor.IDs()
+		objects := make([]fyne.CanvasObject, len(ids))
+		shown := make(map[edit.ID]*stepRow, len(ids))
+		for i, id := range ids {
+			row := rows[id]
+			if row == nil || row.rev != editor.Revision(id) {
+				row = rw.newStepRow(editor, id, session, previewWindow, apply)
+			}
+			row.number.SetText(fmt.Sprintf("Step %d", i+1))
+			if i == 0 {
+				row.moveUp.Disable()
+			} else {
+				row.moveUp.Enable()
+			}
+			if i == len(ids)-1 {
+				row.moveDown.Disable()
+			} else {
+				row.moveDown.Enable()
+			}
+			shown[id] = row
+			objects[i] = row.object
+		}
+		rows = shown
+		vbox.Objects = objects
+		vbox.Refresh()
 
-		descContainer := container.NewVBox(
-			descLabel,
-		)
+		totalLabel.SetText(fmt.Sprintf("Total Steps: %d", len(ids)))
+		if _, ok := editor.CanUndo(); ok {
+			undoBtn.Enable()
+		} else {
+			undoBtn.Disable()
+		}
+		if _, ok := editor.CanRedo(); ok {
+			redoBtn.Enable()
+		} else {
+			redoBtn.Disable()
+		}
+	}
 
-		controls := container.NewHBox(
-			deleteBtn,
-			moveUpBtn,
-			moveDownBtn,
-			editBtn,
-		)
+	undo := func() {
+		if editor.Undo() {
+			refresh()
+		}
+	}
+	redo := func() {
+		if editor.Redo() {
+			refresh()
+		}
+	}
+	undoBtn.OnTapped = undo
+	redoBtn.OnTapped = redo
+	previewWindow.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) { undo() })
+	previewWindow.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyY, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) { redo() })
 
-		stepContainer := container.NewVBox(
-			widget.NewLabelWithStyle(
-				fmt.Sprintf("Step %d", i+1),
-				fyne.TextAlignLeading,
-				fyne.TextStyle{Bold: true},
-			),
-			container.NewPadded(
-				container.NewVBox(
-					imgContainer,
-					container.NewPadded(descContainer),
-					controls,
-				),
-			),
-			widget.NewSeparator(),
+	refresh()
+
+	var top fyne.CanvasObject = toolbar
+	if notice != "" {
+		top = container.NewVBox(toolbar, widget.NewLabel(notice))
+	}
+	mainContainer := container.NewBorder(
+		top, nil, nil, nil,
+		wrapper,
+	)
+
+	previewWindow.SetContent(mainContainer)
+	previewWindow.Show()
+}
+
+// stepRow is the preview of one step, as it was at revision rev.
+type stepRow struct {
+	rev              int
+	object           fyne.CanvasObject
+	number           *widget.Label
+	moveUp, moveDown *widget.Button
+}
+
+// newStepRow builds the preview of the step with the given ID. Its buttons
+// make their edits through editor and pass the result to apply.
+func (rw *RecorderWindow) newStepRow(editor *edit.Editor, id edit.ID, session *store.Session, previewWindow fyne.Window, apply func(error)) *stepRow {
+	step, _ := editor.Step(id)
+
+	img := canvas.NewImageFromFile(step.Screenshot.Path())
+	if len(step.Annotations) > 0 || len(step.Redactions) > 0 || step.Cursor != nil {
+		img = canvas.NewImageFromImage(annotatedScreenshot(step))
+	}
+	img.FillMode = canvas.ImageFillContain
+	img.SetMinSize(fyne.NewSize(400, 300))
+
+	imgContainer := container.NewHBox(layout.NewSpacer(), img, layout.NewSpacer())
+	imgContainer.Resize(fyne.NewSize(750, 525))
+
+	buttonSize := fyne.NewSize(100, 32)
+
+	deleteBtn := widget.NewButton("Delete", func() {
+		apply(editor.Delete(id))
+	})
+	deleteBtn.Resize(buttonSize)
+
+	moveUpBtn := widget.NewButton("Move Up", func() {
+		apply(editor.Move(id, editor.Index(id)-1))
+	})
+	moveUpBtn.Resize(buttonSize)
+
+	moveDownBtn := widget.NewButton("Move Down", func() {
+		apply(editor.Move(id, editor.Index(id)+1))
+	})
+	moveDownBtn.Resize(buttonSize)
+
+	descLabel := widget.NewTextGrid()
+	descLabel.SetText(step.Description)
+
+	editBtn := widget.NewButton("Edit Description", func() {
+		entry := widget.NewMultiLineEntry()
+		entry.SetText(step.Description)
+		entry.SetPlaceHolder("Add description...")
+		entry.Wrapping = fyne.TextWrapWord
+		entry.Resize(fyne.NewSize(600, 400))
+
+		entryContainer := container.NewPadded(
+			container.NewScroll(entry),
 		)
-		stepWidgets[i] = stepContainer
-
-		deleteBtn.OnTapped = func() {
-			vbox.Remove(stepContainer)
-			newSteps := make([]recorder.Step, 0)
-			for _, s := range steps {
-				if s != step {
-					newSteps = append(newSteps, s)
+		entryContainer.Resize(fyne.NewSize(600, 400))
+
+		dialog := dialog.NewCustomConfirm("Edit Description", "Save", "Cancel",
+			entryContainer,
+			func(save bool) {
+				if save && entry.Text != step.Description {
+					apply(editor.SetDescription(id, entry.Text))
 				}
-			}
-			steps = newSteps
-		}
+			},
+			previewWindow,
+		)
+		dialog.Resize(fyne.NewSize(700, 500))
+		dialog.Show()
+	})
 
-		moveUpBtn.OnTapped = func() {
-			if i > 0 {
-				steps[i], steps[i-1] = steps[i-1], steps[i]
-				stepWidgets[i], stepWidgets[i-1] = stepWidgets[i-1], stepWidgets[i]
+	descContainer := container.NewVBox(
+		descLabel,
+	)
 
-				vbox.RemoveAll()
-				for _, widget := range stepWidgets {
-					vbox.Add(widget)
-				}
-			}
+	annotateBtn := widget.NewButton("Annotate / Redact", func() {
+		base, err := step.Screenshot.Load()
+		if err != nil {
+			dialog.ShowError(err, previewWindow)
+			return
 		}
+		showAnnotationEditor(rw.app, base, step.Annotations, step.Redactions, step.Cursor, rw.settings, func(annotations []annotation.Annotation, redactions []redact.Redaction, pointer *cursor.Layer) {
+			apply(editor.Annotate(id, annotations, redactions, pointer))
+		})
+	})
 
-		moveDownBtn.OnTapped = func() {
-			if i < len(steps)-1 {
-				steps[i], steps[i+1] = steps[i+1], steps[i]
-				stepWidgets[i], stepWidgets[i+1] = stepWidgets[i+1], stepWidgets[i]
-
-				vbox.RemoveAll()
-				for _, widget := range stepWidgets {
-					vbox.Add(widget)
-				}
-			}
+	cropBtn := widget.NewButton("Crop", func() {
+		bounds := step.Screenshot.Bounds()
+		entry := widget.NewEntry()
+		entry.SetText(config.Region{Width: bounds.Dx(), Height: bounds.Dy()}.String())
+		entry.Validator = func(text string) error {
+			_, err := config.ParseRegion(text)
+			return err
+		}
+		items := []*widget.FormItem{
+			widget.NewFormItem("Keep", entry),
 		}
+		items[0].HintText = fmt.Sprintf("x,y,width,height in pixels of the %dx%d screenshot", bounds.Dx(), bounds.Dy())
+		dialog.ShowForm("Crop Screenshot", "Crop", "Cancel", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			r, err := config.ParseRegion(entry.Text)
+			if err != nil {
+				dialog.ShowError(err, previewWindow)
+				return
+			}
+			apply(editor.Crop(id, image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height), session))
+		}, previewWindow)
+	})
+
+	controls := container.NewHBox(
+		deleteBtn,
+		moveUpBtn,
+		moveDownBtn,
+		editBtn,
+		annotateBtn,
+		cropBtn,
+	)
 
-		vbox.Add(stepContainer)
+	number := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	header := container.NewVBox()
+	if step.Paused > 0 {
+		header.Add(widget.NewLabelWithStyle(
+			fmt.Sprintf("Recording paused (%s)", step.Paused.Round(time.Second)),
+			fyne.TextAlignCenter,
+			fyne.TextStyle{Italic: true},
+		))
+	}
+	header.Add(number)
+	if !step.Window.IsZero() {
+		header.Add(widget.NewLabel(step.Window.String()))
 	}
 
-	mainContainer := container.NewBorder(
-		toolbar, nil, nil, nil,
-		wrapper,
+	stepContainer := container.NewVBox(
+		header,
+		container.NewPadded(
+			container.NewVBox(
+				imgContainer,
+				container.NewPadded(descContainer),
+				controls,
+			),
+		),
+		widget.NewSeparator(),
 	)
 
-	previewWindow.SetContent(mainContainer)
-	previewWindow.Show()
+	return &stepRow{
+		rev:      editor.Revision(id),
+		object:   stepContainer,
+		number:   number,
+		moveUp:   moveUpBtn,
+		moveDown: moveDownBtn,
+	}
+}
+
+// annotatedScreenshot returns the screenshot of step with its redactions
+// and annotations drawn on it, for the preview only; the stored screenshot
+// is unchanged.
+func annotatedScreenshot(step model.Step) image.Image {
+	base, err := step.Screenshot.Load()
+	if err != nil {
+		log.Printf("Failed to load screenshot: %v", err)
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+	img, err := annotation.Render(cursor.Render(redact.Apply(base, step.Redactions), step.Cursor), step.Annotations)
+	if err != nil {
+		log.Printf("Failed to draw annotations: %v", err)
+		return base
+	}
+	return img
 }
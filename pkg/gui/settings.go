@@ -4,21 +4,184 @@
 package gui
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/highlight"
 )
 
-func ShowSettingsDialog(window fyne.Window, settings *config.Settings) error {
+// ShowSettingsDialog lets the user change settings, and calls onClosed
+// once the dialog is closed.
+func ShowSettingsDialog(window fyne.Window, settings *config.Settings, onClosed func()) error {
+	keystrokes := widget.NewCheck("Capture keystrokes", func(on bool) {
+		settings.CaptureKeystrokes = on
+	})
+	keystrokes.SetChecked(settings.CaptureKeystrokes)
+
+	beforeClick := widget.NewCheck("Show the screen from just before each click", func(on bool) {
+		if on {
+			settings.FrameBufferInterval = config.FrameBufferInterval
+		} else {
+			settings.FrameBufferInterval = 0
+		}
+	})
+	beforeClick.SetChecked(settings.FrameBufferInterval > 0)
+
+	merge := widget.NewCheck("Merge clicks and scrolls that changed nothing", func(on bool) {
+		settings.MergeNoOpSteps = on
+	})
+	merge.SetChecked(settings.MergeNoOpSteps)
+
+	afterDelay := widget.NewEntry()
+	afterDelay.SetText(strconv.Itoa(settings.AfterFrameDelay))
+	afterDelay.Validator = func(text string) error {
+		ms, err := strconv.Atoi(text)
+		if err == nil && ms < 0 {
+			err = fmt.Errorf("the delay cannot be negative")
+		}
+		return err
+	}
+	afterDelay.OnChanged = func(text string) {
+		if ms, err := strconv.Atoi(text); err == nil && ms >= 0 {
+			settings.AfterFrameDelay = ms
+		}
+	}
+
+	afterClick := widget.NewCheck("Also capture each step after a delay (ms) and outline what changed", func(on bool) {
+		settings.CaptureAfterFrame = on
+		if on {
+			afterDelay.Enable()
+		} else {
+			afterDelay.Disable()
+		}
+	})
+	afterClick.SetChecked(settings.CaptureAfterFrame)
+	if !settings.CaptureAfterFrame {
+		afterDelay.Disable()
+	}
+
+	region := widget.NewEntry()
+	region.SetPlaceHolder("x,y,width,height")
+	if settings.CaptureRegion != (config.Region{}) {
+		region.SetText(settings.CaptureRegion.String())
+	}
+	region.Validator = func(text string) error {
+		_, err := config.ParseRegion(text)
+		return err
+	}
+	region.OnChanged = func(text string) {
+		if r, err := config.ParseRegion(text); err == nil {
+			settings.CaptureRegion = r
+		}
+	}
+
+	scopes := map[string]string{
+		"Whole display": config.ScopeDisplay,
+		"Active window": config.ScopeWindow,
+		"Fixed region":  config.ScopeRegion,
+		"All displays":  config.ScopeAllDisplays,
+	}
+	scope := widget.NewSelect([]string{"Whole display", "Active window", "Fixed region", "All displays"}, func(label string) {
+		settings.CaptureScope = scopes[label]
+		if settings.CaptureScope == config.ScopeRegion {
+			region.Enable()
+		} else {
+			region.Disable()
+		}
+	})
+	for label, value := range scopes {
+		if value == settings.CaptureScope {
+			scope.SetSelected(label)
+		}
+	}
+	if scope.Selected == "" {
+		scope.SetSelected("Whole display")
+	}
+
+	ownModes := map[string]string{
+		"Show":                    config.OwnWindowsShow,
+		"Mask":                    config.OwnWindowsMask,
+		"Hide (Windows 10 2004+)": config.OwnWindowsHide,
+	}
+	ownWindows := widget.NewSelect([]string{"Show", "Mask", "Hide (Windows 10 2004+)"}, func(label string) {
+		settings.OwnWindows = ownModes[label]
+	})
+	for label, value := range ownModes {
+		if value == settings.OwnWindows {
+			ownWindows.SetSelected(label)
+		}
+	}
+	if ownWindows.Selected == "" {
+		ownWindows.SetSelected("Mask")
+	}
+
+	style := widget.NewSelect(highlight.Styles(), func(name string) {
+		settings.HighlightStyle = name
+	})
+	style.SetSelected(settings.HighlightStyle)
+
+	showCursor := widget.NewCheck("Draw the mouse pointer into screenshots", func(on bool) {
+		settings.ShowCursor = on
+	})
+	showCursor.SetChecked(settings.ShowCursor)
+
+	// Title rules are edited one pattern per line; region rules from the
+	// settings file are kept as they are.
+	var titles []string
+	for _, rule := range settings.RedactionRules {
+		if rule.WindowTitle != "" {
+			titles = append(titles, rule.WindowTitle)
+		}
+	}
+	redactTitles := widget.NewMultiLineEntry()
+	redactTitles.SetPlaceHolder("One regular expression per line")
+	redactTitles.SetText(strings.Join(titles, "\n"))
+	redactTitles.OnChanged = func(text string) {
+		var rules []config.RedactionRule
+		for _, rule := range settings.RedactionRules {
+			if rule.WindowTitle == "" {
+				rules = append(rules, rule)
+			}
+		}
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				rules = append(rules, config.RedactionRule{WindowTitle: line})
+			}
+		}
+		settings.RedactionRules = rules
+	}
+
 	form := container.NewVBox(
 		widget.NewLabel("Output Settings"),
 		widget.NewLabel("Output directory: "+settings.OutputDir),
+		widget.NewSeparator(),
+		widget.NewLabel("Recording Settings"),
+		keystrokes,
+		beforeClick,
+		afterClick,
+		afterDelay,
+		merge,
+		widget.NewLabel("Capture"),
+		scope,
+		region,
+		widget.NewLabel("GoStep windows in screenshots"),
+		ownWindows,
+		widget.NewLabel("Highlight style"),
+		style,
+		showCursor,
+		widget.NewLabel("Redact windows whose title matches"),
+		redactTitles,
 	)
 
 	dlg := dialog.NewCustom("Settings", "Close", form, window)
-	dlg.Resize(fyne.NewSize(300, 150))
+	dlg.SetOnClosed(onClosed)
+	dlg.Resize(fyne.NewSize(360, 520))
 	dlg.Show()
 
 	return nil
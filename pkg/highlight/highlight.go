@@ -0,0 +1,153 @@
+// Package highlight draws the marks that show where a step happened on its
+// screenshot. Each style is a Renderer registered under its name, which is
+// what Settings.HighlightStyle refers to.
+package highlight
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Built-in styles.
+const (
+	// StyleCircle draws a ring around each target point.
+	StyleCircle = "circle"
+	// StyleHalo draws a soft filled disc that fades out from each point.
+	StyleHalo = "halo"
+	// StyleArrow draws an arrow pointing at each point.
+	StyleArrow = "arrow"
+	// StyleWindow draws a rectangle around the target window, or a ring
+	// when the window is unknown.
+	StyleWindow = "window"
+	// StyleSpotlight dims everything but a disc around each point.
+	StyleSpotlight = "spotlight"
+)
+
+// Options control how a highlight is drawn. Size and Stroke are given in
+// logical pixels at 96 DPI and multiplied by Scale when drawing.
+type Options struct {
+	Color color.NRGBA `json:"color"`
+	// Size is the radius of rings and discs, and the length of arrows.
+	Size   int `json:"size"`
+	Stroke int `json:"stroke"`
+	// Opacity, from 0 to 1, is applied on top of the alpha of Color.
+	Opacity float64 `json:"opacity"`
+	// Scale is the ratio of physical to logical pixels of the display.
+	Scale float64 `json:"scale"`
+}
+
+// Target is what a highlight marks, in the coordinates of the image it is
+// drawn on.
+type Target struct {
+	Points []image.Point
+	// Window is the bounds of the window the step happened in, or empty
+	// if it is unknown.
+	Window image.Rectangle
+}
+
+// Renderer draws one highlight style.
+type Renderer interface {
+	Render(dst draw.Image, t Target, opts Options)
+}
+
+var (
+	mu        sync.RWMutex
+	renderers = map[string]Renderer{
+		StyleCircle:    circle{},
+		StyleHalo:      halo{},
+		StyleArrow:     arrow{},
+		StyleWindow:    windowFrame{},
+		StyleSpotlight: spotlight{},
+	}
+)
+
+// Register makes a renderer available under style, replacing any renderer
+// registered under the same name.
+func Register(style string, r Renderer) {
+	mu.Lock()
+	defer mu.Unlock()
+	renderers[style] = r
+}
+
+// Lookup returns the renderer registered under style.
+func Lookup(style string) (Renderer, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := renderers[style]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q", style)
+	}
+	return r, nil
+}
+
+// Styles returns the names of the registered styles in sorted order.
+func Styles() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	styles := make([]string, 0, len(renderers))
+	for style := range renderers {
+		styles = append(styles, style)
+	}
+	sort.Strings(styles)
+	return styles
+}
+
+// Draw renders t onto img with the given style and returns the result.
+// img is drawn on directly when it is an *image.RGBA; other images are
+// copied first.
+func Draw(img image.Image, style string, t Target, opts Options) (image.Image, error) {
+	r, err := Lookup(style)
+	if err != nil {
+		return img, err
+	}
+	dst, ok := img.(*image.RGBA)
+	if !ok {
+		dst = image.NewRGBA(img.Bounds())
+		draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	r.Render(dst, t, opts)
+	return dst, nil
+}
+
+// ParseColor parses a colour written as "#rrggbb" or "#rrggbbaa".
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("colour %q is not #rrggbb or #rrggbbaa", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("colour %q is not #rrggbb or #rrggbbaa", s)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// scaled returns a logical length in physical pixels, never less than one.
+func (o Options) scaled(v int) float64 {
+	scale := o.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	return max(float64(v)*scale, 1)
+}
+
+// source returns the uniform colour to draw with, with the opacity
+// applied.
+func (o Options) source() *image.Uniform {
+	c := o.Color
+	c.A = uint8(float64(c.A)*clamp(o.Opacity) + 0.5)
+	return image.NewUniform(c)
+}
+
+func clamp(v float64) float64 {
+	return min(max(v, 0), 1)
+}
@@ -0,0 +1,169 @@
+package highlight
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var (
+	white = color.RGBA{255, 255, 255, 255}
+	red   = color.NRGBA{R: 255, A: 255}
+)
+
+// render draws style for t on a white 300x300 image.
+func render(t *testing.T, style string, target Target, opts Options) *image.RGBA {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	draw.Draw(img, img.Bounds(), image.NewUniform(white), image.Point{}, draw.Src)
+	out, err := Draw(img, style, target, opts)
+	if err != nil {
+		t.Fatalf("Draw(%q): %v", style, err)
+	}
+	return out.(*image.RGBA)
+}
+
+// changed returns the smallest rectangle holding every pixel of img that
+// is no longer white.
+func changed(img *image.RGBA) image.Rectangle {
+	var r image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.RGBAAt(x, y) != white {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestRenderAtTarget(t *testing.T) {
+	opts := Options{Color: red, Size: 10, Stroke: 2, Opacity: 1, Scale: 1}
+	at := image.Pt(150, 120)
+	window := image.Rect(40, 50, 220, 180)
+
+	tests := []struct {
+		style  string
+		target Target
+		// within is where the marker has to stay.
+		within image.Rectangle
+	}{
+		{StyleCircle, Target{Points: []image.Point{at}}, image.Rect(137, 107, 164, 134)},
+		{StyleHalo, Target{Points: []image.Point{at}}, image.Rect(140, 110, 161, 131)},
+		// The arrow points at the target from below and to the right.
+		{StyleArrow, Target{Points: []image.Point{at}}, image.Rect(151, 121, 180, 150)},
+		{StyleWindow, Target{Points: []image.Point{at}, Window: window}, window},
+		{StyleWindow, Target{Points: []image.Point{at}}, image.Rect(137, 107, 164, 134)},
+	}
+	for _, tt := range tests {
+		img := render(t, tt.style, tt.target, opts)
+		got := changed(img)
+		if got.Empty() {
+			t.Errorf("%s: nothing drawn", tt.style)
+			continue
+		}
+		if !got.In(tt.within) {
+			t.Errorf("%s: drawn over %v, want within %v", tt.style, got, tt.within)
+		}
+	}
+}
+
+func TestRenderPoints(t *testing.T) {
+	opts := Options{Color: red, Size: 10, Stroke: 2, Opacity: 1, Scale: 1}
+	img := render(t, StyleCircle, Target{Points: []image.Point{{50, 50}, {200, 200}}}, opts)
+	for _, p := range []image.Point{{50 + 10, 50}, {200, 200 - 10}} {
+		if img.RGBAAt(p.X, p.Y) == white {
+			t.Errorf("no ring through %v", p)
+		}
+	}
+	if c := img.RGBAAt(125, 125); c != white {
+		t.Errorf("pixel between the targets is %v, want it unchanged", c)
+	}
+}
+
+func TestRenderScale(t *testing.T) {
+	at := image.Pt(150, 150)
+	for _, style := range []string{StyleCircle, StyleHalo, StyleArrow} {
+		var sizes [2]image.Point
+		for i, scale := range []float64{1, 2} {
+			opts := Options{Color: red, Size: 10, Stroke: 2, Opacity: 1, Scale: scale}
+			sizes[i] = changed(render(t, style, Target{Points: []image.Point{at}}, opts)).Size()
+		}
+		// Antialiasing adds a pixel or two at the edges.
+		for _, d := range []int{sizes[1].X - 2*sizes[0].X, sizes[1].Y - 2*sizes[0].Y} {
+			if d < -3 || d > 3 {
+				t.Errorf("%s: %v at 2x, want about twice %v at 1x", style, sizes[1], sizes[0])
+				break
+			}
+		}
+	}
+
+	// A ring of size 10 passes 20 physical pixels from the target at 2x.
+	opts := Options{Color: red, Size: 10, Stroke: 2, Opacity: 1, Scale: 2}
+	img := render(t, StyleCircle, Target{Points: []image.Point{at}}, opts)
+	if img.RGBAAt(at.X+20, at.Y) == white || img.RGBAAt(at.X+10, at.Y) != white {
+		t.Errorf("ring at 2x does not pass 20 pixels from the target")
+	}
+}
+
+func TestRenderOpacity(t *testing.T) {
+	at := image.Pt(150, 150)
+	ringAt := image.Pt(at.X+10, at.Y)
+	solid := render(t, StyleCircle, Target{Points: []image.Point{at}}, Options{Color: red, Size: 10, Stroke: 2, Opacity: 1, Scale: 1})
+	if c := solid.RGBAAt(ringAt.X, ringAt.Y); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("opaque ring is %v, want red", c)
+	}
+	faint := render(t, StyleCircle, Target{Points: []image.Point{at}}, Options{Color: red, Size: 10, Stroke: 2, Opacity: 0.5, Scale: 1})
+	if c := faint.RGBAAt(ringAt.X, ringAt.Y); c.R != 255 || c.G < 120 || c.G > 135 {
+		t.Errorf("half opaque ring is %v, want red blended half way with white", c)
+	}
+	none := render(t, StyleCircle, Target{Points: []image.Point{at}}, Options{Color: red, Size: 10, Stroke: 2, Opacity: 0, Scale: 1})
+	if got := changed(none); !got.Empty() {
+		t.Errorf("transparent ring drawn over %v", got)
+	}
+}
+
+func TestRenderSpotlight(t *testing.T) {
+	at := image.Pt(150, 150)
+	opts := Options{Color: red, Size: 10, Stroke: 2, Opacity: 1, Scale: 1}
+	img := render(t, StyleSpotlight, Target{Points: []image.Point{at}}, opts)
+
+	if c := img.RGBAAt(at.X, at.Y); c != white {
+		t.Errorf("target is %v, want it left as it was", c)
+	}
+	const dim = 255 * (1 - spotlightDim)
+	for _, p := range []image.Point{{0, 0}, {299, 299}, {at.X + 40, at.Y}} {
+		c := img.RGBAAt(p.X, p.Y)
+		if d := float64(c.R) - dim; d < -1.5 || d > 1.5 || c.R != c.G || c.G != c.B {
+			t.Errorf("pixel at %v is %v, want it dimmed to grey %.0f", p, c, dim)
+		}
+	}
+}
+
+func TestDrawUnknownStyle(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	if _, err := Draw(img, "sparkles", Target{}, Options{}); err == nil {
+		t.Error("Draw succeeded with an unknown style")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.NRGBA
+		ok   bool
+	}{
+		{"#ff0000", color.NRGBA{R: 255, A: 255}, true},
+		{"00ff0080", color.NRGBA{G: 255, A: 128}, true},
+		{"#12345", color.NRGBA{}, false},
+		{"#gg0000", color.NRGBA{}, false},
+		{"", color.NRGBA{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
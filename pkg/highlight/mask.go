@@ -0,0 +1,124 @@
+package highlight
+
+import (
+	"image"
+	"math"
+)
+
+// coverage returns how much of the pixel whose centre is at (x, y) a shape
+// covers, from 0 to 1.
+type coverage func(x, y float64) float64
+
+// rasterize samples a shape over bounds into an alpha mask. Drawing a
+// uniform colour through an *image.Alpha mask takes the fast path of
+// image/draw.
+func rasterize(bounds image.Rectangle, shape coverage) *image.Alpha {
+	mask := image.NewAlpha(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if a := shape(float64(x)+0.5, float64(y)+0.5); a > 0 {
+				mask.Pix[mask.PixOffset(x, y)] = uint8(clamp(a)*255 + 0.5)
+			}
+		}
+	}
+	return mask
+}
+
+type vec struct{ x, y float64 }
+
+func pt(p image.Point) vec {
+	return vec{float64(p.X) + 0.5, float64(p.Y) + 0.5}
+}
+
+func (v vec) add(w vec) vec     { return vec{v.x + w.x, v.y + w.y} }
+func (v vec) sub(w vec) vec     { return vec{v.x - w.x, v.y - w.y} }
+func (v vec) mul(k float64) vec { return vec{v.x * k, v.y * k} }
+func (v vec) dot(w vec) float64 { return v.x*w.x + v.y*w.y }
+func (v vec) length() float64   { return math.Hypot(v.x, v.y) }
+func (v vec) perp() vec         { return vec{-v.y, v.x} }
+func (v vec) dist(x, y float64) float64 {
+	return math.Hypot(x-v.x, y-v.y)
+}
+
+// around returns the pixels within r of c.
+func around(c vec, r float64) image.Rectangle {
+	return image.Rect(
+		int(math.Floor(c.x-r)), int(math.Floor(c.y-r)),
+		int(math.Ceil(c.x+r))+1, int(math.Ceil(c.y+r))+1,
+	)
+}
+
+// ring covers a circle of radius r drawn with a line width w.
+func ring(c vec, r, w float64) coverage {
+	return func(x, y float64) float64 {
+		return w/2 + 0.5 - math.Abs(c.dist(x, y)-r)
+	}
+}
+
+// disc covers a filled circle of radius r.
+func disc(c vec, r float64) coverage {
+	return func(x, y float64) float64 {
+		return r + 0.5 - c.dist(x, y)
+	}
+}
+
+// glow covers a disc of radius r that fades out smoothly from its centre.
+func glow(c vec, r float64) coverage {
+	return func(x, y float64) float64 {
+		t := clamp(1 - c.dist(x, y)/r)
+		return t * t * (3 - 2*t)
+	}
+}
+
+// segment covers a line from a to b of width w with round ends.
+func segment(a, b vec, w float64) coverage {
+	ab := b.sub(a)
+	l2 := ab.dot(ab)
+	return func(x, y float64) float64 {
+		p := vec{x, y}
+		t := 0.0
+		if l2 > 0 {
+			t = clamp(p.sub(a).dot(ab) / l2)
+		}
+		return w/2 + 0.5 - p.sub(a.add(ab.mul(t))).length()
+	}
+}
+
+// triangle covers the triangle with the given corners, in either winding.
+func triangle(a, b, c vec) coverage {
+	corners := [3]vec{a, b, c}
+	// Orient the edges so that the inside is on the positive side.
+	sign := 1.0
+	if b.sub(a).perp().dot(c.sub(a)) < 0 {
+		sign = -1
+	}
+	return func(x, y float64) float64 {
+		p := vec{x, y}
+		inside := math.Inf(1)
+		for i, from := range corners {
+			to := corners[(i+1)%3]
+			edge := to.sub(from)
+			d := sign * edge.perp().dot(p.sub(from)) / edge.length()
+			inside = min(inside, d)
+		}
+		return inside + 0.5
+	}
+}
+
+// union covers every pixel covered by any of shapes.
+func union(shapes ...coverage) coverage {
+	return func(x, y float64) float64 {
+		a := 0.0
+		for _, shape := range shapes {
+			a = max(a, shape(x, y))
+		}
+		return a
+	}
+}
+
+// invert covers every pixel shape does not.
+func invert(shape coverage) coverage {
+	return func(x, y float64) float64 {
+		return 1 - clamp(shape(x, y))
+	}
+}
@@ -0,0 +1,50 @@
+package highlight
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// The shapes below draw single marks with the colour, stroke, opacity and
+// scale of opts. Annotations use them to draw marks placed by hand.
+
+// DrawArrow draws an arrow from tail to tip.
+func DrawArrow(dst draw.Image, tail, tip image.Point, opts Options) {
+	drawArrow(dst, pt(tail), pt(tip), opts)
+}
+
+// DrawLine draws a line from a to b.
+func DrawLine(dst draw.Image, a, b image.Point, opts Options) {
+	w := opts.scaled(opts.Stroke)
+	from, to := pt(a), pt(b)
+	fill(dst, around(from.add(to).mul(0.5), to.sub(from).length()/2+w), segment(from, to, w), opts.source())
+}
+
+// DrawDisc fills a circle with a radius of opts.Size around c.
+func DrawDisc(dst draw.Image, c image.Point, opts Options) {
+	r := opts.scaled(opts.Size)
+	fill(dst, around(pt(c), r), disc(pt(c), r), opts.source())
+}
+
+// DrawFrame outlines r. The frame is drawn inside r, so it stays visible
+// when r fills the image.
+func DrawFrame(dst draw.Image, r image.Rectangle, opts Options) {
+	w := int(math.Round(opts.scaled(opts.Stroke)))
+	r = r.Canon().Intersect(dst.Bounds())
+	edges := []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w),
+		image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y+w, r.Min.X+w, r.Max.Y-w),
+		image.Rect(r.Max.X-w, r.Min.Y+w, r.Max.X, r.Max.Y-w),
+	}
+	src := opts.source()
+	for _, edge := range edges {
+		draw.Draw(dst, edge.Intersect(r), src, image.Point{}, draw.Over)
+	}
+}
+
+// FillRect fills r with the colour of opts.
+func FillRect(dst draw.Image, r image.Rectangle, opts Options) {
+	draw.Draw(dst, r.Canon(), opts.source(), image.Point{}, draw.Over)
+}
@@ -0,0 +1,135 @@
+package highlight
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+const (
+	// haloOpacity keeps the halo translucent at full opacity, so the
+	// clicked element shows through it.
+	haloOpacity = 0.5
+	// spotlightDim is how dark the spotlight makes the rest of the
+	// screenshot at full opacity.
+	spotlightDim = 0.6
+)
+
+type circle struct{}
+
+func (circle) Render(dst draw.Image, t Target, opts Options) {
+	r, w := opts.scaled(opts.Size), opts.scaled(opts.Stroke)
+	for _, p := range t.Points {
+		c := pt(p)
+		fill(dst, around(c, r+w), ring(c, r, w), opts.source())
+	}
+}
+
+type halo struct{}
+
+func (halo) Render(dst draw.Image, t Target, opts Options) {
+	r := opts.scaled(opts.Size)
+	opts.Opacity *= haloOpacity
+	for _, p := range t.Points {
+		c := pt(p)
+		fill(dst, around(c, r), glow(c, r), opts.source())
+	}
+}
+
+// arrow points at each target from below and to the right. Arrows that
+// would leave the image point from another corner instead.
+type arrow struct{}
+
+func (arrow) Render(dst draw.Image, t Target, opts Options) {
+	// Arrows are three times as long as rings are wide so they stand out
+	// at the same size setting.
+	length, gap := 3*opts.scaled(opts.Size), opts.scaled(opts.Stroke)
+
+	for _, p := range t.Points {
+		tip := pt(p)
+		dir := arrowDirection(tip, length+gap, dst.Bounds())
+		tip = tip.add(dir.mul(gap))
+		drawArrow(dst, tip.add(dir.mul(length)), tip, opts)
+	}
+}
+
+// drawArrow draws an arrow from tail to tip. The head grows with the
+// stroke but never takes up more than half of a short arrow.
+func drawArrow(dst draw.Image, tail, tip vec, opts Options) {
+	length := tail.sub(tip).length()
+	if length == 0 {
+		return
+	}
+	w := opts.scaled(opts.Stroke)
+	head := min(5*w, length/2)
+	dir := tail.sub(tip).mul(1 / length)
+	base := tip.add(dir.mul(head))
+	side := dir.perp().mul(head * 0.4)
+
+	shape := union(
+		triangle(tip, base.add(side), base.sub(side)),
+		segment(base, tail, w),
+	)
+	fill(dst, around(tip.add(tail).mul(0.5), length/2+head), shape, opts.source())
+}
+
+// arrowDirection returns the unit vector from the tip towards the tail of
+// an arrow at tip, preferring the lower right.
+func arrowDirection(tip vec, length float64, bounds image.Rectangle) vec {
+	diagonals := []vec{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}}
+	for _, d := range diagonals {
+		d = d.mul(1 / math.Sqrt2)
+		tail := tip.add(d.mul(length))
+		if image.Pt(int(tail.x), int(tail.y)).In(bounds) {
+			return d
+		}
+	}
+	return diagonals[0].mul(1 / math.Sqrt2)
+}
+
+// windowFrame outlines the target window, or falls back to circles when
+// the window is unknown.
+type windowFrame struct{}
+
+func (windowFrame) Render(dst draw.Image, t Target, opts Options) {
+	if t.Window.Empty() {
+		circle{}.Render(dst, t, opts)
+		return
+	}
+
+	DrawFrame(dst, t.Window, opts)
+}
+
+// spotlight dims everything outside a disc twice the highlight size
+// around each target, and rings the discs.
+type spotlight struct{}
+
+func (spotlight) Render(dst draw.Image, t Target, opts Options) {
+	if len(t.Points) == 0 {
+		return
+	}
+	r, w := 2*opts.scaled(opts.Size), opts.scaled(opts.Stroke)
+
+	holes := make([]coverage, len(t.Points))
+	for i, p := range t.Points {
+		holes[i] = disc(pt(p), r)
+	}
+	shade := image.NewUniform(color.NRGBA{A: uint8(255*spotlightDim*clamp(opts.Opacity) + 0.5)})
+	fill(dst, dst.Bounds(), invert(union(holes...)), shade)
+
+	for _, p := range t.Points {
+		c := pt(p)
+		fill(dst, around(c, r+w), ring(c, r, w), opts.source())
+	}
+}
+
+// fill draws src through the coverage of shape over bounds.
+func fill(dst draw.Image, bounds image.Rectangle, shape coverage, src image.Image) {
+	bounds = bounds.Intersect(dst.Bounds())
+	if bounds.Empty() {
+		return
+	}
+	mask := rasterize(bounds, shape)
+	draw.DrawMask(dst, bounds, src, image.Point{}, mask, bounds.Min, draw.Over)
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// SchemaVersion is the version of the JSON written by MarshalRecording
+// and MarshalStep.
+const SchemaVersion = 1
+
+// Namer returns the name a screenshot is written under, such as its file
+// in a session directory or in a bundle.
+type Namer func(img *store.Image) string
+
+// Opener returns the screenshot written under name.
+type Opener func(name string) (*store.Image, error)
+
+// stepJSON is a step as written, with its screenshots named.
+type stepJSON struct {
+	Step
+	Screenshot      string `json:"screenshot"`
+	AfterScreenshot string `json:"after_screenshot,omitempty"`
+}
+
+type recordingJSON struct {
+	Version int `json:"version"`
+	Recording
+	Steps []stepJSON `json:"steps"`
+}
+
+func encodeStep(step Step, name Namer) stepJSON {
+	s := stepJSON{Step: step, Screenshot: name(step.Screenshot)}
+	if step.AfterScreenshot != nil {
+		s.AfterScreenshot = name(step.AfterScreenshot)
+	}
+	return s
+}
+
+func decodeStep(s stepJSON, open Opener) (Step, error) {
+	step := s.Step
+	var err error
+	if step.Screenshot, err = open(s.Screenshot); err != nil {
+		return Step{}, err
+	}
+	if s.AfterScreenshot != "" {
+		if step.AfterScreenshot, err = open(s.AfterScreenshot); err != nil {
+			return Step{}, err
+		}
+	}
+	return step, nil
+}
+
+// MarshalStep encodes step on its own, as in the autosave journal. Every
+// step must have a screenshot.
+func MarshalStep(step Step, name Namer) ([]byte, error) {
+	if step.Screenshot == nil {
+		return nil, fmt.Errorf("step has no screenshot")
+	}
+	return json.Marshal(encodeStep(step, name))
+}
+
+// UnmarshalStep decodes a step encoded by MarshalStep.
+func UnmarshalStep(data []byte, open Opener) (Step, error) {
+	var s stepJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Step{}, err
+	}
+	return decodeStep(s, open)
+}
+
+// MarshalRecording encodes rec with the current SchemaVersion.
+func MarshalRecording(rec *Recording, name Namer) ([]byte, error) {
+	r := recordingJSON{Version: SchemaVersion, Recording: *rec, Steps: make([]stepJSON, len(rec.Steps))}
+	for i, step := range rec.Steps {
+		if step.Screenshot == nil {
+			return nil, fmt.Errorf("step %d has no screenshot", i+1)
+		}
+		r.Steps[i] = encodeStep(step, name)
+	}
+	return json.MarshalIndent(r, "", "    ")
+}
+
+// UnmarshalRecording decodes a recording encoded by MarshalRecording. It
+// fails on a version newer than SchemaVersion, whose fields it would
+// silently drop.
+func UnmarshalRecording(data []byte, open Opener) (*Recording, error) {
+	var r recordingJSON
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if err := CheckVersion(r.Version); err != nil {
+		return nil, err
+	}
+
+	rec := r.Recording
+	rec.Steps = make([]Step, len(r.Steps))
+	for i, s := range r.Steps {
+		step, err := decodeStep(s, open)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		rec.Steps[i] = step
+	}
+	return &rec, nil
+}
+
+// CheckVersion reports whether data of the given schema version can be
+// read.
+func CheckVersion(version int) error {
+	switch {
+	case version <= 0:
+		return fmt.Errorf("not a GoStep recording")
+	case version > SchemaVersion:
+		return fmt.Errorf("saved by a newer GoStep (version %d, this version reads up to %d)", version, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// images is a set of stored screenshots known by name, standing in for a
+// session directory or a bundle.
+type images map[string]*store.Image
+
+func newImages(t *testing.T, names ...string) images {
+	t.Helper()
+	session, err := store.NewSession(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(images)
+	for i, name := range names {
+		img, err := session.Put(image.NewRGBA(image.Rect(0, 0, i+1, i+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[name] = img
+	}
+	return m
+}
+
+func (m images) name(img *store.Image) string {
+	for name, i := range m {
+		if i == img {
+			return name
+		}
+	}
+	return ""
+}
+
+func (m images) open(name string) (*store.Image, error) {
+	img, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("no image %q", name)
+	}
+	return img, nil
+}
+
+func testRecording(imgs images) *Recording {
+	started := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	return &Recording{
+		Started: started,
+		Ended:   started.Add(time.Minute),
+		Capture: Capture{
+			Scope:           "region",
+			Region:          image.Rect(-100, 0, 500, 400),
+			Keystrokes:      true,
+			HighlightStyle:  "halo",
+			OwnWindows:      "mask",
+			AfterFrameDelay: 500 * time.Millisecond,
+			MergeThreshold:  -1,
+		},
+		Steps: []Step{
+			{
+				Screenshot:  imgs["a.png"],
+				Description: "Click OK",
+				Timestamp:   started.Add(time.Second),
+				Action:      "Mouse Click",
+				Coordinates: image.Pt(10, 20),
+				Window:      Window{Title: "Dialog", PID: 7},
+				Redactions:  []redact.Redaction{{Rect: image.Rect(0, 0, 5, 5), Method: redact.Pixelate}},
+			},
+			{
+				Screenshot:      imgs["b.png"],
+				AfterScreenshot: imgs["c.png"],
+				Description:     "Type 'x'",
+				Timestamp:       started.Add(2 * time.Second),
+				Paused:          time.Second,
+			},
+		},
+	}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	imgs := newImages(t, "a.png", "b.png", "c.png")
+	rec := testRecording(imgs)
+	data, err := MarshalRecording(rec, imgs.name)
+	if err != nil {
+		t.Fatalf("MarshalRecording: %v", err)
+	}
+	got, err := UnmarshalRecording(data, imgs.open)
+	if err != nil {
+		t.Fatalf("UnmarshalRecording: %v", err)
+	}
+	if !reflect.DeepEqual(got, rec) {
+		t.Errorf("round trip = %+v, want %+v", got, rec)
+	}
+}
+
+func TestStepRoundTrip(t *testing.T) {
+	imgs := newImages(t, "a.png", "b.png", "c.png")
+	for i, step := range testRecording(imgs).Steps {
+		data, err := MarshalStep(step, imgs.name)
+		if err != nil {
+			t.Fatalf("step %d: MarshalStep: %v", i+1, err)
+		}
+		got, err := UnmarshalStep(data, imgs.open)
+		if err != nil {
+			t.Fatalf("step %d: UnmarshalStep: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, step) {
+			t.Errorf("step %d: round trip = %+v, want %+v", i+1, got, step)
+		}
+	}
+}
+
+func TestMarshalMissingImage(t *testing.T) {
+	imgs := newImages(t, "a.png", "b.png", "c.png")
+	rec := testRecording(imgs)
+	rec.Steps[1].Screenshot = nil
+	if _, err := MarshalRecording(rec, imgs.name); err == nil || !strings.Contains(err.Error(), "step 2") {
+		t.Errorf("MarshalRecording error = %v, want one naming step 2", err)
+	}
+	if _, err := MarshalStep(rec.Steps[1], imgs.name); err == nil {
+		t.Error("MarshalStep succeeded without a screenshot")
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	imgs := newImages(t, "a.png", "b.png", "c.png")
+	data, err := MarshalRecording(testRecording(imgs), imgs.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// withVersion returns data with its version replaced.
+	withVersion := func(version int) []byte {
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		m["version"] = version
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	// without opens every screenshot except the one named.
+	without := func(missing string) Opener {
+		return func(name string) (*store.Image, error) {
+			if name == missing {
+				return nil, fmt.Errorf("no image %q", name)
+			}
+			return imgs.open(name)
+		}
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		open Opener
+		want string
+	}{
+		{"not JSON", []byte("nope"), imgs.open, "invalid character"},
+		{"no version", withVersion(0), imgs.open, "not a GoStep recording"},
+		{"newer version", withVersion(SchemaVersion + 1), imgs.open, "newer GoStep"},
+		{"missing screenshot", data, without("b.png"), `step 2: no image "b.png"`},
+		{"missing after screenshot", data, without("c.png"), `step 2: no image "c.png"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnmarshalRecording(tt.data, tt.open)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("UnmarshalRecording error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	step, err := MarshalStep(testRecording(imgs).Steps[1], imgs.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, missing := range []string{"b.png", "c.png"} {
+		if _, err := UnmarshalStep(step, without(missing)); err == nil {
+			t.Errorf("UnmarshalStep succeeded without %s", missing)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version int
+		ok      bool
+	}{
+		{-1, false},
+		{0, false},
+		{1, true},
+		{SchemaVersion, true},
+		{SchemaVersion + 1, false},
+	} {
+		if err := CheckVersion(tt.version); (err == nil) != tt.ok {
+			t.Errorf("CheckVersion(%d) = %v, want ok %v", tt.version, err, tt.ok)
+		}
+	}
+}
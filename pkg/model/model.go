@@ -0,0 +1,147 @@
+// Package model is the recording as every part of GoStep sees it: the
+// recorder fills it in, the editor changes it, and the exporters, bundles
+// and the autosave journal read and write it. New step data is added here
+// once and reaches all of them.
+package model
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/diff"
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// Recording is a recorded session: its steps in order, when it was
+// recorded and how.
+type Recording struct {
+	// Started and Ended are when the recording started and stopped. Ended
+	// is zero for a recording that never stopped, such as one recovered
+	// after a crash.
+	Started time.Time `json:"started"`
+	Ended   time.Time `json:"ended"`
+	// Capture is how the steps were captured. It is zero for a recording
+	// recovered after a crash, whose journal only holds the steps.
+	Capture Capture `json:"capture"`
+	Steps   []Step  `json:"-"`
+}
+
+// Capture is the settings a recording was made with that decide which
+// steps it has and what their screenshots show.
+type Capture struct {
+	// Scope is the capture scope, and Region the part of the virtual
+	// desktop captured when it is "region".
+	Scope  string          `json:"scope"`
+	Region image.Rectangle `json:"region"`
+	// Keystrokes is set when typing and shortcuts were recorded.
+	Keystrokes bool `json:"keystrokes"`
+	// Cursor is set when the pointer was recorded with each step.
+	Cursor bool `json:"cursor"`
+	// HighlightStyle is the style clicks were marked in.
+	HighlightStyle string `json:"highlight_style"`
+	// OwnWindows is how GoStep's windows appear in the screenshots.
+	OwnWindows string `json:"own_windows"`
+	// FrameBuffer is how often the screen was buffered to show it from
+	// just before each press, or zero if screenshots were taken after it.
+	FrameBuffer time.Duration `json:"frame_buffer,omitempty"`
+	// AfterFrameDelay is how long after each step its after screenshot
+	// was taken, or zero if none were.
+	AfterFrameDelay time.Duration `json:"after_frame_delay,omitempty"`
+	// MergeThreshold is the hash distance up to which steps that changed
+	// nothing were merged, or negative if every step was kept.
+	MergeThreshold int `json:"merge_threshold"`
+	// Redacted is set when redaction rules were applied while recording.
+	Redacted bool `json:"redacted"`
+}
+
+// Step is one recorded action with its screenshot.
+type Step struct {
+	Screenshot *store.Image `json:"-"`
+	// AfterScreenshot shows CaptureArea again once the screen settled
+	// after the step. It is nil unless after frames are enabled.
+	AfterScreenshot *store.Image `json:"-"`
+	Description     string       `json:"description"`
+	Timestamp       time.Time    `json:"timestamp"`
+	Action          string       `json:"action"`
+	// Coordinates are in physical pixels of the virtual desktop, the same
+	// pixels as the screenshot.
+	Coordinates image.Point `json:"coordinates"`
+	// EndCoordinates is where a drag was released. It is only set for
+	// drag steps.
+	EndCoordinates image.Point `json:"end_coordinates"`
+	// LogicalCoordinates and LogicalEndCoordinates are the same positions
+	// in logical pixels of the display they are on, as an application
+	// that is not DPI aware sees them.
+	LogicalCoordinates    image.Point `json:"logical_coordinates"`
+	LogicalEndCoordinates image.Point `json:"logical_end_coordinates"`
+	// Scale is the scale factor of the display the step happened on.
+	Scale       float64 `json:"scale"`
+	Highlighted bool    `json:"highlighted"`
+	// Paused is how long the recording was paused between the previous
+	// step and this one, or zero if it was not.
+	Paused time.Duration `json:"paused,omitempty"`
+	// Window is the window that was active when the step happened.
+	Window Window `json:"window"`
+	// CaptureArea is the part of the virtual desktop the screenshot shows.
+	// Subtract its Min from Coordinates to find them in the screenshot.
+	CaptureArea image.Rectangle `json:"capture_area"`
+	// Annotations are drawn over the screenshot when the step is
+	// exported; Screenshot itself is kept as captured.
+	Annotations []annotation.Annotation `json:"annotations,omitempty"`
+	// Redactions are applied to the screenshot, before the annotations,
+	// when the step is exported.
+	Redactions []redact.Redaction `json:"redactions,omitempty"`
+	// AfterRedactions are what the redaction rules call for in
+	// AfterScreenshot, which can show windows the screenshot does not.
+	// Redactions are applied to it as well.
+	AfterRedactions []redact.Redaction `json:"after_redactions,omitempty"`
+	// Cursor is the pointer, drawn between the redactions and the
+	// annotations when the step is exported. It is nil unless the pointer
+	// is recorded.
+	Cursor *cursor.Layer `json:"cursor,omitempty"`
+	// Changes are the areas of the screenshot that differ in
+	// AfterScreenshot, in screenshot pixels.
+	Changes []image.Rectangle `json:"changes,omitempty"`
+
+	// Hash is the perceptual hash of Screenshot, used to find steps that
+	// changed nothing. It is only kept while recording.
+	Hash diff.Hash `json:"-"`
+}
+
+// Window describes the active top-level window when a step was recorded.
+type Window struct {
+	Title string `json:"title,omitempty"`
+	// Process is the executable name of the owning process, without its
+	// directory.
+	Process string `json:"process,omitempty"`
+	PID     int    `json:"pid,omitempty"`
+	// Bounds is the window frame in virtual desktop coordinates.
+	Bounds image.Rectangle `json:"bounds"`
+}
+
+// String describes the window in one line, e.g.
+// "Untitled - Notepad (notepad.exe, PID 1234)".
+func (w Window) String() string {
+	title := w.Title
+	if title == "" {
+		title = "Untitled window"
+	}
+	switch {
+	case w.Process != "" && w.PID != 0:
+		return fmt.Sprintf("%s (%s, PID %d)", title, w.Process, w.PID)
+	case w.PID != 0:
+		return fmt.Sprintf("%s (PID %d)", title, w.PID)
+	case w.Process != "":
+		return fmt.Sprintf("%s (%s)", title, w.Process)
+	}
+	return title
+}
+
+// IsZero reports whether nothing is known about the window.
+func (w Window) IsZero() bool {
+	return w == Window{}
+}
@@ -4,12 +4,12 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
-	"image/png"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/gustaf/go-test/pkg/recorder"
+	"github.com/gustaf/go-test/pkg/model"
 )
 
 const htmlTemplate = `
@@ -35,12 +35,24 @@ const htmlTemplate = `
         .step-header {
             margin-bottom: 10px;
         }
+        .window {
+            color: #666;
+            font-size: 0.9em;
+        }
         .description {
             margin-top: 10px;
             padding: 10px;
             background-color: #e3f2fd;
             border-radius: 4px;
         }
+        .paused {
+            margin-bottom: 20px;
+            padding: 8px;
+            text-align: center;
+            color: #666;
+            border-top: 1px dashed #bbb;
+            border-bottom: 1px dashed #bbb;
+        }
         .screenshot {
             max-width: 100%;
             height: auto;
@@ -48,30 +60,67 @@ const htmlTemplate = `
             border-radius: 4px;
             margin-top: 10px;
         }
+        .pair {
+            display: flex;
+            gap: 10px;
+        }
+        .pair figure {
+            flex: 1;
+            margin: 0;
+        }
+        .pair figcaption {
+            color: #666;
+            font-size: 0.9em;
+        }
     </style>
 </head>
 <body>
     <h1>Step Recording - {{.Timestamp}}</h1>
     {{range .Steps}}
+    {{if .PausedFor}}
+    <div class="paused">Recording paused ({{.PausedFor}})</div>
+    {{end}}
     <div class="step">
+        <div class="step-header">
+            <strong>Step {{.Number}}</strong>{{if .Action}} &mdash; {{.Action}}{{end}}
+            {{if .WindowDetails}}
+            <div class="window">{{.WindowDetails}}</div>
+            {{end}}
+        </div>
         {{if .Description}}
         <div class="description">
             {{.Description}}
         </div>
         {{end}}
+        {{if .AfterPath}}
+        <div class="pair">
+            <figure>
+                <img class="screenshot" src="{{.ImagePath}}" alt="Screenshot before">
+                <figcaption>Before</figcaption>
+            </figure>
+            <figure>
+                <img class="screenshot" src="{{.AfterPath}}" alt="Screenshot after">
+                <figcaption>After{{if .ChangeSummary}} ({{.ChangeSummary}}){{end}}</figcaption>
+            </figure>
+        </div>
+        {{else}}
         <img class="screenshot" src="{{.ImagePath}}" alt="Screenshot">
+        {{end}}
     </div>
     {{end}}
 </body>
 </html>
 `
 
+// htmlStep is a step with what the template shows about it worked out.
 type htmlStep struct {
-	Timestamp   time.Time
-	Action      string
-	Description string
-	Coordinates struct{ X, Y int }
-	ImagePath   string
+	model.Step
+	Number        int
+	ImagePath     string
+	AfterPath     string
+	ChangeSummary string
+	PausedFor     string
+	WindowDetails string
 }
 
 type htmlData struct {
@@ -80,7 +129,8 @@ type htmlData struct {
 }
 
 // SaveHTML saves the recording as an HTML file
-func SaveHTML(steps []recorder.Step, outputPath string) error {
+func SaveHTML(rec *model.Recording, outputPath string) error {
+	steps := rec.Steps
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
@@ -92,30 +142,34 @@ func SaveHTML(steps []recorder.Step, outputPath string) error {
 	}
 
 	data := htmlData{
-		Timestamp: time.Now(),
+		Timestamp: recordedAt(rec),
 		Steps:     make([]htmlStep, len(steps)),
 	}
 
 	for i, step := range steps {
 		imgPath := filepath.Join("images", fmt.Sprintf("step_%d.png", i+1))
-		imgFile, err := os.Create(filepath.Join(outputDir, imgPath))
-		if err != nil {
-			return fmt.Errorf("failed to create image file: %w", err)
+		if err := writeImage(openScreenshot, step, filepath.Join(outputDir, imgPath)); err != nil {
+			return err
 		}
-		if err := png.Encode(imgFile, step.Screenshot); err != nil {
-			imgFile.Close()
-			return fmt.Errorf("failed to encode image: %w", err)
+		var afterPath string
+		if step.AfterScreenshot != nil {
+			afterPath = filepath.Join("images", fmt.Sprintf("step_%d_after.png", i+1))
+			if err := writeImage(openAfterScreenshot, step, filepath.Join(outputDir, afterPath)); err != nil {
+				return err
+			}
 		}
-		imgFile.Close()
 
 		data.Steps[i] = htmlStep{
-			Timestamp:   step.Timestamp,
-			Action:      step.Action,
-			Description: step.Description,
-			ImagePath:   imgPath,
+			Step:          step,
+			Number:        i + 1,
+			ImagePath:     imgPath,
+			AfterPath:     afterPath,
+			ChangeSummary: changeSummary(step.Changes),
+			WindowDetails: windowDetails(step.Window),
+		}
+		if step.Paused > 0 {
+			data.Steps[i].PausedFor = pauseLength(step.Paused)
 		}
-		data.Steps[i].Coordinates.X = step.Coordinates.X
-		data.Steps[i].Coordinates.Y = step.Coordinates.Y
 	}
 
 	tmpl, err := template.New("report").Parse(htmlTemplate)
@@ -134,3 +188,22 @@ func SaveHTML(steps []recorder.Step, outputPath string) error {
 
 	return nil
 }
+
+// writeImage writes the image of step that open returns to path.
+func writeImage(open func(model.Step) (io.ReadCloser, error), step model.Step, path string) error {
+	src, err := open(step)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create image file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	return dst.Close()
+}
@@ -3,108 +3,110 @@ package output
 import (
 	"bytes"
 	"fmt"
-	"html/template"
+	"image"
+	"image/color"
 	"image/png"
-	"os"
-	"path/filepath"
+	"io"
+	"slices"
 	"time"
 
-	"github.com/jung-kurt/gofpdf"
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/highlight"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+	"github.com/gustaf/go-test/pkg/store"
 )
 
-type Step struct {
-	Screenshot  []byte
-	Description string
-	Timestamp   time.Time
-	Action      string
+// recordedAt returns when rec was recorded, for the title of a report, or
+// the current time if that is unknown.
+func recordedAt(rec *model.Recording) time.Time {
+	if rec.Started.IsZero() {
+		return time.Now()
+	}
+	return rec.Started
 }
 
-func SaveAsHTML(steps []Step, outputPath string) error {
-	const htmlTemplate = `
-<!DOCTYPE html>
-<html>
-<head>
-    <title>Step Recording Report</title>
-    <style>
-        body { font-family: Arial, sans-serif; margin: 20px; }
-        .step { margin-bottom: 30px; border-bottom: 1px solid #ccc; padding-bottom: 20px; }
-        .timestamp { color: #666; font-size: 0.9em; }
-        .description { margin: 10px 0; }
-        img { max-width: 100%; border: 1px solid #ddd; margin-top: 10px; }
-    </style>
-</head>
-<body>
-    <h1>Step Recording Report</h1>
-    {{range .Steps}}
-    <div class="step">
-        <div class="timestamp">{{.Timestamp.Format "2006-01-02 15:04:05"}}</div>
-        <div class="description">{{.Description}}</div>
-        <img src="data:image/png;base64,{{.Screenshot}}" alt="Screenshot">
-    </div>
-    {{end}}
-</body>
-</html>`
-
-	tmpl, err := template.New("report").Parse(htmlTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse template: %v", err)
+// pauseLength formats how long a recording was paused, to the second.
+func pauseLength(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Second {
+		return "less than a second"
 	}
+	return d.String()
+}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+// windowDetails describes the window a step happened in, including where
+// it was on screen, or returns "" if the window is unknown.
+func windowDetails(w model.Window) string {
+	if w.IsZero() {
+		return ""
 	}
-	defer file.Close()
-
-	data := struct {
-		Steps []Step
-	}{
-		Steps: steps,
+	details := w.String()
+	if !w.Bounds.Empty() {
+		details += fmt.Sprintf(", %dx%d at (%d, %d)", w.Bounds.Dx(), w.Bounds.Dy(), w.Bounds.Min.X, w.Bounds.Min.Y)
 	}
+	return details
+}
 
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute template: %v", err)
+// changeSummary describes how many regions changed in a step's after
+// frame, or returns "" if none did.
+func changeSummary(changes []image.Rectangle) string {
+	switch len(changes) {
+	case 0:
+		return ""
+	case 1:
+		return "1 region changed"
 	}
-
-	return nil
+	return fmt.Sprintf("%d regions changed", len(changes))
 }
 
-func SaveAsPDF(steps []Step, outputPath string) error {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-
-	for i, step := range steps {
-		pdf.AddPage()
-
-		pdf.SetFont("Arial", "B", 12)
-		pdf.Cell(0, 10, fmt.Sprintf("Step %d - %s", i+1, step.Timestamp.Format("2006-01-02 15:04:05")))
-		pdf.Ln(10)
-
-		pdf.SetFont("Arial", "", 10)
-		pdf.MultiCell(0, 10, step.Description, "", "", false)
-		pdf.Ln(5)
+// openScreenshot returns the PNG encoded screenshot of step as it should
+// appear in a report. A step without redactions, pointer or annotations is
+// read straight from the store; otherwise the redactions, the pointer and
+// then the annotations are drawn onto a decoded copy, so the redacted
+// pixels never reach the report.
+func openScreenshot(step model.Step) (io.ReadCloser, error) {
+	return renderImage(step.Screenshot, step.Redactions, step.Cursor, step.Annotations)
+}
 
-		imgReader := bytes.NewReader(step.Screenshot)
-		img, err := png.Decode(imgReader)
-		if err != nil {
-			return fmt.Errorf("failed to decode image for step %d: %v", i+1, err)
-		}
+// changeOutline is how the changed regions are outlined on after frames.
+var changeOutline = highlight.Options{
+	Color:   color.NRGBA{R: 255, G: 152, B: 0, A: 255},
+	Stroke:  2,
+	Opacity: 1,
+}
 
-		tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("step_%d.png", i))
-		f, err := os.Create(tmpFile)
-		if err != nil {
-			return fmt.Errorf("failed to create temporary file: %v", err)
-		}
+// openAfterScreenshot returns the PNG encoded after frame of step, with the
+// step's redactions and its own applied and the regions that changed
+// outlined. The step must have an after frame.
+func openAfterScreenshot(step model.Step) (io.ReadCloser, error) {
+	var outlines []annotation.Annotation
+	for _, r := range step.Changes {
+		outlines = append(outlines, annotation.Annotation{Kind: annotation.KindRect, Rect: r, Options: changeOutline})
+	}
+	redactions := append(slices.Clip(step.Redactions), step.AfterRedactions...)
+	return renderImage(step.AfterScreenshot, redactions, nil, outlines)
+}
 
-		if err := png.Encode(f, img); err != nil {
-			f.Close()
-			os.Remove(tmpFile)
-			return fmt.Errorf("failed to encode image: %v", err)
-		}
-		f.Close()
+func renderImage(stored *store.Image, redactions []redact.Redaction, pointer *cursor.Layer, annotations []annotation.Annotation) (io.ReadCloser, error) {
+	if len(redactions) == 0 && pointer == nil && len(annotations) == 0 {
+		return stored.Open()
+	}
 
-		pdf.Image(tmpFile, 10, pdf.GetY(), 190, 0, false, "", 0, "")
-		os.Remove(tmpFile)
+	img, err := stored.Load()
+	if err != nil {
+		return nil, err
+	}
+	img = cursor.Render(redact.Apply(img, redactions), pointer)
+	img, err = annotation.Render(img, annotations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to draw annotations: %w", err)
 	}
 
-	return pdf.OutputFileAndClose(outputPath)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+	return io.NopCloser(&buf), nil
 }
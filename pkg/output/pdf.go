@@ -1,16 +1,15 @@
 package output
 
 import (
-	"bytes"
 	"fmt"
-	"image/png"
-	"time"
+	"io"
 
-	"github.com/gustaf/go-test/pkg/recorder"
+	"github.com/gustaf/go-test/pkg/model"
 	"github.com/jung-kurt/gofpdf"
 )
 
-func SavePDF(steps []recorder.Step, outputPath string) error {
+func SavePDF(rec *model.Recording, outputPath string) error {
+	steps := rec.Steps
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.SetMargins(10, 10, 10)
 	pdf.SetAutoPageBreak(true, 15)
@@ -20,14 +19,33 @@ func SavePDF(steps []recorder.Step, outputPath string) error {
 	pdf.Cell(190, 10, "Step Recording")
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(190, 10, time.Now().Format("2006-01-02 15:04:05"))
+	pdf.Cell(190, 10, recordedAt(rec).Format("2006-01-02 15:04:05"))
 
 	for i, step := range steps {
 		pdf.AddPage()
+		if step.Paused > 0 {
+			pdf.SetFont("Arial", "I", 10)
+			pdf.SetTextColor(102, 102, 102)
+			pdf.CellFormat(190, 8, fmt.Sprintf("Recording paused (%s)", pauseLength(step.Paused)), "B", 1, "C", false, 0, "")
+			pdf.SetTextColor(0, 0, 0)
+			pdf.Ln(2)
+		}
 		pdf.SetFont("Arial", "B", 14)
-		pdf.Cell(190, 10, fmt.Sprintf("Step %d", i+1))
+		title := fmt.Sprintf("Step %d", i+1)
+		if step.Action != "" {
+			title = fmt.Sprintf("Step %d - %s", i+1, step.Action)
+		}
+		pdf.Cell(190, 10, title)
 		pdf.Ln(10)
 
+		if window := windowDetails(step.Window); window != "" {
+			pdf.SetFont("Arial", "", 10)
+			pdf.SetTextColor(102, 102, 102)
+			pdf.MultiCell(190, 5, window, "", "L", false)
+			pdf.SetTextColor(0, 0, 0)
+			pdf.Ln(2)
+		}
+
 		if step.Description != "" {
 			pdf.SetFillColor(227, 242, 253) // Light blue background
 			pdf.Rect(10, pdf.GetY(), 190, 10, "F")
@@ -35,26 +53,54 @@ func SavePDF(steps []recorder.Step, outputPath string) error {
 			pdf.Ln(12)
 		}
 
-		var buf bytes.Buffer
-		if err := png.Encode(&buf, step.Screenshot); err != nil {
-			return fmt.Errorf("failed to encode screenshot: %w", err)
-		}
-
-		imgOpts := gofpdf.ImageOptions{
-			ImageType: "PNG",
-		}
-
 		// Calculate image dimensions to fit page width while maintaining aspect ratio
-		pageWidth := 190.0 // mm
 		imgWidth := float64(step.Screenshot.Bounds().Dx())
 		imgHeight := float64(step.Screenshot.Bounds().Dy())
 		ratio := imgHeight / imgWidth
-		width := pageWidth
-		height := pageWidth * ratio
 
-		pdf.RegisterImageOptionsReader(fmt.Sprintf("img%d", i), imgOpts, &buf)
-		pdf.Image(fmt.Sprintf("img%d", i), 10, pdf.GetY(), width, height, false, "", 0, "")
+		if step.AfterScreenshot == nil {
+			if err := placeImage(pdf, fmt.Sprintf("img%d", i), openScreenshot, step, 10, 190, ratio); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Before and after side by side, each half the page width.
+		const half = 93.0
+		top := pdf.GetY()
+		if err := placeImage(pdf, fmt.Sprintf("img%d", i), openScreenshot, step, 10, half, ratio); err != nil {
+			return err
+		}
+		pdf.SetY(top)
+		if err := placeImage(pdf, fmt.Sprintf("img%d_after", i), openAfterScreenshot, step, 10+190-half, half, ratio); err != nil {
+			return err
+		}
+
+		after := "After"
+		if changes := changeSummary(step.Changes); changes != "" {
+			after += " (" + changes + ")"
+		}
+		pdf.SetY(top + half*ratio + 1)
+		pdf.SetFont("Arial", "", 9)
+		pdf.SetTextColor(102, 102, 102)
+		pdf.CellFormat(half, 5, "Before", "", 0, "L", false, 0, "")
+		pdf.SetX(10 + 190 - half)
+		pdf.CellFormat(half, 5, after, "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
 	}
 
 	return pdf.OutputFileAndClose(outputPath)
 }
+
+// placeImage draws the image of step that open returns at x on the
+// current line, width mm wide and ratio times as high.
+func placeImage(pdf *gofpdf.Fpdf, name string, open func(model.Step) (io.ReadCloser, error), step model.Step, x, width, ratio float64) error {
+	shot, err := open(step)
+	if err != nil {
+		return err
+	}
+	pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, shot)
+	shot.Close()
+	pdf.Image(name, x, pdf.GetY(), width, width*ratio, false, "", 0, "")
+	return nil
+}
@@ -0,0 +1,406 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/diff"
+	"github.com/gustaf/go-test/pkg/highlight"
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+const (
+	// captureWorkers take screenshots; encodeWorkers highlight and store
+	// them. Capturing has to keep up with the input, encoding only has to
+	// finish before the recording is handed over.
+	captureWorkers = 2
+	// captureQueueSize bounds the screenshots waiting for a capture
+	// worker. Events beyond it are dropped rather than delaying the ones
+	// already queued even further.
+	captureQueueSize = 16
+	encodeQueueSize  = 64
+	// lateCapture is how long after its event a capture may start before
+	// it is counted as late.
+	lateCapture = 250 * time.Millisecond
+)
+
+// CaptureStats counts input that could not be captured as it happened.
+type CaptureStats struct {
+	// Dropped events found the capture queue, or the input source's own
+	// queue, full and produced no step.
+	Dropped int
+	// Late captures started more than lateCapture after their event, so
+	// the screenshot may show a later state of the screen.
+	Late int
+	// Failed captures could not take or store a screenshot.
+	Failed int
+}
+
+// dropCounter is implemented by input sources that drop events rather
+// than hold up the platform when the recorder falls behind.
+type dropCounter interface {
+	// Dropped returns how many events were dropped since Start.
+	Dropped() int
+}
+
+// shot is a screenshot requested by the event loop and taken by a capture
+// worker. ready is closed once img and bounds are set, or the capture
+// failed and ok is false. A zero window is filled in with the active
+// window just before the screenshot is taken. Shots with a buffered frame
+// are cut from it instead of captured; after asks for a second screenshot
+// once the screen has settled after the step.
+type shot struct {
+	pos    image.Point
+	time   time.Time
+	window model.Window
+	frame  *frame
+	after  bool
+	ready  chan struct{}
+
+	img    image.Image
+	bounds image.Rectangle
+	// windows are the windows on screen when img was taken, for the title
+	// redaction rules; they are only listed when there are such rules.
+	windows []model.Window
+	layout  layout
+	scale   float64
+	cursor  *cursor.Layer
+	ok      bool
+}
+
+// markFunc returns the annotations of a step once its screenshot s has
+// been taken. s.bounds is the area of the virtual desktop the screenshot
+// covers, so a desktop position p is at p.Sub(s.bounds.Min) in it.
+type markFunc func(s *shot) []annotation.Annotation
+
+// captureQueues connects the event loop to the worker pools of one
+// recording.
+type captureQueues struct {
+	shots   chan *shot
+	encodes chan func()
+
+	captureWG sync.WaitGroup
+	encodeWG  sync.WaitGroup
+	// afterWG counts the after frames still waiting for the screen.
+	afterWG sync.WaitGroup
+}
+
+func (r *Recorder) startWorkers() *captureQueues {
+	q := &captureQueues{
+		shots:   make(chan *shot, captureQueueSize),
+		encodes: make(chan func(), encodeQueueSize),
+	}
+
+	for i := 0; i < captureWorkers; i++ {
+		q.captureWG.Add(1)
+		go func() {
+			defer q.captureWG.Done()
+			for s := range q.shots {
+				r.takeShot(s)
+			}
+		}()
+	}
+
+	for i := 0; i < min(runtime.NumCPU(), 4); i++ {
+		q.encodeWG.Add(1)
+		go func() {
+			defer q.encodeWG.Done()
+			for job := range q.encodes {
+				job()
+			}
+		}()
+	}
+	return q
+}
+
+// drain waits for every queued screenshot and after frame to be taken and
+// stored. Encode jobs and after frames wait on their shots, so the capture
+// workers are stopped last.
+func (q *captureQueues) drain() {
+	close(q.encodes)
+	q.encodeWG.Wait()
+	q.afterWG.Wait()
+	close(q.shots)
+	q.captureWG.Wait()
+}
+
+// String summarises the statistics for the user, or returns "" if every
+// event was captured on time.
+func (s CaptureStats) String() string {
+	var parts []string
+	if s.Dropped > 0 {
+		parts = append(parts, fmt.Sprintf("%d events dropped", s.Dropped))
+	}
+	if s.Late > 0 {
+		parts = append(parts, fmt.Sprintf("%d captured late", s.Late))
+	}
+	if s.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", s.Failed))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// requestShot queues a screenshot for an event at pos that happened at t.
+// It returns nil if the queue is full.
+func (r *Recorder) requestShot(pos image.Point, t time.Time) *shot {
+	return r.requestShotIn(model.Window{}, pos, t)
+}
+
+// requestShotIn is requestShot for an event in a known window, which is
+// used instead of the window active when the screenshot is taken.
+func (r *Recorder) requestShotIn(win model.Window, pos image.Point, t time.Time) *shot {
+	return r.queueShot(&shot{pos: pos, time: t, window: win, after: r.afterFrames})
+}
+
+// requestPressShot is requestShot for a button press. The frame buffered
+// just before t is used when there is one, so the screenshot does not show
+// the effect of the press itself.
+func (r *Recorder) requestPressShot(pos image.Point, t time.Time) *shot {
+	return r.queueShot(&shot{pos: pos, time: t, frame: r.frameBefore(t), after: r.afterFrames})
+}
+
+func (r *Recorder) queueShot(s *shot) *shot {
+	s.ready = make(chan struct{})
+	select {
+	case r.queues.shots <- s:
+		return s
+	default:
+		r.countStat(func(st *CaptureStats) { st.Dropped++ })
+		log.Printf("Capture queue full, dropping event at (%d, %d)", s.pos.X, s.pos.Y)
+		return nil
+	}
+}
+
+func (r *Recorder) takeShot(s *shot) {
+	defer close(s.ready)
+
+	// A buffered frame shows the screen before the event, however late
+	// the shot is taken.
+	if s.frame == nil && time.Since(s.time) > lateCapture {
+		r.countStat(func(st *CaptureStats) { st.Late++ })
+	}
+	if s.window.IsZero() {
+		s.window = r.activeWindow()
+	}
+	var hidden bool
+	var shape *cursor.Shape
+	if s.frame != nil {
+		hidden = s.frame.hidden
+		s.windows = s.frame.windows
+		shape = s.frame.cursor
+		s.img, s.bounds, s.ok = r.cropFrame(s.frame, s.pos, s.window)
+	} else {
+		hidden = r.hideOwnWindows()
+		s.windows = r.visibleWindows()
+		s.img, s.bounds, s.ok = r.capture(s.pos, s.window)
+		if s.ok && r.showCursor {
+			shape = r.sampleCursor()
+		}
+	}
+	if s.ok {
+		s.img = r.coverOwnWindows(s.img, s.bounds, hidden)
+		s.layout = r.displayLayout()
+		s.scale = s.layout.scaleAt(s.pos, s.bounds)
+		if r.showCursor {
+			s.cursor = r.cursorLayer(s, shape)
+		}
+	} else {
+		r.countStat(func(st *CaptureStats) { st.Failed++ })
+	}
+}
+
+// appendStep adds step to the recording and returns its index. Any pause
+// since the previous step is marked on it. Its screenshot, capture area,
+// window, rule redactions and the annotations from mark are filled in by
+// an encode worker once s has been taken; a nil mark leaves the step
+// unannotated. The after frame, if s asked for one, is added by
+// storeAfterFrame. Steps whose screenshot cannot be taken are removed when
+// the recording stops. It must run on the event loop: a new step ends the
+// chance of the last click becoming a double click, and the wait for the
+// previous step's after frame.
+func (r *Recorder) appendStep(step model.Step, s *shot, mark markFunc) int {
+	r.lastClick = nil
+	r.beginStep()
+
+	r.mu.Lock()
+	step.Paused = r.pauseGap
+	r.pauseGap = 0
+	r.steps = append(r.steps, step)
+	index := len(r.steps) - 1
+	r.mu.Unlock()
+
+	if s.after {
+		next := r.stepBegun
+		r.queues.afterWG.Add(1)
+		go func() {
+			defer r.queues.afterWG.Done()
+			r.storeAfterFrame(index, s, next)
+		}()
+	}
+
+	r.queues.encodes <- func() {
+		<-s.ready
+		if !s.ok {
+			return
+		}
+
+		stored, err := r.session.Put(s.img)
+		if err != nil {
+			log.Printf("Failed to store screenshot: %v", err)
+			r.countStat(func(st *CaptureStats) { st.Failed++ })
+			return
+		}
+		hash := diff.NewHash(s.img)
+
+		r.mu.Lock()
+		r.steps[index].Screenshot = stored
+		r.steps[index].Hash = hash
+		r.steps[index].CaptureArea = s.bounds
+		r.steps[index].Window = s.window
+		r.steps[index].Scale = s.scale
+		r.steps[index].LogicalCoordinates = s.layout.logical(r.steps[index].Coordinates)
+		if r.steps[index].Action == ActionDrag {
+			r.steps[index].LogicalEndCoordinates = s.layout.logical(r.steps[index].EndCoordinates)
+		}
+		r.steps[index].Redactions = r.ruleRedactions(s.bounds, s.window, s.windows)
+		r.steps[index].Cursor = s.cursor
+		if mark != nil {
+			r.steps[index].Annotations = mark(s)
+		}
+		r.journalStep(index)
+		r.mu.Unlock()
+	}
+	return index
+}
+
+// beginStep ends the wait for the after frame of the last step, since the
+// input for a new one has arrived.
+func (r *Recorder) beginStep() {
+	close(r.stepBegun)
+	r.stepBegun = make(chan struct{})
+}
+
+// storeAfterFrame captures the after frame of the step at index, whose
+// shot is s, and stores it with the regions that changed since s was taken
+// and the redactions the rules call for in it. It waits for the screen to
+// settle from afterDelay after the step's event, but no longer than until
+// next is closed, when the next step begins. A step without it is still
+// recorded.
+func (r *Recorder) storeAfterFrame(index int, s *shot, next <-chan struct{}) {
+	<-s.ready
+	if !s.ok {
+		return
+	}
+	img, windows := r.captureSettled(s.bounds, s.time.Add(r.afterDelay), next)
+	if img == nil {
+		return
+	}
+	stored, err := r.session.Put(img)
+	if err != nil {
+		log.Printf("Failed to store after frame: %v", err)
+		return
+	}
+	changes := diff.Regions(s.img, img)
+	redactions := r.ruleRedactions(s.bounds, model.Window{}, windows)
+
+	r.mu.Lock()
+	r.steps[index].AfterScreenshot = stored
+	r.steps[index].Changes = changes
+	r.steps[index].AfterRedactions = redactions
+	r.journalStep(index)
+	r.mu.Unlock()
+}
+
+// dropIncompleteSteps removes the steps whose screenshot never arrived.
+// It must only run once the workers have drained.
+func (r *Recorder) dropIncompleteSteps() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.steps[:0]
+	for _, step := range r.steps {
+		if step.Screenshot != nil {
+			kept = append(kept, step)
+		}
+	}
+	r.steps = kept
+}
+
+// Stats returns the capture statistics of the current or last recording.
+func (r *Recorder) Stats() CaptureStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+func (r *Recorder) countStat(update func(*CaptureStats)) {
+	r.mu.Lock()
+	update(&r.stats)
+	r.mu.Unlock()
+}
+
+// highlightStyle is the highlight configured for a recording.
+type highlightStyle struct {
+	name string
+	opts highlight.Options
+}
+
+func parseHighlight(settings *config.Settings) (highlightStyle, error) {
+	if _, err := highlight.Lookup(settings.HighlightStyle); err != nil {
+		return highlightStyle{}, err
+	}
+	c, err := highlight.ParseColor(settings.HighlightColor)
+	if err != nil {
+		return highlightStyle{}, fmt.Errorf("invalid highlight colour: %w", err)
+	}
+	return highlightStyle{
+		name: settings.HighlightStyle,
+		opts: highlight.Options{
+			Color:   c,
+			Size:    settings.HighlightSize,
+			Stroke:  settings.HighlightStroke,
+			Opacity: settings.HighlightOpacity,
+		},
+	}, nil
+}
+
+// markClicks marks the given virtual desktop positions with click markers
+// in the configured highlight style, scaled for the display the step
+// happened on. Positions outside the screenshot are left out.
+func (r *Recorder) markClicks(points ...image.Point) markFunc {
+	style := r.highlight
+	return func(s *shot) []annotation.Annotation {
+		window := s.window.Bounds.Sub(s.bounds.Min)
+		opts := style.opts
+		opts.Scale = s.scale
+
+		var marks []annotation.Annotation
+		for _, p := range points {
+			local := p.Sub(s.bounds.Min)
+			if local.In(s.img.Bounds()) {
+				marks = append(marks, annotation.Click(local, window, style.name, opts))
+			}
+		}
+		return marks
+	}
+}
+
+// markDrag marks where a drag started and draws an arrow to where it
+// ended.
+func (r *Recorder) markDrag(from, to image.Point) markFunc {
+	click := r.markClicks(from)
+	return func(s *shot) []annotation.Annotation {
+		marks := click(s)
+		opts := r.highlight.opts
+		opts.Scale = s.scale
+		return append(marks, annotation.Arrow(from.Sub(s.bounds.Min), to.Sub(s.bounds.Min), opts))
+	}
+}
@@ -0,0 +1,22 @@
+package recorder
+
+import "time"
+
+// eventClock turns event timestamps in milliseconds since an arbitrary
+// point, as the X server and GetTickCount give them, into wall clock time.
+// The first timestamp seen is taken to be now; later ones are placed
+// relative to it, so events keep the spacing the system gave them however
+// late they are read.
+type eventClock struct {
+	base time.Time
+	ms   uint32
+	set  bool
+}
+
+func (c *eventClock) at(ms uint32) time.Time {
+	if !c.set {
+		c.base, c.ms, c.set = time.Now(), ms, true
+	}
+	// The subtraction wraps like the 32-bit clock does.
+	return c.base.Add(time.Duration(int32(ms-c.ms)) * time.Millisecond)
+}
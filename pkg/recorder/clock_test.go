@@ -0,0 +1,26 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventClock(t *testing.T) {
+	var c eventClock
+	base := c.at(0xfffff000)
+	tests := []struct {
+		server uint32
+		want   time.Duration
+	}{
+		{0xfffff000, 0},
+		{0xfffff000 + 1500, 1500 * time.Millisecond},
+		// The clock wraps after 49 days.
+		{0x00000100, 4352 * time.Millisecond},
+		{0xfffff000 - 20, -20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := c.at(tt.server).Sub(base); got != tt.want {
+			t.Errorf("at(%#x) = base%+v, want base%+v", tt.server, got, tt.want)
+		}
+	}
+}
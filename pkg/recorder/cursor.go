@@ -0,0 +1,48 @@
+package recorder
+
+import (
+	"log"
+
+	"github.com/gustaf/go-test/pkg/cursor"
+)
+
+// cursorReader is implemented by screen capturers that can report the
+// shape of the pointer. The others get the bundled arrow.
+type cursorReader interface {
+	Cursor() (cursor.Shape, error)
+}
+
+// sampleCursor reads the shape of the pointer. It is called right after
+// each capture, so the shape is the one on screen when the capture was
+// taken, even when the screenshot is cut from a buffered frame later. It
+// returns nil if the shape cannot be read.
+func (r *Recorder) sampleCursor() *cursor.Shape {
+	reader, ok := r.backend.Screen.(cursorReader)
+	if !ok {
+		return nil
+	}
+	shape, err := reader.Cursor()
+	if err != nil {
+		if !r.cursorFailed.Swap(true) {
+			log.Printf("Failed to read the pointer shape, drawing an arrow instead: %v", err)
+		}
+		return nil
+	}
+	return &shape
+}
+
+// cursorLayer returns the pointer to draw over the screenshot of s, at the
+// position of its event, or nil if that is outside the screenshot. shape
+// is the pointer sampled with the capture; the bundled arrow stands in
+// when it is nil.
+func (r *Recorder) cursorLayer(s *shot, shape *cursor.Shape) *cursor.Layer {
+	at := s.pos.Sub(s.bounds.Min)
+	if !at.In(s.img.Bounds()) {
+		return nil
+	}
+	if shape == nil {
+		arrow := cursor.Arrow(s.scale)
+		shape = &arrow
+	}
+	return &cursor.Layer{Shape: *shape, At: at}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xfixes"
+)
+
+// xfixesConn connects to the X server and sets up XFixes once. Cursor
+// images need version 2 of the extension.
+var xfixesConn = sync.OnceValues(func() (*xgb.Conn, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+	if err := xfixes.Init(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("XFixes extension not available: %w", err)
+	}
+	if _, err := xfixes.QueryVersion(conn, 2, 0).Reply(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to query XFixes version: %w", err)
+	}
+	return conn, nil
+})
+
+// Cursor returns the current pointer image from XFixes.
+func (displayCapturer) Cursor() (cursor.Shape, error) {
+	conn, err := xfixesConn()
+	if err != nil {
+		return cursor.Shape{}, err
+	}
+	reply, err := xfixes.GetCursorImage(conn).Reply()
+	if err != nil {
+		return cursor.Shape{}, fmt.Errorf("failed to get cursor image: %w", err)
+	}
+
+	// The pixels are premultiplied ARGB, one per uint32, which is what
+	// image.RGBA holds once the bytes are reordered.
+	w, h := int(reply.Width), int(reply.Height)
+	if w == 0 || h == 0 || len(reply.CursorImage) < w*h {
+		return cursor.Shape{}, fmt.Errorf("cursor image is empty")
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i, px := range reply.CursorImage[:w*h] {
+		img.Pix[i*4+0] = uint8(px >> 16)
+		img.Pix[i*4+1] = uint8(px >> 8)
+		img.Pix[i*4+2] = uint8(px)
+		img.Pix[i*4+3] = uint8(px >> 24)
+	}
+	return cursor.Shape{Image: img, Hotspot: image.Pt(int(reply.Xhot), int(reply.Yhot))}, nil
+}
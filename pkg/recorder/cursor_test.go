@@ -0,0 +1,63 @@
+package recorder
+
+import (
+	"image"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/cursor"
+)
+
+// cursorScreen is a fake screen that also reports a pointer shape, told
+// apart by its hotspot.
+type cursorScreen struct {
+	*FakeScreenCapturer
+
+	mu      sync.Mutex
+	hotspot image.Point
+}
+
+func (c *cursorScreen) setHotspot(p image.Point) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hotspot = p
+}
+
+func (c *cursorScreen) Cursor() (cursor.Shape, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cursor.Shape{Image: image.NewRGBA(image.Rect(0, 0, 8, 8)), Hotspot: c.hotspot}, nil
+}
+
+// TestCursorSampledWithFrame checks that a screenshot cut from a buffered
+// frame shows the pointer as it was when the frame was taken, not as it is
+// when the screenshot is stored.
+func TestCursorSampledWithFrame(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	settings := testSettings(t)
+	settings.ShowCursor = true
+	settings.FrameBufferInterval = int(interval / time.Millisecond)
+
+	b := newTestBackend()
+	screen := &cursorScreen{FakeScreenCapturer: b.screen, hotspot: image.Pt(1, 1)}
+	r := NewRecorderWith(settings, Backend{Input: b.input, Screen: screen, Windows: b.windows, Hotkeys: b.hotkeys})
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(3 * interval)
+	b.input.Click(ButtonLeft, 30, 40)
+	screen.setHotspot(image.Pt(5, 5))
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	steps := r.Recording().Steps
+	if len(steps) != 1 || steps[0].Cursor == nil {
+		t.Fatalf("steps = %+v, want one with a pointer", steps)
+	}
+	if got := steps[0].Cursor.Hotspot; got != image.Pt(1, 1) {
+		t.Errorf("pointer hotspot = %v, want the shape from the frame (1,1)", got)
+	}
+}
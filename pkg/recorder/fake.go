@@ -0,0 +1,310 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// FakeInputSource is an in-memory InputSource. Events passed to Emit are
+// delivered in order, which makes the step-building logic deterministic
+// in tests.
+type FakeInputSource struct {
+	mu       sync.Mutex
+	events   chan InputEvent
+	keyboard bool
+}
+
+func NewFakeInputSource() *FakeInputSource {
+	return &FakeInputSource{}
+}
+
+func (f *FakeInputSource) Start(keyboard bool) (<-chan InputEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.events != nil {
+		return nil, fmt.Errorf("input source already started")
+	}
+	f.events = make(chan InputEvent)
+	f.keyboard = keyboard
+	return f.events, nil
+}
+
+func (f *FakeInputSource) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.events == nil {
+		return fmt.Errorf("input source not started")
+	}
+	close(f.events)
+	f.events = nil
+	return nil
+}
+
+// Emit delivers ev to the recorder and blocks until it has been received.
+// A zero Time is replaced by the current time. Key events are dropped
+// unless the source was started with keyboard enabled, and every event is
+// dropped once it has stopped. The lock is held while sending, so Stop
+// cannot close the channel under it.
+func (f *FakeInputSource) Emit(ev InputEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.events == nil {
+		return
+	}
+	if (ev.Kind == KeyPress || ev.Kind == KeyRelease) && !f.keyboard {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	f.events <- ev
+}
+
+// Click emits a press and release of button at (x, y).
+func (f *FakeInputSource) Click(button Button, x, y int) {
+	pos := image.Point{X: x, Y: y}
+	f.Emit(InputEvent{Kind: ButtonPress, Button: button, Position: pos})
+	f.Emit(InputEvent{Kind: ButtonRelease, Button: button, Position: pos})
+}
+
+// Type emits a press and release for every character of text.
+func (f *FakeInputSource) Type(text string) {
+	for _, r := range text {
+		f.Key(string(r), r, 0)
+	}
+}
+
+// Key emits a press and release of the named key with mods held.
+func (f *FakeInputSource) Key(key string, r rune, mods Modifier) {
+	f.Emit(InputEvent{Kind: KeyPress, Key: key, Rune: r, Modifiers: mods})
+	f.Emit(InputEvent{Kind: KeyRelease, Key: key, Rune: r, Modifiers: mods})
+}
+
+// FakeScreenCapturer is an in-memory ScreenCapturer with a synthetic
+// display layout. Every capture is filled with a solid colour.
+type FakeScreenCapturer struct {
+	mu       sync.Mutex
+	displays []image.Rectangle
+	fill     color.Color
+	captures []image.Rectangle
+	scale    float64
+	scales   map[image.Rectangle]float64
+}
+
+// NewFakeScreenCapturer creates a capturer reporting the given display
+// bounds, in virtual desktop coordinates.
+func NewFakeScreenCapturer(displays ...image.Rectangle) *FakeScreenCapturer {
+	return &FakeScreenCapturer{
+		displays: displays,
+		fill:     color.White,
+		scale:    1,
+	}
+}
+
+// SetFill changes the colour of subsequent captures.
+func (f *FakeScreenCapturer) SetFill(c color.Color) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fill = c
+}
+
+// SetScale changes the scale reported for every display without a scale
+// of its own.
+func (f *FakeScreenCapturer) SetScale(scale float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scale = scale
+}
+
+// SetDisplayScale changes the scale reported for one display, so that a
+// test can lay out monitors with mixed DPI.
+func (f *FakeScreenCapturer) SetDisplayScale(display image.Rectangle, scale float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.scales == nil {
+		f.scales = make(map[image.Rectangle]float64)
+	}
+	f.scales[display] = scale
+}
+
+func (f *FakeScreenCapturer) Scale(display image.Rectangle) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if scale, ok := f.scales[display]; ok {
+		return scale
+	}
+	return f.scale
+}
+
+func (f *FakeScreenCapturer) Displays() []image.Rectangle {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]image.Rectangle(nil), f.displays...)
+}
+
+func (f *FakeScreenCapturer) Capture(bounds image.Rectangle) (image.Image, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if bounds.Empty() {
+		return nil, fmt.Errorf("empty capture rectangle %v", bounds)
+	}
+	f.captures = append(f.captures, bounds)
+
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), image.NewUniform(f.fill), image.Point{}, draw.Src)
+	return img, nil
+}
+
+// Captures returns the rectangles captured so far.
+func (f *FakeScreenCapturer) Captures() []image.Rectangle {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]image.Rectangle(nil), f.captures...)
+}
+
+// FakeWindowInspector is an in-memory WindowInspector whose focus, active
+// window and window stack are set by the test.
+type FakeWindowInspector struct {
+	mu      sync.Mutex
+	focus   Focus
+	window  model.Window
+	windows []model.Window
+}
+
+func NewFakeWindowInspector() *FakeWindowInspector {
+	return &FakeWindowInspector{}
+}
+
+// SetFocus changes the focus reported from now on.
+func (f *FakeWindowInspector) SetFocus(focus Focus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.focus = focus
+}
+
+func (f *FakeWindowInspector) Focus() (Focus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.focus, nil
+}
+
+// SetWindow changes the active window reported from now on.
+func (f *FakeWindowInspector) SetWindow(window model.Window) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.window = window
+}
+
+func (f *FakeWindowInspector) ActiveWindow() (model.Window, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.window, nil
+}
+
+// SetWindows changes the top-level windows on screen, topmost first.
+// Windows with the PID of the test process count as GoStep's own.
+func (f *FakeWindowInspector) SetWindows(windows ...model.Window) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.windows = append([]model.Window(nil), windows...)
+}
+
+func (f *FakeWindowInspector) WindowAt(p image.Point) (model.Window, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, w := range f.windows {
+		if p.In(w.Bounds) {
+			return w, nil
+		}
+	}
+	return model.Window{}, nil
+}
+
+func (f *FakeWindowInspector) Windows() ([]model.Window, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]model.Window(nil), f.windows...), nil
+}
+
+func (f *FakeWindowInspector) OwnWindows() ([]model.Window, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var own []model.Window
+	for _, w := range f.windows {
+		if w.PID == os.Getpid() {
+			own = append(own, w)
+		}
+	}
+	return own, nil
+}
+
+// FakeHotkeyListener is an in-memory HotkeyListener. Press delivers a
+// hotkey as if it had been pressed.
+type FakeHotkeyListener struct {
+	mu       sync.Mutex
+	events   chan HotkeyEvent
+	bindings []HotkeyBinding
+}
+
+func NewFakeHotkeyListener() *FakeHotkeyListener {
+	return &FakeHotkeyListener{}
+}
+
+func (f *FakeHotkeyListener) Start(bindings []HotkeyBinding) (<-chan HotkeyEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.events != nil {
+		return nil, fmt.Errorf("hotkey listener already started")
+	}
+	f.events = make(chan HotkeyEvent)
+	f.bindings = bindings
+	return f.events, nil
+}
+
+func (f *FakeHotkeyListener) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.events == nil {
+		return fmt.Errorf("hotkey listener not started")
+	}
+	close(f.events)
+	f.events = nil
+	return nil
+}
+
+// Bindings returns the bindings passed to Start.
+func (f *FakeHotkeyListener) Bindings() []HotkeyBinding {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]HotkeyBinding(nil), f.bindings...)
+}
+
+// Press delivers action with the pointer at (x, y) and blocks until it has
+// been received. It does nothing if the action is not bound or the
+// listener has stopped. Like Emit, it holds the lock while sending.
+func (f *FakeHotkeyListener) Press(action HotkeyAction, x, y int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bound := false
+	for _, b := range f.bindings {
+		bound = bound || b.Action == action
+	}
+	if f.events == nil || !bound {
+		return
+	}
+	f.events <- HotkeyEvent{Action: action, Position: image.Point{X: x, Y: y}, Time: time.Now()}
+}
@@ -0,0 +1,224 @@
+package recorder
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"log"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/cursor"
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+const (
+	// frameBufferDepth is how many recent frames are kept. Frames are
+	// looked up as soon as the event loop sees a press, so only the last
+	// few are ever needed.
+	frameBufferDepth = 3
+	// settleInterval is how long the screen has to stay unchanged before
+	// an after frame is taken; settleTimeout is how long to wait for that,
+	// after the settle delay, before taking it anyway.
+	settleInterval = 150 * time.Millisecond
+	settleTimeout  = 2 * time.Second
+)
+
+// frame is a capture of the whole virtual desktop. img has its origin at
+// bounds.Min.
+type frame struct {
+	time   time.Time
+	img    image.Image
+	bounds image.Rectangle
+	// hidden is set when GoStep's own windows were kept out of the capture.
+	hidden bool
+	// windows are the windows on screen, for the title redaction rules.
+	windows []model.Window
+	// cursor is the pointer shape when the frame was taken, or nil if the
+	// pointer is not recorded or its shape could not be read.
+	cursor *cursor.Shape
+}
+
+// frameBuffer captures the desktop at a fixed interval and keeps the last
+// frameBufferDepth frames, so that a press can be shown as the screen was
+// just before the button went down.
+type frameBuffer struct {
+	interval time.Duration
+	frames   [frameBufferDepth]*frame
+	next     int
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startFrameBuffer starts capturing frames every interval until stop is
+// called. Nothing is captured while the recording is paused.
+func (r *Recorder) startFrameBuffer(interval time.Duration) *frameBuffer {
+	b := &frameBuffer{
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+			}
+			if r.IsPaused() {
+				continue
+			}
+
+			f := &frame{time: time.Now(), hidden: r.hideOwnWindows(), windows: r.visibleWindows()}
+			img, bounds, ok := r.captureAllDisplays()
+			if !ok {
+				continue
+			}
+			f.img, f.bounds = img, bounds
+			if r.showCursor {
+				f.cursor = r.sampleCursor()
+			}
+
+			r.mu.Lock()
+			b.frames[b.next] = f
+			b.next = (b.next + 1) % frameBufferDepth
+			r.mu.Unlock()
+		}
+	}()
+	return b
+}
+
+func (b *frameBuffer) halt() {
+	close(b.stop)
+	<-b.done
+}
+
+// frameBefore returns the latest frame whose capture started before t, or nil
+// if there is none recent enough to stand in for the screen at t.
+func (r *Recorder) frameBefore(t time.Time) *frame {
+	if r.frames == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest *frame
+	for _, f := range r.frames.frames {
+		if f != nil && f.time.Before(t) && (latest == nil || f.time.After(latest.time)) {
+			latest = f
+		}
+	}
+	if latest == nil || t.Sub(latest.time) > 2*r.frames.interval {
+		return nil
+	}
+	return latest
+}
+
+// cropFrame cuts the screenshot for an event at pos in window win out of
+// f, the way capture would have taken it from the screen.
+func (r *Recorder) cropFrame(f *frame, pos image.Point, win model.Window) (image.Image, image.Rectangle, bool) {
+	if r.scope.mode == config.ScopeAllDisplays {
+		return f.img, f.bounds, true
+	}
+
+	area, ok := r.captureArea(pos, win)
+	if !ok {
+		return nil, image.Rectangle{}, false
+	}
+	area = area.Intersect(f.bounds)
+	if area.Empty() {
+		return nil, image.Rectangle{}, false
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	draw.Draw(img, img.Bounds(), f.img, f.img.Bounds().Min.Add(area.Min.Sub(f.bounds.Min)), draw.Src)
+	return img, area, true
+}
+
+// captureSettled captures area again once at has passed and the screen
+// has stopped changing, or once settleTimeout has passed on top of at. It
+// returns the capture with the windows that were on screen, for the title
+// redaction rules. When next is closed before then, the last capture taken
+// up to that moment is returned instead, so the frame does not show what
+// the next step did. It returns nil if the area cannot be captured.
+func (r *Recorder) captureSettled(area image.Rectangle, at time.Time, next <-chan struct{}) (image.Image, []model.Window) {
+	grab := func() (image.Image, []model.Window, bool) {
+		windows := r.visibleWindows()
+		hidden := r.hideOwnWindows()
+		img, ok := r.grab(area)
+		if ok {
+			img = r.coverOwnWindows(img, area, hidden)
+		}
+		return img, windows, ok
+	}
+
+	wait := time.NewTimer(time.Until(at))
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+	case <-next:
+		// The screen has had no more time to change than this.
+	}
+
+	deadline := time.Now().Add(settleTimeout)
+	last, lastWindows, ok := grab()
+	for ok && time.Now().Before(deadline) {
+		wait.Reset(settleInterval)
+		select {
+		case <-wait.C:
+		case <-next:
+			return last, lastWindows
+		}
+		img, windows, ok := grab()
+		if !ok {
+			break
+		}
+		if sameImage(img, last) {
+			return img, windows
+		}
+		last, lastWindows = img, windows
+	}
+	return last, lastWindows
+}
+
+// grab captures area, which is the whole desktop in the all displays
+// scope.
+func (r *Recorder) grab(area image.Rectangle) (image.Image, bool) {
+	if r.scope.mode == config.ScopeAllDisplays {
+		img, _, ok := r.captureAllDisplays()
+		return img, ok
+	}
+	img, err := r.backend.Screen.Capture(area)
+	if err != nil {
+		log.Printf("Failed to capture screenshot: %v", err)
+		return nil, false
+	}
+	return img, true
+}
+
+func sameImage(a, b image.Image) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	ra, okA := a.(*image.RGBA)
+	rb, okB := b.(*image.RGBA)
+	if okA && okB && ra.Rect.Min == (image.Point{}) && rb.Rect.Min == (image.Point{}) && ra.Stride == rb.Stride {
+		return bytes.Equal(ra.Pix, rb.Pix)
+	}
+
+	da, db := a.Bounds().Min, b.Bounds().Min
+	for y := 0; y < a.Bounds().Dy(); y++ {
+		for x := 0; x < a.Bounds().Dx(); x++ {
+			r1, g1, b1, a1 := a.At(da.X+x, da.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(db.X+x, db.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
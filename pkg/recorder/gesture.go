@@ -0,0 +1,277 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// Step actions produced by the gesture classifier. Scroll steps use
+// scrollAction instead of a fixed string.
+const (
+	ActionClick       = "Mouse Click"
+	ActionRightClick  = "Right Click"
+	ActionMiddleClick = "Middle Click"
+	ActionDoubleClick = "Double Click"
+	ActionDrag        = "Drag"
+	ActionManual      = "Manual Capture"
+)
+
+const (
+	// dragThreshold is how far the pointer may move between press and
+	// release before the gesture counts as a drag rather than a click.
+	dragThreshold = 8
+	// doubleClickInterval and doubleClickDistance bound how close two
+	// left clicks must be to merge into a double click.
+	doubleClickInterval = 500 * time.Millisecond
+	doubleClickDistance = 4
+	// scrollGap ends a run of scroll notches when no notch arrives in time.
+	scrollGap = time.Second
+)
+
+var clickActions = map[Button]string{
+	ButtonLeft:   ActionClick,
+	ButtonMiddle: ActionMiddleClick,
+	ButtonRight:  ActionRightClick,
+}
+
+// pendingPress is a button press waiting for its release, together with
+// the screenshot requested when it happened. index is the step recorded
+// for it once it was released as a click.
+type pendingPress struct {
+	ev    InputEvent
+	shot  *shot
+	index int
+}
+
+// scrollRun tracks consecutive scroll notches collapsed into one step.
+type scrollRun struct {
+	index   int
+	delta   image.Point
+	notches int
+	last    time.Time
+}
+
+func (r *Recorder) handleEvent(ev InputEvent) {
+	switch ev.Kind {
+	case ButtonPress:
+		r.scroll = nil
+		r.flushTyping()
+		r.handlePress(ev)
+	case ButtonRelease:
+		r.handleRelease(ev)
+	case Scroll:
+		r.flushTyping()
+		r.handleScroll(ev)
+	case KeyPress, KeyRelease:
+		r.handleKey(ev)
+	case Capture:
+		r.flushPress()
+		r.flushTyping()
+		r.handleCapture(ev)
+	}
+}
+
+// handleCapture records the display under the pointer as a step of its
+// own. Nothing was clicked, so the screenshot is not highlighted.
+func (r *Recorder) handleCapture(ev InputEvent) {
+	r.lastClick, r.scroll = nil, nil
+
+	s := r.requestShot(ev.Position, ev.Time)
+	if s == nil {
+		return
+	}
+	r.appendStep(model.Step{
+		Timestamp:   ev.Time,
+		Action:      ActionManual,
+		Coordinates: ev.Position,
+	}, s, nil)
+}
+
+func (r *Recorder) handlePress(ev InputEvent) {
+	// Presses of other buttons while one is held are part of the same
+	// gesture and are ignored, as are presses on GoStep's own windows.
+	if r.press != nil || r.isOwnWindowAt(ev.Position) {
+		return
+	}
+
+	// The second press of a double click only renames the step of the
+	// first, so it neither cuts that step's after frame short nor needs a
+	// screenshot; pressShot takes one late if it turns out to be a drag.
+	press := &pendingPress{ev: ev}
+	if r.isDoubleClick(press) {
+		r.press = press
+		return
+	}
+
+	// Otherwise the screen after the last step has to be taken before the
+	// press changes anything.
+	r.beginStep()
+	if press.shot = r.requestPressShot(ev.Position, ev.Time); press.shot == nil {
+		return
+	}
+	r.press = press
+}
+
+// pressShot returns the screenshot of press, requesting it now for a press
+// that was held back as the second half of a double click and became
+// something else. It returns nil if the capture queue is full.
+func (r *Recorder) pressShot(press *pendingPress) *shot {
+	if press.shot == nil {
+		r.beginStep()
+		press.shot = r.requestPressShot(press.ev.Position, press.ev.Time)
+	}
+	return press.shot
+}
+
+func (r *Recorder) handleRelease(ev InputEvent) {
+	press := r.press
+	if press == nil || press.ev.Button != ev.Button {
+		return
+	}
+	r.press = nil
+
+	if exceeds(ev.Position.Sub(press.ev.Position), dragThreshold) {
+		r.lastClick = nil
+		s := r.pressShot(press)
+		if s == nil {
+			return
+		}
+		step := press.step(ActionDrag)
+		step.EndCoordinates = ev.Position
+		r.appendStep(step, s, r.markDrag(press.ev.Position, ev.Position))
+		return
+	}
+
+	if r.isDoubleClick(press) {
+		index := r.lastClick.index
+		r.lastClick = nil
+		r.mu.Lock()
+		r.steps[index].Action = ActionDoubleClick
+		r.journalStep(index)
+		r.mu.Unlock()
+		return
+	}
+
+	s := r.pressShot(press)
+	if s == nil {
+		r.lastClick = nil
+		return
+	}
+	press.index = r.appendStep(press.step(clickActions[press.ev.Button]), s, r.markClicks(press.ev.Position))
+	r.lastClick = press
+}
+
+// isDoubleClick reports whether press completes a double click with the
+// previous left click. Any step recorded in between forgets that click.
+func (r *Recorder) isDoubleClick(press *pendingPress) bool {
+	last := r.lastClick
+	if last == nil || last.ev.Button != ButtonLeft || press.ev.Button != ButtonLeft {
+		return false
+	}
+	if press.ev.Time.Sub(last.ev.Time) > doubleClickInterval {
+		return false
+	}
+	return !exceeds(press.ev.Position.Sub(last.ev.Position), doubleClickDistance)
+}
+
+func (r *Recorder) handleScroll(ev InputEvent) {
+	r.lastClick = nil
+	if r.isOwnWindowAt(ev.Position) {
+		return
+	}
+
+	run := r.scroll
+	if run != nil && run.delta == sign(ev.Delta) && ev.Time.Sub(run.last) <= scrollGap {
+		run.notches += notches(ev.Delta)
+		run.last = ev.Time
+		r.mu.Lock()
+		r.steps[run.index].Action = scrollAction(run.delta, run.notches)
+		r.journalStep(run.index)
+		r.mu.Unlock()
+		return
+	}
+
+	s := r.requestShot(ev.Position, ev.Time)
+	if s == nil {
+		r.scroll = nil
+		return
+	}
+
+	run = &scrollRun{delta: sign(ev.Delta), notches: notches(ev.Delta), last: ev.Time}
+	press := &pendingPress{ev: ev, shot: s}
+	run.index = r.appendStep(press.step(scrollAction(run.delta, run.notches)), s, r.markClicks(ev.Position))
+	r.scroll = run
+}
+
+// finishGestures records any gesture or typing run still in progress and
+// forgets the state that would merge later input into earlier steps.
+func (r *Recorder) finishGestures() {
+	r.flushPress()
+	r.flushTyping()
+	r.lastClick, r.scroll = nil, nil
+}
+
+// flushPress records a press whose release never arrived as a click.
+func (r *Recorder) flushPress() {
+	press := r.press
+	if press == nil {
+		return
+	}
+	r.press = nil
+	if s := r.pressShot(press); s != nil {
+		r.appendStep(press.step(clickActions[press.ev.Button]), s, r.markClicks(press.ev.Position))
+	}
+}
+
+func (p *pendingPress) step(action string) model.Step {
+	return model.Step{
+		Timestamp:   p.ev.Time,
+		Action:      action,
+		Coordinates: p.ev.Position,
+		Highlighted: true,
+	}
+}
+
+func scrollAction(delta image.Point, notches int) string {
+	direction := "down"
+	switch {
+	case delta.Y < 0:
+		direction = "up"
+	case delta.X > 0:
+		direction = "right"
+	case delta.X < 0:
+		direction = "left"
+	}
+
+	unit := "notches"
+	if notches == 1 {
+		unit = "notch"
+	}
+	return fmt.Sprintf("Scroll %s %d %s", direction, notches, unit)
+}
+
+// notches returns how many wheel notches a scroll delta covers.
+func notches(delta image.Point) int {
+	n := max(delta.X, -delta.X, delta.Y, -delta.Y)
+	return max(n, 1)
+}
+
+func exceeds(d image.Point, limit int) bool {
+	return d.X > limit || d.X < -limit || d.Y > limit || d.Y < -limit
+}
+
+func sign(p image.Point) image.Point {
+	clamp := func(v int) int {
+		switch {
+		case v > 0:
+			return 1
+		case v < 0:
+			return -1
+		}
+		return 0
+	}
+	return image.Point{X: clamp(p.X), Y: clamp(p.Y)}
+}
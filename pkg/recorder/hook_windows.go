@@ -0,0 +1,262 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	WH_KEYBOARD_LL = 13
+	WH_MOUSE_LL    = 14
+
+	WM_KEYDOWN     = 0x0100
+	WM_KEYUP       = 0x0101
+	WM_SYSKEYDOWN  = 0x0104
+	WM_SYSKEYUP    = 0x0105
+	WM_LBUTTONDOWN = 0x0201
+	WM_LBUTTONUP   = 0x0202
+	WM_RBUTTONDOWN = 0x0204
+	WM_RBUTTONUP   = 0x0205
+	WM_MBUTTONDOWN = 0x0207
+	WM_MBUTTONUP   = 0x0208
+	WM_MOUSEWHEEL  = 0x020A
+	WM_MOUSEHWHEEL = 0x020E
+
+	WHEEL_DELTA = 120
+)
+
+var (
+	setWindowsHookExW   = user32.NewProc("SetWindowsHookExW")
+	unhookWindowsHookEx = user32.NewProc("UnhookWindowsHookEx")
+	callNextHookEx      = user32.NewProc("CallNextHookEx")
+	getModuleHandleW    = kernel32.NewProc("GetModuleHandleW")
+)
+
+type msllHookStruct struct {
+	pt          point
+	mouseData   uint32
+	flags       uint32
+	time        uint32
+	dwExtraInfo uintptr
+}
+
+type kbdllHookStruct struct {
+	vkCode      uint32
+	scanCode    uint32
+	flags       uint32
+	time        uint32
+	dwExtraInfo uintptr
+}
+
+var hookButtons = map[uintptr]struct {
+	button Button
+	press  bool
+}{
+	WM_LBUTTONDOWN: {ButtonLeft, true},
+	WM_LBUTTONUP:   {ButtonLeft, false},
+	WM_RBUTTONDOWN: {ButtonRight, true},
+	WM_RBUTTONUP:   {ButtonRight, false},
+	WM_MBUTTONDOWN: {ButtonMiddle, true},
+	WM_MBUTTONUP:   {ButtonMiddle, false},
+}
+
+// Callbacks made by syscall.NewCallback are never released, so the hook
+// procedures are created once and deliver to whichever source is running.
+var (
+	activeHook       atomic.Pointer[hookSource]
+	mouseHookProc    = syscall.NewCallback(mouseHook)
+	keyboardHookProc = syscall.NewCallback(keyboardHook)
+)
+
+// hookSource receives input through low-level mouse and keyboard hooks.
+// Windows calls the hooks on the thread that installed them, from its
+// message loop, as each input event arrives.
+type hookSource struct {
+	mu       sync.Mutex
+	threadID uintptr
+	done     chan struct{}
+
+	events chan InputEvent
+	// dropped counts the events send could not queue.
+	dropped atomic.Int64
+
+	// Owned by the hook thread. clock places the tick counts of events in
+	// wall clock time; wheel is the wheel rotation, per axis, not yet sent
+	// as a whole notch.
+	clock eventClock
+	wheel image.Point
+}
+
+func newHookSource() InputSource {
+	return &hookSource{}
+}
+
+func (s *hookSource) Start(keyboard bool) (<-chan InputEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return nil, fmt.Errorf("input source already started")
+	}
+	if !activeHook.CompareAndSwap(nil, s) {
+		return nil, fmt.Errorf("another input hook is already running")
+	}
+
+	s.events = make(chan InputEvent, 256)
+	s.dropped.Store(0)
+	s.clock = eventClock{}
+	s.wheel = image.Point{}
+	started := make(chan error, 1)
+	threadID := make(chan uintptr, 1)
+	done := make(chan struct{})
+
+	go s.run(keyboard, threadID, started, done)
+	if err := <-started; err != nil {
+		activeHook.Store(nil)
+		return nil, err
+	}
+
+	s.threadID = <-threadID
+	s.done = done
+	return s.events, nil
+}
+
+func (s *hookSource) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done == nil {
+		return fmt.Errorf("input source not started")
+	}
+
+	postThreadMessageW.Call(s.threadID, WM_QUIT, 0, 0)
+	<-s.done
+	s.done = nil
+	return nil
+}
+
+func (s *hookSource) run(keyboard bool, threadID chan<- uintptr, started chan<- error, done chan struct{}) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(done)
+	defer activeHook.Store(nil)
+	defer close(s.events)
+
+	module, _, _ := getModuleHandleW.Call(0)
+	mouse, _, err := setWindowsHookExW.Call(WH_MOUSE_LL, mouseHookProc, module, 0)
+	if mouse == 0 {
+		started <- fmt.Errorf("failed to install mouse hook: %v", err)
+		return
+	}
+	defer unhookWindowsHookEx.Call(mouse)
+
+	if keyboard {
+		kbd, _, err := setWindowsHookExW.Call(WH_KEYBOARD_LL, keyboardHookProc, module, 0)
+		if kbd == 0 {
+			started <- fmt.Errorf("failed to install keyboard hook: %v", err)
+			return
+		}
+		defer unhookWindowsHookEx.Call(kbd)
+	}
+
+	tid, _, _ := getCurrentThreadId.Call()
+	threadID <- tid
+	started <- nil
+
+	// The hooks are called from within GetMessage.
+	var m msg
+	for {
+		ret, _, _ := getMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		if int32(ret) <= 0 {
+			return
+		}
+	}
+}
+
+// send queues ev without blocking; a hook that takes too long is removed
+// by Windows, so an event that does not fit is dropped instead.
+func (s *hookSource) send(ev InputEvent) {
+	select {
+	case s.events <- ev:
+	default:
+		s.dropped.Add(1)
+		log.Printf("Input queue full, dropping event")
+	}
+}
+
+// Dropped returns how many events were dropped since Start.
+func (s *hookSource) Dropped() int {
+	return int(s.dropped.Load())
+}
+
+func mouseHook(nCode int32, wParam uintptr, info *msllHookStruct) uintptr {
+	if s := activeHook.Load(); s != nil && nCode >= 0 {
+		ev := InputEvent{
+			Position: image.Point{X: int(info.pt.X), Y: int(info.pt.Y)},
+			Time:     s.clock.at(info.time),
+		}
+
+		switch {
+		case hookButtons[wParam].button != 0:
+			b := hookButtons[wParam]
+			ev.Button = b.button
+			ev.Kind = ButtonRelease
+			if b.press {
+				ev.Kind = ButtonPress
+			}
+			s.send(ev)
+		case wParam == WM_MOUSEWHEEL:
+			// Positive is a rotation away from the user, which scrolls up.
+			if notches := wheelNotches(&s.wheel.Y, info.mouseData); notches != 0 {
+				ev.Kind = Scroll
+				ev.Delta = image.Point{Y: -notches}
+				s.send(ev)
+			}
+		case wParam == WM_MOUSEHWHEEL:
+			if notches := wheelNotches(&s.wheel.X, info.mouseData); notches != 0 {
+				ev.Kind = Scroll
+				ev.Delta = image.Point{X: notches}
+				s.send(ev)
+			}
+		}
+	}
+	ret, _, _ := callNextHookEx.Call(0, uintptr(nCode), wParam, uintptr(unsafe.Pointer(info)))
+	return ret
+}
+
+// wheelNotches adds the wheel delta in mouseData, its high word, to the
+// rotation in acc and takes out the whole notches of WHEEL_DELTA each. High
+// resolution wheels and touchpads report a fraction of a notch at a time,
+// which add up to a notch over several events.
+func wheelNotches(acc *int, mouseData uint32) int {
+	*acc += int(int16(mouseData >> 16))
+	notches := *acc / WHEEL_DELTA
+	*acc -= notches * WHEEL_DELTA
+	return notches
+}
+
+func keyboardHook(nCode int32, wParam uintptr, info *kbdllHookStruct) uintptr {
+	if s := activeHook.Load(); s != nil && nCode >= 0 {
+		vk := uintptr(info.vkCode)
+		if _, ok := vkNames[vk]; ok {
+			press := wParam == WM_KEYDOWN || wParam == WM_SYSKEYDOWN
+			if press || wParam == WM_KEYUP || wParam == WM_SYSKEYUP {
+				ev := keyEvent(vk, press)
+				ev.Position = cursorPos()
+				ev.Time = s.clock.at(info.time)
+				s.send(ev)
+			}
+		}
+	}
+	ret, _, _ := callNextHookEx.Call(0, uintptr(nCode), wParam, uintptr(unsafe.Pointer(info)))
+	return ret
+}
@@ -0,0 +1,210 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gustaf/go-test/pkg/config"
+)
+
+// HotkeyAction is a recorder command bound to a global hotkey.
+type HotkeyAction int
+
+const (
+	HotkeyStartStop HotkeyAction = iota
+	HotkeyPauseResume
+	HotkeyCaptureStep
+)
+
+// Hotkey is a key chord such as Ctrl+Alt+R. Key uses the same names as
+// InputEvent.Key, with single characters in lower case.
+type Hotkey struct {
+	Modifiers Modifier
+	Key       string
+}
+
+// HotkeyBinding ties a hotkey to the action it triggers.
+type HotkeyBinding struct {
+	Action HotkeyAction
+	Hotkey Hotkey
+}
+
+// HotkeyEvent reports that a bound hotkey was pressed. Position is the
+// pointer position at the time, in virtual desktop coordinates.
+type HotkeyEvent struct {
+	Action   HotkeyAction
+	Position image.Point
+	Time     time.Time
+}
+
+// HotkeyListener grabs hotkeys system-wide, so they work whichever
+// application has the focus.
+type HotkeyListener interface {
+	// Start grabs the given hotkeys and reports presses on the returned
+	// channel. The channel is closed once the listener has stopped.
+	Start(bindings []HotkeyBinding) (<-chan HotkeyEvent, error)
+	Stop() error
+}
+
+var modifierAliases = map[string]Modifier{
+	"shift":   ModShift,
+	"ctrl":    ModCtrl,
+	"control": ModCtrl,
+	"alt":     ModAlt,
+	"super":   ModSuper,
+	"win":     ModSuper,
+	"meta":    ModSuper,
+}
+
+// ParseHotkey parses a chord written like "Ctrl+Alt+R" or "F9".
+func ParseHotkey(s string) (Hotkey, error) {
+	parts := strings.Split(s, "+")
+	var h Hotkey
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i < len(parts)-1 {
+			mod, ok := modifierAliases[strings.ToLower(part)]
+			if !ok {
+				return Hotkey{}, fmt.Errorf("unknown modifier %q in hotkey %q", part, s)
+			}
+			h.Modifiers |= mod
+			continue
+		}
+		if part == "" {
+			return Hotkey{}, fmt.Errorf("hotkey %q has no key", s)
+		}
+		if _, ok := modifierAliases[strings.ToLower(part)]; ok {
+			return Hotkey{}, fmt.Errorf("hotkey %q has no key besides modifiers", s)
+		}
+		if utf8.RuneCountInString(part) == 1 {
+			part = strings.ToLower(part)
+		}
+		h.Key = part
+	}
+	return h, nil
+}
+
+func (h Hotkey) String() string {
+	name := h.Key
+	if utf8.RuneCountInString(name) == 1 {
+		name = strings.ToUpper(name)
+	}
+	if h.Modifiers != 0 {
+		name = h.Modifiers.String() + "+" + name
+	}
+	return name
+}
+
+// matches reports whether ev is a press or release of the hotkey.
+func (h Hotkey) matches(ev InputEvent) bool {
+	return ev.Modifiers == h.Modifiers && strings.EqualFold(ev.Key, h.Key)
+}
+
+// hotkeyBindings parses the hotkeys configured in settings. Empty settings
+// leave the action unbound.
+func hotkeyBindings(settings *config.Settings) ([]HotkeyBinding, error) {
+	configured := []struct {
+		action HotkeyAction
+		value  string
+	}{
+		{HotkeyStartStop, settings.HotkeyStartStop},
+		{HotkeyPauseResume, settings.HotkeyPauseResume},
+		{HotkeyCaptureStep, settings.HotkeyCaptureStep},
+	}
+
+	var bindings []HotkeyBinding
+	for _, c := range configured {
+		if strings.TrimSpace(c.value) == "" {
+			continue
+		}
+		h, err := ParseHotkey(c.value)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, HotkeyBinding{Action: c.action, Hotkey: h})
+	}
+	return bindings, nil
+}
+
+// ListenHotkeys grabs the global hotkeys configured in settings. Pausing
+// and manual steps are carried out by the Recorder itself. notify is then
+// called with every hotkey that fired, from a background goroutine; what
+// start/stop does is left to it, since only the frontend knows what has to
+// happen around a recording.
+func (r *Recorder) ListenHotkeys(notify func(HotkeyAction)) error {
+	if r.backend.Hotkeys == nil {
+		return fmt.Errorf("global hotkeys are not supported on this platform")
+	}
+
+	bindings, err := hotkeyBindings(r.settings)
+	if err != nil {
+		return err
+	}
+	if len(bindings) == 0 {
+		return nil
+	}
+
+	events, err := r.backend.Hotkeys.Start(bindings)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for ev := range events {
+			r.handleHotkey(ev)
+			if notify != nil {
+				notify(ev.Action)
+			}
+		}
+	}()
+	return nil
+}
+
+// StopHotkeys releases the hotkeys grabbed by ListenHotkeys.
+func (r *Recorder) StopHotkeys() error {
+	if r.backend.Hotkeys == nil {
+		return nil
+	}
+	return r.backend.Hotkeys.Stop()
+}
+
+func (r *Recorder) handleHotkey(ev HotkeyEvent) {
+	if !r.IsRecording() {
+		return
+	}
+
+	switch ev.Action {
+	case HotkeyPauseResume:
+		var err error
+		if r.IsPaused() {
+			err = r.Resume()
+		} else {
+			err = r.Pause()
+		}
+		if err != nil {
+			log.Printf("Failed to toggle pause: %v", err)
+		}
+	case HotkeyCaptureStep:
+		if r.IsPaused() {
+			return
+		}
+		r.do(func() {
+			r.handleEvent(InputEvent{Kind: Capture, Position: ev.Position, Time: ev.Time})
+		})
+	}
+}
+
+// isHotkey reports whether a key event belongs to one of the bound
+// hotkeys. Those keys drive the recorder and are never recorded.
+func (r *Recorder) isHotkey(ev InputEvent) bool {
+	for _, b := range r.hotkeys {
+		if b.Hotkey.matches(ev) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// x11ModifierMasks maps modifiers to core protocol modifier masks.
+var x11ModifierMasks = []struct {
+	mod  Modifier
+	mask uint16
+}{
+	{ModShift, xproto.ModMaskShift},
+	{ModCtrl, xproto.ModMaskControl},
+	{ModAlt, xproto.ModMask1},
+	{ModSuper, xproto.ModMask4},
+}
+
+// x11IgnoredMasks are the lock modifiers a hotkey has to work with. Each
+// combination needs its own grab.
+var x11IgnoredMasks = []uint16{
+	0,
+	xproto.ModMaskLock,
+	xproto.ModMask2, // Num Lock
+	xproto.ModMaskLock | xproto.ModMask2,
+}
+
+// x11Grab is one grabbed key chord.
+type x11Grab struct {
+	keycode xproto.Keycode
+	mask    uint16
+	action  HotkeyAction
+}
+
+// x11HotkeyListener grabs hotkeys on the root window with XGrabKey.
+type x11HotkeyListener struct {
+	mu    sync.Mutex
+	conn  *xgb.Conn
+	done  chan struct{}
+	grabs []x11Grab
+}
+
+func newHotkeyListener() HotkeyListener {
+	return &x11HotkeyListener{}
+}
+
+func (l *x11HotkeyListener) Start(bindings []HotkeyBinding) (<-chan HotkeyEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		return nil, fmt.Errorf("hotkey listener already started")
+	}
+
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+	keymap, err := loadKeymap(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read keyboard mapping: %w", err)
+	}
+
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	grabs, err := grabHotkeys(conn, root, keymap, bindings)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	events := make(chan HotkeyEvent, 8)
+	l.conn = conn
+	l.done = make(chan struct{})
+	l.grabs = grabs
+
+	go l.readEvents(conn, grabs, events, l.done)
+	return events, nil
+}
+
+func (l *x11HotkeyListener) Stop() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return fmt.Errorf("hotkey listener not started")
+	}
+
+	// Grabs are released together with the connection that made them.
+	l.conn.Close()
+	<-l.done
+	l.conn, l.grabs = nil, nil
+	return nil
+}
+
+// grabHotkeys grabs every binding on root, once per lock modifier
+// combination.
+func grabHotkeys(conn *xgb.Conn, root xproto.Window, keymap *x11Keymap, bindings []HotkeyBinding) ([]x11Grab, error) {
+	var grabs []x11Grab
+	for _, b := range bindings {
+		keycode, ok := keymap.keycode(b.Hotkey.Key)
+		if !ok {
+			return nil, fmt.Errorf("hotkey %s: no key named %q on this keyboard", b.Hotkey, b.Hotkey.Key)
+		}
+
+		var mask uint16
+		for _, m := range x11ModifierMasks {
+			if b.Hotkey.Modifiers&m.mod != 0 {
+				mask |= m.mask
+			}
+		}
+
+		for _, ignored := range x11IgnoredMasks {
+			err := xproto.GrabKeyChecked(conn, true, root, mask|ignored, keycode,
+				xproto.GrabModeAsync, xproto.GrabModeAsync).Check()
+			if err != nil {
+				return nil, fmt.Errorf("hotkey %s is already in use: %v", b.Hotkey, err)
+			}
+		}
+		grabs = append(grabs, x11Grab{keycode: keycode, mask: mask, action: b.Action})
+	}
+	return grabs, nil
+}
+
+func (l *x11HotkeyListener) readEvents(conn *xgb.Conn, grabs []x11Grab, events chan<- HotkeyEvent, done chan struct{}) {
+	defer close(done)
+	defer close(events)
+
+	ignored := uint16(xproto.ModMaskLock | xproto.ModMask2)
+	for {
+		ev, err := conn.WaitForEvent()
+		if ev == nil && err == nil {
+			// The connection was closed.
+			return
+		}
+		press, ok := ev.(xproto.KeyPressEvent)
+		if !ok {
+			continue
+		}
+
+		for _, g := range grabs {
+			if press.Detail != g.keycode || press.State&^ignored != g.mask {
+				continue
+			}
+			// A full channel means the previous presses have not been
+			// handled yet; dropping a repeat is harmless.
+			select {
+			case events <- HotkeyEvent{
+				Action:   g.action,
+				Position: image.Point{X: int(press.RootX), Y: int(press.RootY)},
+				Time:     time.Now(),
+			}:
+			default:
+			}
+			break
+		}
+	}
+}
+
+// keycode finds the key whose unshifted name is key.
+func (k *x11Keymap) keycode(key string) (xproto.Keycode, bool) {
+	if k.perKeycode == 0 {
+		return 0, false
+	}
+	count := len(k.keysyms) / k.perKeycode
+	for i := 0; i < count; i++ {
+		keycode := int(k.minKeycode) + i
+		if keycode > 255 {
+			break
+		}
+		if name, _ := k.lookup(byte(keycode), false, false); name != "" && strings.EqualFold(name, key) {
+			return xproto.Keycode(keycode), true
+		}
+	}
+	return 0, false
+}
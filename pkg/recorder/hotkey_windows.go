@@ -0,0 +1,177 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	MOD_ALT      = 0x0001
+	MOD_CONTROL  = 0x0002
+	MOD_SHIFT    = 0x0004
+	MOD_WIN      = 0x0008
+	MOD_NOREPEAT = 0x4000
+
+	WM_QUIT   = 0x0012
+	WM_HOTKEY = 0x0312
+)
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	getCurrentThreadId = kernel32.NewProc("GetCurrentThreadId")
+	registerHotKey     = user32.NewProc("RegisterHotKey")
+	unregisterHotKey   = user32.NewProc("UnregisterHotKey")
+	getMessageW        = user32.NewProc("GetMessageW")
+	postThreadMessageW = user32.NewProc("PostThreadMessageW")
+)
+
+var hotkeyModifiers = []struct {
+	mod   Modifier
+	flags uintptr
+}{
+	{ModShift, MOD_SHIFT},
+	{ModCtrl, MOD_CONTROL},
+	{ModAlt, MOD_ALT},
+	{ModSuper, MOD_WIN},
+}
+
+type point struct {
+	X, Y int32
+}
+
+type msg struct {
+	hwnd     uintptr
+	message  uint32
+	wParam   uintptr
+	lParam   uintptr
+	time     uint32
+	pt       point
+	lPrivate uint32
+}
+
+// win32HotkeyListener registers hotkeys with RegisterHotKey. The hotkeys
+// belong to the thread that registered them, so a dedicated OS thread
+// registers them and runs the message loop that receives WM_HOTKEY.
+type win32HotkeyListener struct {
+	mu       sync.Mutex
+	threadID uintptr
+	done     chan struct{}
+}
+
+func newHotkeyListener() HotkeyListener {
+	return &win32HotkeyListener{}
+}
+
+func (l *win32HotkeyListener) Start(bindings []HotkeyBinding) (<-chan HotkeyEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done != nil {
+		return nil, fmt.Errorf("hotkey listener already started")
+	}
+
+	events := make(chan HotkeyEvent, 8)
+	started := make(chan error, 1)
+	threadID := make(chan uintptr, 1)
+	done := make(chan struct{})
+
+	go l.run(bindings, events, threadID, started, done)
+	if err := <-started; err != nil {
+		return nil, err
+	}
+
+	l.threadID = <-threadID
+	l.done = done
+	return events, nil
+}
+
+func (l *win32HotkeyListener) Stop() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.done == nil {
+		return fmt.Errorf("hotkey listener not started")
+	}
+
+	postThreadMessageW.Call(l.threadID, WM_QUIT, 0, 0)
+	<-l.done
+	l.done = nil
+	return nil
+}
+
+func (l *win32HotkeyListener) run(bindings []HotkeyBinding, events chan<- HotkeyEvent, threadID chan<- uintptr, started chan<- error, done chan struct{}) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(done)
+	defer close(events)
+
+	registered := 0
+	defer func() {
+		for id := 1; id <= registered; id++ {
+			unregisterHotKey.Call(0, uintptr(id))
+		}
+	}()
+
+	for i, b := range bindings {
+		vk, ok := virtualKey(b.Hotkey.Key)
+		if !ok {
+			started <- fmt.Errorf("hotkey %s: unknown key %q", b.Hotkey, b.Hotkey.Key)
+			return
+		}
+		flags := uintptr(MOD_NOREPEAT)
+		for _, m := range hotkeyModifiers {
+			if b.Hotkey.Modifiers&m.mod != 0 {
+				flags |= m.flags
+			}
+		}
+		// Hotkey ids start at 1 so the binding index is id-1.
+		if ok, _, err := registerHotKey.Call(0, uintptr(i+1), flags, vk); ok == 0 {
+			started <- fmt.Errorf("hotkey %s is already in use: %v", b.Hotkey, err)
+			return
+		}
+		registered++
+	}
+
+	tid, _, _ := getCurrentThreadId.Call()
+	threadID <- tid
+	started <- nil
+
+	var m msg
+	for {
+		ret, _, _ := getMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		if int32(ret) <= 0 {
+			// WM_QUIT from Stop, or an error.
+			return
+		}
+		if m.message != WM_HOTKEY || m.wParam < 1 || int(m.wParam) > len(bindings) {
+			continue
+		}
+
+		select {
+		case events <- HotkeyEvent{
+			Action:   bindings[m.wParam-1].Action,
+			Position: cursorPos(),
+			Time:     time.Now(),
+		}:
+		default:
+		}
+	}
+}
+
+// virtualKey finds the virtual key code of a key name.
+func virtualKey(key string) (uintptr, bool) {
+	for vk, name := range vkNames {
+		if name != "" && strings.EqualFold(name, key) {
+			return vk, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,288 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"log"
+	"sync"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/record"
+	"github.com/jezek/xgb/xproto"
+)
+
+// X11 core protocol constants
+const (
+	x11KeyPress      = 2
+	x11KeyRelease    = 3
+	x11ButtonPress   = 4
+	x11ButtonRelease = 5
+)
+
+// x11Buttons maps core protocol button numbers to pointer buttons.
+var x11Buttons = map[byte]Button{
+	1: ButtonLeft,
+	2: ButtonMiddle,
+	3: ButtonRight,
+}
+
+// x11ScrollButtons maps the wheel pseudo-buttons to scroll directions.
+var x11ScrollButtons = map[byte]image.Point{
+	4: {Y: -1},
+	5: {Y: 1},
+	6: {X: -1},
+	7: {X: 1},
+}
+
+// recordSource reports pointer button and key events from every X client
+// through the RECORD extension.
+type recordSource struct {
+	mu       sync.Mutex
+	stopChan chan struct{}
+	done     chan struct{}
+
+	ctrl    *xgb.Conn
+	data    *x11DataConn
+	context record.Context
+	keymap  *x11Keymap
+}
+
+// newInputSource picks the X11 input source for the configured backend.
+func newInputSource(backend string) InputSource {
+	if backend == config.InputFallback {
+		return &recordSource{}
+	}
+	return newXI2Source()
+}
+
+func (s *recordSource) Start(keyboard bool) (<-chan InputEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan != nil {
+		return nil, fmt.Errorf("input source already started")
+	}
+
+	if err := s.openRecordContext(keyboard); err != nil {
+		return nil, err
+	}
+
+	events := make(chan InputEvent, 64)
+	s.stopChan = make(chan struct{})
+	s.done = make(chan struct{})
+
+	dec := &x11Decoder{keymap: s.keymap}
+	go s.readEvents(events, s.data, dec, s.stopChan, s.done)
+	return events, nil
+}
+
+func (s *recordSource) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan == nil {
+		return fmt.Errorf("input source not started")
+	}
+
+	close(s.stopChan)
+	s.stopChan = nil
+
+	// Closing the data connection unblocks readEvents; the context goes
+	// away together with the control connection that created it.
+	record.DisableContext(s.ctrl, s.context)
+	record.FreeContext(s.ctrl, s.context)
+	s.data.Close()
+	<-s.done
+	s.ctrl.Close()
+
+	s.ctrl, s.data, s.keymap = nil, nil, nil
+	return nil
+}
+
+// openRecordContext creates a RECORD context that intercepts pointer
+// button events, and key events if keyboard is set, from every client and
+// enables it on a dedicated data connection.
+func (s *recordSource) openRecordContext(keyboard bool) error {
+	ctrl, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("failed to connect to X server: %w", err)
+	}
+
+	if err := record.Init(ctrl); err != nil {
+		ctrl.Close()
+		return fmt.Errorf("X server does not support the RECORD extension: %w", err)
+	}
+	if _, err := record.QueryVersion(ctrl, 1, 13).Reply(); err != nil {
+		ctrl.Close()
+		return fmt.Errorf("failed to query RECORD version: %w", err)
+	}
+
+	ctx, err := record.NewContextId(ctrl)
+	if err != nil {
+		ctrl.Close()
+		return fmt.Errorf("failed to allocate RECORD context: %w", err)
+	}
+
+	var keymap *x11Keymap
+	first := byte(xproto.ButtonPress)
+	if keyboard {
+		if keymap, err = loadKeymap(ctrl); err != nil {
+			ctrl.Close()
+			return fmt.Errorf("failed to read keyboard mapping: %w", err)
+		}
+		first = xproto.KeyPress
+	}
+
+	ranges := []record.Range{{
+		DeviceEvents: record.Range8{First: first, Last: xproto.ButtonRelease},
+	}}
+	clients := []record.ClientSpec{record.CsAllClients}
+	if err := record.CreateContextChecked(ctrl, ctx, 0, uint32(len(clients)), uint32(len(ranges)), clients, ranges).Check(); err != nil {
+		ctrl.Close()
+		return fmt.Errorf("failed to create RECORD context: %w", err)
+	}
+
+	data, err := dialX11("")
+	if err != nil {
+		ctrl.Close()
+		return fmt.Errorf("failed to open RECORD data connection: %w", err)
+	}
+
+	ctrl.ExtLock.RLock()
+	opcode := ctrl.Extensions["RECORD"]
+	ctrl.ExtLock.RUnlock()
+
+	if err := data.enableContext(opcode, uint32(ctx)); err != nil {
+		data.Close()
+		ctrl.Close()
+		return fmt.Errorf("failed to enable RECORD context: %w", err)
+	}
+
+	s.ctrl = ctrl
+	s.data = data
+	s.context = ctx
+	s.keymap = keymap
+	return nil
+}
+
+func (s *recordSource) readEvents(events chan<- InputEvent, data *x11DataConn, dec *x11Decoder, stopChan, done chan struct{}) {
+	defer close(done)
+	defer close(events)
+
+	for {
+		reply, err := data.readRecordReply()
+		if err != nil {
+			select {
+			case <-stopChan:
+			default:
+				log.Printf("RECORD data connection failed: %v", err)
+			}
+			return
+		}
+
+		switch reply.Category {
+		case recordEndOfData:
+			return
+		case recordFromServer:
+		default:
+			continue
+		}
+
+		// Intercepted device events are plain 32-byte core events.
+		for buf := reply.Data; len(buf) >= 32; buf = buf[32:] {
+			ev, ok := dec.decode(buf)
+			if !ok {
+				continue
+			}
+
+			select {
+			case events <- ev:
+			case <-stopChan:
+				return
+			}
+		}
+	}
+}
+
+// x11Decoder turns intercepted core events into InputEvents. Recorded
+// device events do not carry a reliable modifier state, so it is tracked
+// from the modifier key events themselves.
+type x11Decoder struct {
+	keymap    *x11Keymap
+	clock     eventClock
+	modifiers Modifier
+	capsLock  bool
+}
+
+func (d *x11Decoder) decode(buf []byte) (InputEvent, bool) {
+	x := int(int16(binary.LittleEndian.Uint16(buf[20:])))
+	y := int(int16(binary.LittleEndian.Uint16(buf[22:])))
+	ev := InputEvent{
+		Position: image.Point{X: x, Y: y},
+		Time:     d.clock.at(binary.LittleEndian.Uint32(buf[4:])),
+	}
+
+	switch code := buf[0] & 0x7f; code {
+	case x11KeyPress, x11KeyRelease:
+		return d.decodeKey(ev, code == x11KeyPress, buf[1])
+	case x11ButtonPress, x11ButtonRelease:
+		return d.decodeButton(ev, code == x11ButtonPress, buf[1])
+	}
+	return InputEvent{}, false
+}
+
+// decodeButton handles button events. Wheel buttons become a single Scroll
+// event per notch.
+func (d *x11Decoder) decodeButton(ev InputEvent, press bool, detail byte) (InputEvent, bool) {
+	if delta, ok := x11ScrollButtons[detail]; ok {
+		if !press {
+			return InputEvent{}, false
+		}
+		ev.Kind = Scroll
+		ev.Delta = delta
+		return ev, true
+	}
+
+	button, ok := x11Buttons[detail]
+	if !ok {
+		return InputEvent{}, false
+	}
+	ev.Button = button
+	ev.Kind = ButtonRelease
+	if press {
+		ev.Kind = ButtonPress
+	}
+	return ev, true
+}
+
+func (d *x11Decoder) decodeKey(ev InputEvent, press bool, keycode byte) (InputEvent, bool) {
+	if d.keymap == nil {
+		return InputEvent{}, false
+	}
+
+	name, r := d.keymap.lookup(keycode, d.modifiers&ModShift != 0, d.capsLock)
+	if mod, ok := x11ModifierKeys[name]; ok {
+		if press {
+			d.modifiers |= mod
+		} else {
+			d.modifiers &^= mod
+		}
+	}
+	if name == "CapsLock" && press {
+		d.capsLock = !d.capsLock
+	}
+
+	ev.Kind = KeyRelease
+	if press {
+		ev.Kind = KeyPress
+	}
+	ev.Key = name
+	ev.Rune = r
+	ev.Modifiers = d.modifiers
+	return ev, true
+}
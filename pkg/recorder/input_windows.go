@@ -0,0 +1,145 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/config"
+)
+
+// Windows API constants
+const (
+	VK_CONTROL = 0x11
+	VK_MENU    = 0x12 // ALT key
+	VK_R       = 0x52
+	VK_LBUTTON = 0x01
+	VK_RBUTTON = 0x02
+	VK_MBUTTON = 0x04
+)
+
+// pollButtons lists the virtual keys polled for each pointer button.
+// Wheel movement is not visible to GetAsyncKeyState, so this source never
+// reports Scroll events.
+var pollButtons = []struct {
+	vk     uintptr
+	button Button
+}{
+	{VK_LBUTTON, ButtonLeft},
+	{VK_RBUTTON, ButtonRight},
+	{VK_MBUTTON, ButtonMiddle},
+}
+
+var (
+	user32           = syscall.NewLazyDLL("user32.dll")
+	getAsyncKeyState = user32.NewProc("GetAsyncKeyState")
+)
+
+// pollingSource detects button and key edges by polling GetAsyncKeyState.
+type pollingSource struct {
+	mu       sync.Mutex
+	stopChan chan struct{}
+}
+
+// newInputSource picks the Windows input source for the configured
+// backend.
+func newInputSource(backend string) InputSource {
+	if backend == config.InputFallback {
+		return &pollingSource{}
+	}
+	return newHookSource()
+}
+
+func (s *pollingSource) Start(keyboard bool) (<-chan InputEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan != nil {
+		return nil, fmt.Errorf("input source already started")
+	}
+
+	events := make(chan InputEvent, 64)
+	s.stopChan = make(chan struct{})
+
+	go s.poll(events, keyboard, s.stopChan)
+	return events, nil
+}
+
+func (s *pollingSource) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan == nil {
+		return fmt.Errorf("input source not started")
+	}
+	close(s.stopChan)
+	s.stopChan = nil
+	return nil
+}
+
+func (s *pollingSource) poll(events chan<- InputEvent, keyboard bool, stopChan chan struct{}) {
+	defer close(events)
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastMouseState := make([]bool, len(pollButtons))
+	lastKeyState := make(map[uintptr]bool)
+
+	send := func(ev InputEvent) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-stopChan:
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			for i, b := range pollButtons {
+				mouseState, _, _ := getAsyncKeyState.Call(b.vk)
+				isMouseDown := mouseState&0x8000 != 0
+
+				if isMouseDown != lastMouseState[i] {
+					ev := InputEvent{
+						Kind:     ButtonRelease,
+						Button:   b.button,
+						Position: cursorPos(),
+						Time:     time.Now(),
+					}
+					if isMouseDown {
+						ev.Kind = ButtonPress
+					}
+					if !send(ev) {
+						return
+					}
+				}
+				lastMouseState[i] = isMouseDown
+			}
+
+			if !keyboard {
+				continue
+			}
+			for _, vk := range vkPollOrder {
+				isKeyDown := isKeyDown(vk)
+				if isKeyDown != lastKeyState[vk] {
+					ev := keyEvent(vk, isKeyDown)
+					ev.Position = cursorPos()
+					ev.Time = time.Now()
+					if !send(ev) {
+						return
+					}
+				}
+				lastKeyState[vk] = isKeyDown
+			}
+		}
+	}
+}
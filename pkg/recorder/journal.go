@@ -0,0 +1,314 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// journalName is the file in a session directory that steps are appended
+// to as they are recorded, so a recording survives GoStep crashing or
+// the machine going down.
+const journalName = "journal.jsonl"
+
+// maxJournalLine bounds one journal line when reading; a step with a large
+// pointer image is still far below it.
+const maxJournalLine = 16 << 20
+
+// journalEntry is one line of the journal: the state of the step at Index
+// when the line was written, encoded by model.MarshalStep with its
+// screenshots named by their file in the session directory. A later line
+// for the same index replaces it.
+type journalEntry struct {
+	Version int             `json:"version"`
+	Index   int             `json:"index"`
+	Step    json.RawMessage `json:"step"`
+}
+
+// journal appends entries to the journal of a recording. Queuing an entry
+// never waits on the disk: entries are collected in memory and written by
+// one goroutine, in the order they were queued, which syncs the file once
+// for everything that was waiting rather than once per entry.
+//
+// Each line starts with the CRC-32 of its entry. A crash can only tear the
+// last line, and a torn line fails its checksum, so reading stops there.
+type journal struct {
+	f    *os.File
+	wake chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	pending []byte
+	closed  bool
+}
+
+func openJournal(dir string) (*journal, error) {
+	f, err := os.OpenFile(filepath.Join(dir, journalName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal: %w", err)
+	}
+	j := &journal{f: f, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go j.run()
+	return j, nil
+}
+
+func (j *journal) run() {
+	defer close(j.done)
+
+	failed := false
+	for range j.wake {
+		j.mu.Lock()
+		batch, closed := j.pending, j.closed
+		j.pending = nil
+		j.mu.Unlock()
+
+		if len(batch) > 0 && !failed {
+			_, err := j.f.Write(batch)
+			if err == nil {
+				err = j.f.Sync()
+			}
+			if err != nil {
+				// Keep recording; only the autosave is lost.
+				log.Printf("Failed to write journal, steps are no longer saved as they are recorded: %v", err)
+				failed = true
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// add queues a line for writing.
+func (j *journal) add(line []byte) {
+	j.mu.Lock()
+	j.pending = append(j.pending, line...)
+	j.mu.Unlock()
+	j.signal()
+}
+
+func (j *journal) signal() {
+	select {
+	case j.wake <- struct{}{}:
+	default:
+		// The writer has yet to pick up an earlier signal, and will take
+		// this line with it.
+	}
+}
+
+// close writes the queued entries and closes the journal.
+func (j *journal) close() {
+	j.mu.Lock()
+	j.closed = true
+	j.mu.Unlock()
+	j.signal()
+	<-j.done
+	j.f.Close()
+}
+
+// journalStep queues the step at index for the journal. r.mu must be held,
+// which keeps the entries for a step in the order its changes were made;
+// queuing does not block, so holding it costs nothing. A step is only
+// written once its screenshot is stored, and again when it changes after
+// that.
+func (r *Recorder) journalStep(index int) {
+	if r.journal == nil || r.steps[index].Screenshot == nil {
+		return
+	}
+	line, err := journalLine(index, r.steps[index])
+	if err != nil {
+		log.Printf("Failed to journal step %d: %v", index+1, err)
+		return
+	}
+	r.journal.add(line)
+}
+
+// finishJournal closes the journal once the recording stopped, and
+// replaces it with the final steps, so a crash before they are exported
+// recovers them without the steps that were dropped or merged. Edits made
+// after that are not journaled; a recovered recording has its steps as
+// they were when the recording stopped.
+func (r *Recorder) finishJournal() {
+	if r.journal == nil {
+		return
+	}
+	r.journal.close()
+	r.journal = nil
+
+	r.mu.Lock()
+	steps := r.steps
+	r.mu.Unlock()
+	if err := writeJournal(r.session.Dir(), steps); err != nil {
+		log.Printf("Failed to update journal: %v", err)
+	}
+}
+
+func journalLine(index int, step model.Step) ([]byte, error) {
+	data, err := model.MarshalStep(step, screenshotName)
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(journalEntry{Version: model.SchemaVersion, Index: index, Step: data})
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, "%08x %s\n", crc32.ChecksumIEEE(data), data), nil
+}
+
+// screenshotName names a screenshot by its file in the session directory.
+func screenshotName(img *store.Image) string {
+	return filepath.Base(img.Path())
+}
+
+// writeJournal replaces the journal in dir with one holding steps. The new
+// journal is synced before it is renamed over the old one, so one of the
+// two is always complete.
+func writeJournal(dir string, steps []model.Step) error {
+	var buf bytes.Buffer
+	for i, step := range steps {
+		line, err := journalLine(i, step)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+	}
+
+	tmp := filepath.Join(dir, journalName+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, journalName))
+}
+
+// readJournal returns the steps in the journal of session, in recording
+// order. Steps whose screenshot did not survive are left out.
+func readJournal(session *store.Session) ([]model.Step, error) {
+	f, err := os.Open(filepath.Join(session.Dir(), journalName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	latest := make(map[int]journalEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxJournalLine)
+	for scanner.Scan() {
+		entry, ok := parseJournalLine(scanner.Bytes())
+		if !ok {
+			// Everything after a torn line is suspect.
+			break
+		}
+		latest[entry.Index] = entry
+	}
+
+	indexes := make([]int, 0, len(latest))
+	for i := range latest {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var steps []model.Step
+	for _, i := range indexes {
+		step, err := model.UnmarshalStep(latest[i].Step, session.Image)
+		if err != nil {
+			log.Printf("Cannot recover step %d: %v", i+1, err)
+			continue
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func parseJournalLine(line []byte) (journalEntry, bool) {
+	var entry journalEntry
+	var sum uint32
+	sumText, data, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return entry, false
+	}
+	if _, err := fmt.Sscanf(string(sumText), "%08x", &sum); err != nil || sum != crc32.ChecksumIEEE(data) {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Index < 0 {
+		return entry, false
+	}
+	if model.CheckVersion(entry.Version) != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// Unfinished is a recording left behind by a GoStep that crashed, was
+// killed or lost power before the recording was exported or discarded.
+type Unfinished struct {
+	// Session holds the screenshots. The caller owns it and should remove
+	// it once the steps are recovered or discarded.
+	Session   *store.Session
+	Recording *model.Recording
+}
+
+// FindUnfinished returns the unfinished recordings in the output directory,
+// oldest first. Recordings without a step to recover are skipped. Call it
+// before starting a recording: it cannot tell a recording another running
+// GoStep is making from one that was cut short.
+func FindUnfinished(settings *config.Settings) ([]Unfinished, error) {
+	root := filepath.Join(settings.OutputDir, sessionDirName)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for unfinished recordings: %w", err)
+	}
+
+	var found []Unfinished
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "session_") {
+			continue
+		}
+		session, err := store.OpenSession(filepath.Join(root, e.Name()))
+		if err != nil {
+			log.Printf("Skipping %s: %v", e.Name(), err)
+			continue
+		}
+		steps, err := readJournal(session)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Skipping %s: %v", e.Name(), err)
+			}
+			continue
+		}
+		if len(steps) > 0 {
+			rec := &model.Recording{Started: steps[0].Timestamp, Steps: steps}
+			found = append(found, Unfinished{Session: session, Recording: rec})
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,163 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// journalSession returns a session holding a screenshot, and steps that
+// show it with the given descriptions.
+func journalSession(t *testing.T, descriptions ...string) (*store.Session, []model.Step) {
+	t.Helper()
+	session, err := store.NewSession(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shot, err := session.Put(image.NewRGBA(image.Rect(0, 0, 4, 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := make([]model.Step, len(descriptions))
+	for i, d := range descriptions {
+		steps[i] = model.Step{Screenshot: shot, Description: d, Action: ActionClick}
+	}
+	return session, steps
+}
+
+func descriptions(steps []model.Step) []string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Description
+	}
+	return names
+}
+
+func TestJournal(t *testing.T) {
+	line := func(t *testing.T, index int, step model.Step) []byte {
+		t.Helper()
+		l, err := journalLine(index, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+
+	tests := []struct {
+		name string
+		// extra is appended to a journal of the steps "one" and "two".
+		extra func(t *testing.T, steps []model.Step) []byte
+		want  []string
+	}{
+		{
+			name:  "complete",
+			extra: func(*testing.T, []model.Step) []byte { return nil },
+			want:  []string{"one", "two"},
+		},
+		{
+			name: "torn last line",
+			extra: func(t *testing.T, steps []model.Step) []byte {
+				l := line(t, 2, steps[0])
+				return l[:len(l)/2]
+			},
+			want: []string{"one", "two"},
+		},
+		{
+			name: "checksum mismatch",
+			extra: func(t *testing.T, steps []model.Step) []byte {
+				step := steps[0]
+				step.Description = "three"
+				l := line(t, 2, step)
+				l = bytes.Replace(l, []byte("three"), []byte("thre3"), 1)
+				return append(l, line(t, 3, steps[0])...)
+			},
+			want: []string{"one", "two"},
+		},
+		{
+			name: "later line replaces earlier",
+			extra: func(t *testing.T, steps []model.Step) []byte {
+				step := steps[0]
+				step.Description = "edited"
+				return line(t, 0, step)
+			},
+			want: []string{"edited", "two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, steps := journalSession(t, "one", "two")
+			if err := writeJournal(session.Dir(), steps); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(filepath.Join(session.Dir(), journalName), os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = f.Write(tt.extra(t, steps))
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readJournal(session)
+			if err != nil {
+				t.Fatalf("readJournal: %v", err)
+			}
+			if !slices.Equal(descriptions(got), tt.want) {
+				t.Errorf("steps = %q, want %q", descriptions(got), tt.want)
+			}
+			for i, step := range got {
+				if step.Screenshot == nil || step.Screenshot.Bounds() != steps[0].Screenshot.Bounds() {
+					t.Errorf("step %d: screenshot %v not recovered", i+1, step.Screenshot)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJournalLine(t *testing.T) {
+	_, steps := journalSession(t, "one")
+	good, err := journalLine(4, steps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := parseJournalLine(bytes.TrimSuffix(good, []byte("\n")))
+	if !ok || entry.Index != 4 || entry.Version != model.SchemaVersion {
+		t.Fatalf("parseJournalLine = %+v, %v, want index 4 at version %d", entry, ok, model.SchemaVersion)
+	}
+
+	// withSum prefixes data with its checksum, so only the entry itself is
+	// at fault.
+	withSum := func(entry journalEntry) []byte {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Appendf(nil, "%08x %s", crc32.ChecksumIEEE(data), data)
+	}
+	tests := []struct {
+		name string
+		line []byte
+	}{
+		{"empty", nil},
+		{"no checksum", []byte(`{"version":1,"index":0,"step":{}}`)},
+		{"checksum mismatch", append([]byte("00000000"), good[8:]...)},
+		{"not JSON", []byte(fmt.Sprintf("%08x nope", crc32.ChecksumIEEE([]byte("nope"))))},
+		{"negative index", withSum(journalEntry{Version: model.SchemaVersion, Index: -1, Step: entry.Step})},
+		{"unknown version", withSum(journalEntry{Version: model.SchemaVersion + 1, Index: 0, Step: entry.Step})},
+	}
+	for _, tt := range tests {
+		if _, ok := parseJournalLine(tt.line); ok {
+			t.Errorf("%s: parseJournalLine(%q) succeeded", tt.name, tt.line)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// Modifier is a set of keyboard modifiers held during a key event.
+type Modifier int
+
+const (
+	ModShift Modifier = 1 << iota
+	ModCtrl
+	ModAlt
+	ModSuper
+)
+
+var modifierNames = []struct {
+	mod  Modifier
+	name string
+}{
+	{ModCtrl, "Ctrl"},
+	{ModAlt, "Alt"},
+	{ModShift, "Shift"},
+	{ModSuper, "Super"},
+}
+
+// String formats the modifiers the way shortcuts are usually written,
+// e.g. "Ctrl+Shift".
+func (m Modifier) String() string {
+	var names []string
+	for _, n := range modifierNames {
+		if m&n.mod != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// Focus describes the element that receives keyboard input.
+type Focus struct {
+	// ID identifies the focused element. Keys typed while it stays the
+	// same are grouped into one step.
+	ID          uint64
+	WindowTitle string
+	// Password is set when the platform reports a password entry field.
+	Password bool
+}
+
+// maskedText replaces typed text in steps whose input must not be kept.
+// It has a fixed length so the step does not reveal how long the secret is.
+const maskedText = "********"
+
+// typingRun collects the characters typed into one focused element.
+type typingRun struct {
+	focus  Focus
+	window model.Window
+	masked bool
+	text   []rune
+	pos    image.Point
+	start  time.Time
+}
+
+// shortcutKeys end a typing run and are recorded as their own step even
+// without modifiers.
+var shortcutKeys = map[string]bool{
+	"Enter":  true,
+	"Tab":    true,
+	"Escape": true,
+}
+
+var modifierKeys = map[string]bool{
+	"Shift": true,
+	"Ctrl":  true,
+	"Alt":   true,
+	"Super": true,
+}
+
+func (r *Recorder) handleKey(ev InputEvent) {
+	if !r.keyboard || ev.Kind != KeyPress || modifierKeys[ev.Key] || r.isHotkey(ev) {
+		return
+	}
+
+	if ev.Modifiers&(ModCtrl|ModAlt|ModSuper) != 0 || shortcutKeys[ev.Key] {
+		r.flushTyping()
+		r.recordKeyStep(ev)
+		return
+	}
+
+	if ev.Key == "Backspace" {
+		if r.typing != nil && len(r.typing.text) > 0 {
+			r.typing.text = r.typing.text[:len(r.typing.text)-1]
+		}
+		return
+	}
+
+	if ev.Rune == 0 || !unicode.IsPrint(ev.Rune) {
+		return
+	}
+
+	focus, known := r.focus()
+	if r.typing != nil && r.typing.focus.ID != focus.ID {
+		r.flushTyping()
+	}
+	if r.typing == nil {
+		r.typing = &typingRun{
+			focus: focus,
+			// Without focus information there is no way to tell whether
+			// this is a password field, so the run is masked.
+			masked: !known || focus.Password || r.isDenied(focus.WindowTitle),
+			window: r.activeWindow(),
+			pos:    ev.Position,
+			start:  ev.Time,
+		}
+	}
+
+	// Masked runs never hold on to what was typed.
+	if !r.typing.masked {
+		r.typing.text = append(r.typing.text, ev.Rune)
+	}
+}
+
+// recordKeyStep records a shortcut chord or a terminating key as a step.
+func (r *Recorder) recordKeyStep(ev InputEvent) {
+	name := ev.Key
+	if name == "" && ev.Rune != 0 {
+		name = string(ev.Rune)
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		name = strings.ToUpper(name)
+	}
+	if ev.Modifiers != 0 {
+		name = ev.Modifiers.String() + "+" + name
+	}
+
+	s := r.requestShot(ev.Position, ev.Time)
+	if s == nil {
+		return
+	}
+	r.appendStep(model.Step{
+		Timestamp:   ev.Time,
+		Action:      "Press " + name,
+		Coordinates: ev.Position,
+	}, s, nil)
+}
+
+// flushTyping turns the current typing run into a step. The screenshot is
+// taken now so it shows the text that was entered.
+func (r *Recorder) flushTyping() {
+	run := r.typing
+	r.typing = nil
+	if run == nil || (!run.masked && len(run.text) == 0) {
+		return
+	}
+
+	text := string(run.text)
+	if run.masked {
+		text = maskedText
+	}
+
+	// The run's window is captured even if the focus has moved on.
+	s := r.requestShotIn(run.window, run.pos, time.Now())
+	if s == nil {
+		return
+	}
+	r.appendStep(model.Step{
+		Timestamp:   run.start,
+		Action:      fmt.Sprintf("Type '%s'", text),
+		Coordinates: run.pos,
+	}, s, nil)
+}
+
+// focus queries the keyboard focus. It reports false when the backend
+// could not determine it.
+func (r *Recorder) focus() (Focus, bool) {
+	if r.backend.Windows == nil {
+		return Focus{}, false
+	}
+	focus, err := r.backend.Windows.Focus()
+	if err != nil {
+		log.Printf("Failed to query keyboard focus: %v", err)
+		return Focus{}, false
+	}
+	return focus, true
+}
+
+func (r *Recorder) isDenied(title string) bool {
+	for _, re := range r.denyList {
+		if re.MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
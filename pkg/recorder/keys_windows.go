@@ -0,0 +1,169 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"unicode"
+	"unsafe"
+)
+
+const (
+	VK_SHIFT   = 0x10
+	VK_CAPITAL = 0x14
+)
+
+var (
+	getKeyState      = user32.NewProc("GetKeyState")
+	mapVirtualKeyW   = user32.NewProc("MapVirtualKeyW")
+	toUnicode        = user32.NewProc("ToUnicode")
+	vkModifierStates = []struct {
+		vk  uintptr
+		mod Modifier
+	}{
+		{VK_SHIFT, ModShift},
+		{VK_CONTROL, ModCtrl},
+		{VK_MENU, ModAlt},
+		{0x5B, ModSuper}, // VK_LWIN
+		{0x5C, ModSuper}, // VK_RWIN
+	}
+)
+
+// vkNames names the virtual keys reported by the polling source. Letters
+// and digits are added in init. The generic VK_SHIFT, VK_CONTROL and
+// VK_MENU codes are left out because their left/right variants already
+// report the same press.
+var vkNames = map[uintptr]string{
+	0x08: "Backspace",
+	0x09: "Tab",
+	0x0D: "Enter",
+	0x14: "CapsLock",
+	0x1B: "Escape",
+	0x20: "Space",
+	0x21: "PageUp",
+	0x22: "PageDown",
+	0x23: "End",
+	0x24: "Home",
+	0x25: "Left",
+	0x26: "Up",
+	0x27: "Right",
+	0x28: "Down",
+	0x2D: "Insert",
+	0x2E: "Delete",
+	0x5B: "Super",
+	0x5C: "Super",
+	0xA0: "Shift",
+	0xA1: "Shift",
+	0xA2: "Ctrl",
+	0xA3: "Ctrl",
+	0xA4: "Alt",
+	0xA5: "Alt",
+	// Punctuation keys are named by the character they produce.
+	0xBA: "", 0xBB: "", 0xBC: "", 0xBD: "", 0xBE: "", 0xBF: "", 0xC0: "",
+	0xDB: "", 0xDC: "", 0xDD: "", 0xDE: "", 0xE2: "",
+}
+
+// vkPollOrder is the order the polling source checks vkNames in: the
+// modifiers first, so a shortcut pressed between two polls is reported
+// with its modifier already down, then the rest by virtual key code.
+var vkPollOrder []uintptr
+
+func init() {
+	for vk := uintptr('0'); vk <= '9'; vk++ {
+		vkNames[vk] = string(rune(vk))
+	}
+	for vk := uintptr('A'); vk <= 'Z'; vk++ {
+		vkNames[vk] = string(rune(unicode.ToLower(rune(vk))))
+	}
+	for n := uintptr(0); n < 12; n++ {
+		vkNames[0x70+n] = "F" + strconv.Itoa(int(n)+1) // VK_F1..VK_F12
+	}
+	for n := uintptr(0); n < 10; n++ {
+		vkNames[0x60+n] = string(rune('0' + n)) // VK_NUMPAD0..VK_NUMPAD9
+	}
+
+	vkPollOrder = slices.SortedFunc(maps.Keys(vkNames), func(a, b uintptr) int {
+		if ma, mb := isModifierVK(a), isModifierVK(b); ma != mb {
+			if ma {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a, b)
+	})
+}
+
+// isModifierVK reports whether vk is one of the modifier keys.
+func isModifierVK(vk uintptr) bool {
+	switch vkNames[vk] {
+	case "Shift", "Ctrl", "Alt", "Super":
+		return true
+	}
+	return false
+}
+
+func isKeyDown(vk uintptr) bool {
+	state, _, _ := getAsyncKeyState.Call(vk)
+	return state&0x8000 != 0
+}
+
+// currentModifiers reads the modifier keys held right now.
+func currentModifiers() Modifier {
+	var mods Modifier
+	for _, m := range vkModifierStates {
+		if isKeyDown(m.vk) {
+			mods |= m.mod
+		}
+	}
+	return mods
+}
+
+// keyEvent describes a key press or release of vk, translating it to the
+// character it types under the current modifier and caps lock state.
+func keyEvent(vk uintptr, press bool) InputEvent {
+	ev := InputEvent{
+		Kind:      KeyRelease,
+		Key:       vkNames[vk],
+		Modifiers: currentModifiers(),
+	}
+	if press {
+		ev.Kind = KeyPress
+	}
+
+	var state [256]byte
+	if ev.Modifiers&ModShift != 0 {
+		state[VK_SHIFT] = 0x80
+	}
+	if ev.Modifiers&ModCtrl != 0 {
+		state[VK_CONTROL] = 0x80
+	}
+	if ev.Modifiers&ModAlt != 0 {
+		state[VK_MENU] = 0x80
+	}
+	if caps, _, _ := getKeyState.Call(VK_CAPITAL); caps&1 != 0 {
+		state[VK_CAPITAL] = 0x01
+	}
+
+	scan, _, _ := mapVirtualKeyW.Call(vk, 0)
+	var buf [8]uint16
+	// Flag 0x4 keeps ToUnicode from changing the dead-key state of the
+	// thread that is actually receiving the input.
+	n, _, _ := toUnicode.Call(vk, scan, uintptr(unsafe.Pointer(&state[0])),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0x4)
+	if int32(n) == 1 && unicode.IsPrint(rune(buf[0])) {
+		ev.Rune = rune(buf[0])
+		// AltGr is reported as Ctrl+Alt; when it produces a character it
+		// is typing, not a shortcut.
+		if ev.Modifiers&(ModCtrl|ModAlt) == ModCtrl|ModAlt {
+			ev.Modifiers &^= ModCtrl | ModAlt
+		}
+	}
+	if ev.Key == "" && ev.Rune != 0 {
+		ev.Key = string(ev.Rune)
+	}
+	return ev
+}
@@ -0,0 +1,123 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"strconv"
+	"unicode"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// x11KeyNames names the keysyms that do not produce text, plus the few
+// text keys that read better by name.
+var x11KeyNames = map[xproto.Keysym]string{
+	0x0020: "Space",
+	0xff08: "Backspace",
+	0xff09: "Tab",
+	0xff0d: "Enter",
+	0xff1b: "Escape",
+	0xff50: "Home",
+	0xff51: "Left",
+	0xff52: "Up",
+	0xff53: "Right",
+	0xff54: "Down",
+	0xff55: "PageUp",
+	0xff56: "PageDown",
+	0xff57: "End",
+	0xff63: "Insert",
+	0xff8d: "Enter",
+	0xffff: "Delete",
+	0xffe1: "Shift",
+	0xffe2: "Shift",
+	0xffe3: "Ctrl",
+	0xffe4: "Ctrl",
+	0xffe5: "CapsLock",
+	0xffe7: "Alt",
+	0xffe8: "Alt",
+	0xffe9: "Alt",
+	0xffea: "Alt",
+	0xffeb: "Super",
+	0xffec: "Super",
+	0xfe03: "AltGr",
+}
+
+// x11ModifierKeys maps modifier key names to the modifier they hold.
+var x11ModifierKeys = map[string]Modifier{
+	"Shift": ModShift,
+	"Ctrl":  ModCtrl,
+	"Alt":   ModAlt,
+	"Super": ModSuper,
+}
+
+// x11Keymap translates keycodes into key names and characters.
+type x11Keymap struct {
+	minKeycode xproto.Keycode
+	perKeycode int
+	keysyms    []xproto.Keysym
+}
+
+func loadKeymap(c *xgb.Conn) (*x11Keymap, error) {
+	setup := xproto.Setup(c)
+	count := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+	reply, err := xproto.GetKeyboardMapping(c, setup.MinKeycode, count).Reply()
+	if err != nil {
+		return nil, err
+	}
+	return &x11Keymap{
+		minKeycode: setup.MinKeycode,
+		perKeycode: int(reply.KeysymsPerKeycode),
+		keysyms:    reply.Keysyms,
+	}, nil
+}
+
+// lookup returns the name of the key and the character it produces with
+// the given shift and caps lock state.
+func (k *x11Keymap) lookup(keycode byte, shift, capsLock bool) (string, rune) {
+	i := (int(keycode) - int(k.minKeycode)) * k.perKeycode
+	if keycode < byte(k.minKeycode) || i+k.perKeycode > len(k.keysyms) || k.perKeycode == 0 {
+		return "", 0
+	}
+	syms := k.keysyms[i : i+k.perKeycode]
+
+	base := syms[0]
+	sym := base
+	if shift && len(syms) > 1 && syms[1] != 0 {
+		sym = syms[1]
+	}
+
+	r := keysymRune(sym)
+	if capsLock && unicode.IsLetter(r) {
+		if shift {
+			r = unicode.ToLower(r)
+		} else {
+			r = unicode.ToUpper(r)
+		}
+	}
+
+	if name, ok := x11KeyNames[base]; ok {
+		return name, r
+	}
+	if b := keysymRune(base); b != 0 {
+		return string(b), r
+	}
+	if base >= 0xffbe && base <= 0xffc9 {
+		return "F" + strconv.Itoa(int(base-0xffbe)+1), 0
+	}
+	return "", r
+}
+
+// keysymRune returns the character a keysym stands for, or 0.
+func keysymRune(sym xproto.Keysym) rune {
+	switch {
+	case sym >= 0x20 && sym <= 0x7e, sym >= 0xa0 && sym <= 0xff:
+		return rune(sym)
+	case sym >= 0xffb0 && sym <= 0xffb9:
+		return rune('0' + sym - 0xffb0)
+	case sym&0xff000000 == 0x01000000:
+		return rune(sym & 0x00ffffff)
+	}
+	return 0
+}
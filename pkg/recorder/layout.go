@@ -0,0 +1,155 @@
+package recorder
+
+import (
+	"image"
+	"math"
+)
+
+// display is one monitor of the virtual desktop: its bounds in physical
+// pixels, the ratio of its physical pixels to 96 DPI logical pixels, and
+// where it starts in logical pixels.
+type display struct {
+	bounds image.Rectangle
+	scale  float64
+	origin image.Point
+}
+
+// layout is the arrangement of the displays when a screenshot was taken.
+// Monitors can have different scales, so a position can only be converted
+// between physical and logical pixels knowing which display it is on.
+type layout []display
+
+// displayLayout reads the current displays and their scales from the
+// screen capturer.
+func (r *Recorder) displayLayout() layout {
+	bounds := r.backend.Screen.Displays()
+	displays := make([]display, len(bounds))
+	for i, b := range bounds {
+		displays[i] = display{bounds: b, scale: r.backend.Screen.Scale(b)}
+	}
+	return newLayout(displays)
+}
+
+// newLayout arranges displays in logical pixels, the way the desktop
+// appears to an application that is not DPI aware: every display shrinks
+// by its scale, and they stay side by side as they are physically. The
+// primary display, the one at the origin, stays there; every other
+// display is placed against a neighbour that is already placed, keeping
+// its offset along the shared edge in the neighbour's logical pixels.
+// Windows does not document how it lays out scaled displays, so this is
+// an approximation that is exact for displays placed in a row or column.
+// A display that touches no other keeps its physical origin.
+func newLayout(displays []display) layout {
+	l := make(layout, len(displays))
+	copy(l, displays)
+	if len(l) == 0 {
+		return l
+	}
+
+	primary := 0
+	for i, d := range l {
+		if (image.Point{}).In(d.bounds) {
+			primary = i
+			break
+		}
+	}
+	placed := make([]bool, len(l))
+	l[primary].origin = l[primary].bounds.Min
+	placed[primary] = true
+
+	for progress := true; progress; {
+		progress = false
+		for i := range l {
+			if placed[i] {
+				continue
+			}
+			for j := range l {
+				if !placed[j] {
+					continue
+				}
+				if origin, ok := l[j].neighbour(l[i]); ok {
+					l[i].origin = origin
+					placed[i], progress = true, true
+					break
+				}
+			}
+		}
+	}
+	for i := range l {
+		if !placed[i] {
+			l[i].origin = l[i].bounds.Min
+		}
+	}
+	return l
+}
+
+// neighbour returns the logical origin of n when it shares an edge with d,
+// which has been placed.
+func (d display) neighbour(n display) (image.Point, bool) {
+	size := n.logicalSize()
+	overlapsX := n.bounds.Min.X < d.bounds.Max.X && d.bounds.Min.X < n.bounds.Max.X
+	overlapsY := n.bounds.Min.Y < d.bounds.Max.Y && d.bounds.Min.Y < n.bounds.Max.Y
+
+	switch {
+	case n.bounds.Min.X == d.bounds.Max.X && overlapsY:
+		return image.Pt(d.origin.X+d.logicalSize().X, d.origin.Y+d.logical(n.bounds.Min.Y-d.bounds.Min.Y)), true
+	case n.bounds.Max.X == d.bounds.Min.X && overlapsY:
+		return image.Pt(d.origin.X-size.X, d.origin.Y+d.logical(n.bounds.Min.Y-d.bounds.Min.Y)), true
+	case n.bounds.Min.Y == d.bounds.Max.Y && overlapsX:
+		return image.Pt(d.origin.X+d.logical(n.bounds.Min.X-d.bounds.Min.X), d.origin.Y+d.logicalSize().Y), true
+	case n.bounds.Max.Y == d.bounds.Min.Y && overlapsX:
+		return image.Pt(d.origin.X+d.logical(n.bounds.Min.X-d.bounds.Min.X), d.origin.Y-size.Y), true
+	}
+	return image.Point{}, false
+}
+
+// logicalSize returns the size of the display in logical pixels.
+func (d display) logicalSize() image.Point {
+	return image.Pt(d.logical(d.bounds.Dx()), d.logical(d.bounds.Dy()))
+}
+
+// logical converts a physical length on the display to logical pixels.
+func (d display) logical(v int) int {
+	if d.scale <= 0 {
+		return v
+	}
+	return int(math.Round(float64(v) / d.scale))
+}
+
+// at returns the display containing p.
+func (l layout) at(p image.Point) (display, bool) {
+	for _, d := range l {
+		if p.In(d.bounds) {
+			return d, true
+		}
+	}
+	return display{}, false
+}
+
+// scaleAt returns the scale of the display containing pos, or of the
+// display overlapping area the most when pos is on none of them.
+func (l layout) scaleAt(pos image.Point, area image.Rectangle) float64 {
+	if d, ok := l.at(pos); ok {
+		return d.scale
+	}
+	scale, most := 1.0, 0
+	for _, d := range l {
+		overlap := d.bounds.Intersect(area)
+		if n := overlap.Dx() * overlap.Dy(); n > most {
+			scale, most = d.scale, n
+		}
+	}
+	return scale
+}
+
+// logical converts a physical position to logical pixels: its offset into
+// its display is divided by the display's scale and added to the display's
+// logical origin. Positions off every display are returned unchanged.
+func (l layout) logical(p image.Point) image.Point {
+	d, ok := l.at(p)
+	if !ok {
+		return p
+	}
+	off := p.Sub(d.bounds.Min)
+	return d.origin.Add(image.Pt(d.logical(off.X), d.logical(off.Y)))
+}
@@ -0,0 +1,174 @@
+package recorder
+
+import (
+	"image"
+	"testing"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+)
+
+func TestLayoutLogical(t *testing.T) {
+	tests := []struct {
+		name     string
+		displays []display
+		physical image.Point
+		logical  image.Point
+	}{
+		{
+			name:     "single scaled display",
+			displays: []display{{bounds: image.Rect(0, 0, 3840, 2160), scale: 2}},
+			physical: image.Pt(1000, 1000),
+			logical:  image.Pt(500, 500),
+		},
+		{
+			name: "150% to the right of 100% primary",
+			displays: []display{
+				{bounds: image.Rect(0, 0, 1920, 1080), scale: 1},
+				{bounds: image.Rect(1920, 0, 5760, 2160), scale: 1.5},
+			},
+			physical: image.Pt(1920+1500, 300),
+			logical:  image.Pt(1920+1000, 200),
+		},
+		{
+			name: "100% to the right of 150% primary",
+			displays: []display{
+				{bounds: image.Rect(0, 0, 3840, 2160), scale: 1.5},
+				{bounds: image.Rect(3840, 0, 5760, 1080), scale: 1},
+			},
+			physical: image.Pt(3840+100, 50),
+			logical:  image.Pt(2560+100, 50),
+		},
+		{
+			name: "150% to the left of 100% primary",
+			displays: []display{
+				{bounds: image.Rect(0, 0, 1920, 1080), scale: 1},
+				{bounds: image.Rect(-3840, 0, 0, 2160), scale: 1.5},
+			},
+			physical: image.Pt(-3840, 300),
+			logical:  image.Pt(-2560, 200),
+		},
+		{
+			name: "100% below 200% primary, offset along the edge",
+			displays: []display{
+				{bounds: image.Rect(0, 0, 3840, 2160), scale: 2},
+				{bounds: image.Rect(1920, 2160, 3840, 3240), scale: 1},
+			},
+			physical: image.Pt(1920+10, 2160+20),
+			logical:  image.Pt(960+10, 1080+20),
+		},
+		{
+			name: "placed through a neighbour",
+			displays: []display{
+				{bounds: image.Rect(5760, 0, 7680, 1080), scale: 1},
+				{bounds: image.Rect(1920, 0, 5760, 2160), scale: 2},
+				{bounds: image.Rect(0, 0, 1920, 1080), scale: 1},
+			},
+			physical: image.Pt(5760+100, 100),
+			logical:  image.Pt(3840+100, 100),
+		},
+		{
+			name: "below a display of unknown scale, offset along the edge",
+			displays: []display{
+				{bounds: image.Rect(0, 0, 1920, 1080), scale: 0},
+				{bounds: image.Rect(960, 1080, 2880, 2160), scale: 1},
+			},
+			physical: image.Pt(960+10, 1080+20),
+			logical:  image.Pt(960+10, 1080+20),
+		},
+		{
+			name: "display touching no other keeps its origin",
+			displays: []display{
+				{bounds: image.Rect(0, 0, 1000, 1000), scale: 1},
+				{bounds: image.Rect(5000, 0, 6000, 1000), scale: 2},
+			},
+			physical: image.Pt(5200, 200),
+			logical:  image.Pt(5100, 100),
+		},
+		{
+			name:     "off every display",
+			displays: []display{{bounds: image.Rect(0, 0, 1000, 1000), scale: 2}},
+			physical: image.Pt(-10, 5000),
+			logical:  image.Pt(-10, 5000),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newLayout(tt.displays).logical(tt.physical); got != tt.logical {
+				t.Errorf("logical(%v) = %v, want %v", tt.physical, got, tt.logical)
+			}
+		})
+	}
+}
+
+func TestLayoutScaleAt(t *testing.T) {
+	l := newLayout([]display{
+		{bounds: image.Rect(0, 0, 1920, 1080), scale: 1},
+		{bounds: image.Rect(1920, 0, 5760, 2160), scale: 1.5},
+	})
+	tests := []struct {
+		name  string
+		pos   image.Point
+		area  image.Rectangle
+		scale float64
+	}{
+		{"on primary", image.Pt(10, 10), image.Rect(0, 0, 1920, 1080), 1},
+		{"on secondary", image.Pt(2000, 10), image.Rect(1920, 0, 5760, 2160), 1.5},
+		{"off screen, area mostly on secondary", image.Pt(-1, -1), image.Rect(1800, 0, 2400, 100), 1.5},
+		{"off screen, area on no display", image.Pt(-1, -1), image.Rect(-500, -500, -100, -100), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.scaleAt(tt.pos, tt.area); got != tt.scale {
+				t.Errorf("scaleAt(%v, %v) = %v, want %v", tt.pos, tt.area, got, tt.scale)
+			}
+		})
+	}
+}
+
+func TestClickMarkerMixedDPI(t *testing.T) {
+	primary := image.Rect(0, 0, 3840, 2160)
+	secondary := image.Rect(3840, 0, 5760, 1080)
+	b := newTestBackend(primary, secondary)
+	b.screen.SetDisplayScale(primary, 1.5)
+	b.screen.SetDisplayScale(secondary, 1)
+
+	steps := b.record(t, testSettings(t), func(r *Recorder) {
+		b.input.Click(ButtonLeft, 300, 150)
+		b.input.Click(ButtonRight, 3840+100, 50)
+	})
+	if len(steps) != 2 {
+		t.Fatalf("got %d steps, want 2", len(steps))
+	}
+
+	tests := []struct {
+		area    image.Rectangle
+		scale   float64
+		marker  image.Point
+		logical image.Point
+	}{
+		{primary, 1.5, image.Pt(300, 150), image.Pt(200, 100)},
+		{secondary, 1, image.Pt(100, 50), image.Pt(2560+100, 50)},
+	}
+	for i, tt := range tests {
+		step := steps[i]
+		if step.CaptureArea != tt.area {
+			t.Errorf("step %d: CaptureArea = %v, want %v", i+1, step.CaptureArea, tt.area)
+		}
+		if step.Scale != tt.scale {
+			t.Errorf("step %d: Scale = %v, want %v", i+1, step.Scale, tt.scale)
+		}
+		if step.LogicalCoordinates != tt.logical {
+			t.Errorf("step %d: LogicalCoordinates = %v, want %v", i+1, step.LogicalCoordinates, tt.logical)
+		}
+		if len(step.Annotations) != 1 || step.Annotations[0].Kind != annotation.KindClick {
+			t.Fatalf("step %d: annotations = %+v, want one click marker", i+1, step.Annotations)
+		}
+		marker := step.Annotations[0]
+		if marker.At != tt.marker {
+			t.Errorf("step %d: marker at %v, want %v", i+1, marker.At, tt.marker)
+		}
+		if marker.Scale != tt.scale {
+			t.Errorf("step %d: marker scale = %v, want %v", i+1, marker.Scale, tt.scale)
+		}
+	}
+}
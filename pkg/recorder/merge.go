@@ -0,0 +1,71 @@
+package recorder
+
+import (
+	"image"
+	"log"
+	"strings"
+
+	"github.com/gustaf/go-test/pkg/diff"
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// mergeNoOpSteps removes clicks and scrolls that changed nothing on
+// screen: steps whose screenshot is within the merge threshold of the next
+// step's, in the same window. The next step takes over any pause before
+// the removed one. It must only run once the workers have drained.
+func (r *Recorder) mergeNoOpSteps() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	merged := 0
+	kept := r.steps[:0]
+	for i, step := range r.steps {
+		if i+1 < len(r.steps) && r.isNoOp(step, r.steps[i+1]) {
+			r.steps[i+1].Paused += step.Paused
+			merged++
+			continue
+		}
+		kept = append(kept, step)
+	}
+	r.steps = kept
+	r.merged = merged
+	if merged > 0 {
+		log.Printf("Merged %d steps that changed nothing on screen", merged)
+	}
+}
+
+// isNoOp reports whether step changed nothing before next was recorded.
+// Only clicks and scrolls qualify; drags, typing and manual captures are
+// always kept. A pause between the two keeps both, since the screen may
+// have changed and changed back while the recording was paused. Hashes
+// only compare like with like, so the two screenshots must also show the
+// same area at the same size.
+func (r *Recorder) isNoOp(step, next model.Step) bool {
+	switch {
+	case step.Action == ActionClick, step.Action == ActionRightClick, step.Action == ActionMiddleClick,
+		step.Action == ActionDoubleClick, strings.HasPrefix(step.Action, "Scroll "):
+	default:
+		return false
+	}
+	return next.Paused == 0 && step.Window == next.Window &&
+		step.CaptureArea == next.CaptureArea &&
+		screenshotBounds(step) == screenshotBounds(next) &&
+		diff.Distance(step.Hash, next.Hash) <= r.mergeThreshold
+}
+
+// screenshotBounds returns the bounds of the screenshot of step, or an
+// empty rectangle if it has none.
+func screenshotBounds(step model.Step) image.Rectangle {
+	if step.Screenshot == nil {
+		return image.Rectangle{}
+	}
+	return step.Screenshot.Bounds()
+}
+
+// Merged returns how many steps of the current or last recording were
+// merged into the next one because they changed nothing.
+func (r *Recorder) Merged() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.merged
+}
@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"image"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/diff"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+func TestMergeNoOpSteps(t *testing.T) {
+	session, err := store.NewSession(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shot := func(w, h int) *store.Image {
+		img, err := session.Put(image.NewRGBA(image.Rect(0, 0, w, h)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	small, large := shot(800, 600), shot(1024, 768)
+
+	blank := image.NewGray(image.Rect(0, 0, 800, 600))
+	changed := image.NewGray(blank.Rect)
+	for i := range changed.Pix[:len(changed.Pix)/2] {
+		changed.Pix[i] = 0xff
+	}
+	window := model.Window{Title: "Editor", Process: "editor", PID: 1}
+	area := image.Rect(0, 0, 800, 600)
+	step := func(action string) model.Step {
+		return model.Step{
+			Action:      action,
+			Screenshot:  small,
+			Hash:        diff.NewHash(blank),
+			Window:      window,
+			CaptureArea: area,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		first model.Step
+		next  func(s *model.Step)
+		merge bool
+	}{
+		{"click", step(ActionClick), func(*model.Step) {}, true},
+		{"right click", step(ActionRightClick), func(*model.Step) {}, true},
+		{"scroll", step("Scroll down 2 notches"), func(*model.Step) {}, true},
+		{"screen changed", step(ActionClick), func(s *model.Step) { s.Hash = diff.NewHash(changed) }, false},
+		{"paused", step(ActionClick), func(s *model.Step) { s.Paused = time.Second }, false},
+		{"other window", step(ActionClick), func(s *model.Step) { s.Window.Title = "Browser" }, false},
+		{"other capture area", step(ActionClick), func(s *model.Step) { s.CaptureArea = area.Add(image.Pt(800, 0)) }, false},
+		{"other screenshot size", step(ActionClick), func(s *model.Step) { s.Screenshot = large }, false},
+		{"drag", step(ActionDrag), func(*model.Step) {}, false},
+		{"typing", step("Type 'hello'"), func(*model.Step) {}, false},
+		{"manual capture", step(ActionManual), func(*model.Step) {}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := step(ActionClick)
+			tt.next(&next)
+			r := &Recorder{steps: []model.Step{tt.first, next}}
+			r.mergeNoOpSteps()
+
+			want := []string{tt.first.Action, next.Action}
+			merged := 0
+			if tt.merge {
+				want, merged = want[1:], 1
+			}
+			if got := actions(r.steps); !slices.Equal(got, want) {
+				t.Errorf("actions = %q, want %q", got, want)
+			}
+			if r.Merged() != merged {
+				t.Errorf("Merged = %d, want %d", r.Merged(), merged)
+			}
+		})
+	}
+}
+
+// TestMergeNoOpStepsPause checks that the step after a merged one takes
+// over the pause before it.
+func TestMergeNoOpStepsPause(t *testing.T) {
+	hash := diff.NewHash(image.NewGray(image.Rect(0, 0, 64, 64)))
+	r := &Recorder{steps: []model.Step{
+		{Action: ActionClick, Paused: time.Second, Hash: hash},
+		{Action: ActionClick, Hash: hash},
+		{Action: ActionManual, Hash: hash, Window: model.Window{Title: "Browser"}},
+	}}
+	r.mergeNoOpSteps()
+	if got, want := actions(r.steps), []string{ActionClick, ActionManual}; !slices.Equal(got, want) {
+		t.Fatalf("actions = %q, want %q", got, want)
+	}
+	if r.steps[0].Paused != time.Second {
+		t.Errorf("Paused = %v, want the pause of the merged step", r.steps[0].Paused)
+	}
+}
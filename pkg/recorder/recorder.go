@@ -0,0 +1,460 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/store"
+)
+
+// sessionDirName is the directory inside the output directory that holds
+// the working directories of recordings.
+const sessionDirName = ".sessions"
+
+// EventKind identifies what happened in an InputEvent.
+type EventKind int
+
+const (
+	ButtonPress EventKind = iota
+	ButtonRelease
+	// Scroll is one wheel notch; Delta gives its direction.
+	Scroll
+	KeyPress
+	KeyRelease
+	// Capture asks for a step at Position without any pointer gesture.
+	Capture
+)
+
+// Button identifies a pointer button.
+type Button int
+
+const (
+	ButtonLeft Button = iota + 1
+	ButtonMiddle
+	ButtonRight
+)
+
+// InputEvent is a single input event reported by an InputSource.
+// Position is the pointer position in virtual desktop coordinates, the same
+// space as the display bounds reported by a ScreenCapturer. For Scroll
+// events Delta is the number of notches moved; positive Y scrolls down and
+// positive X scrolls right.
+type InputEvent struct {
+	Kind     EventKind
+	Button   Button
+	Position image.Point
+	Delta    image.Point
+	Time     time.Time
+
+	// Key events only. Key is the key name ("a", "Enter", "F5", "Ctrl"),
+	// Rune the character the key produces, or 0 if it produces none.
+	Key       string
+	Rune      rune
+	Modifiers Modifier
+}
+
+// InputSource delivers input events from the platform.
+type InputSource interface {
+	// Start begins delivering events on the returned channel. Key events
+	// are only delivered when keyboard is true. The channel is closed once
+	// the source has stopped.
+	Start(keyboard bool) (<-chan InputEvent, error)
+	Stop() error
+}
+
+// ScreenCapturer describes the display layout and captures screen contents.
+type ScreenCapturer interface {
+	Displays() []image.Rectangle
+	Capture(bounds image.Rectangle) (image.Image, error)
+	// Scale returns the ratio of physical pixels to 96 DPI logical pixels
+	// on the given display.
+	Scale(display image.Rectangle) float64
+}
+
+// Backend bundles the platform services a Recorder depends on.
+type Backend struct {
+	Input   InputSource
+	Screen  ScreenCapturer
+	Windows WindowInspector
+	Hotkeys HotkeyListener
+}
+
+type Recorder struct {
+	steps       []model.Step
+	started     time.Time
+	ended       time.Time
+	isRecording bool
+	paused      bool
+	pausedAt    time.Time
+	mu          sync.Mutex
+	settings    *config.Settings
+	backend     Backend
+	session     *store.Session
+	journal     *journal
+	queues      *captureQueues
+	stats       CaptureStats
+	done        chan struct{}
+	control     chan func()
+
+	// pauseGap accumulates paused time until the next step is recorded.
+	pauseGap time.Duration
+	// stepBegun is closed when the next step is appended, which ends the
+	// wait for the after frame of the one before. It is owned by the event
+	// loop.
+	stepBegun chan struct{}
+
+	// Recording options, fixed for the duration of a recording.
+	scope      captureScope
+	highlight  highlightStyle
+	showCursor bool
+	keyboard   bool
+	denyList   []*regexp.Regexp
+	hotkeys    []HotkeyBinding
+
+	redactionRules []redactionRule
+	// ownWindows is how GoStep's windows appear in screenshots. hideFailed
+	// is set once hiding them has failed, after which they are masked.
+	ownWindows string
+	hideFailed atomic.Bool
+	// cursorFailed is set once reading the pointer shape has failed, so
+	// the failure is logged once rather than for every frame.
+	cursorFailed atomic.Bool
+
+	// frames is nil when the frame buffer is disabled. afterFrames adds
+	// an after frame, taken afterDelay after the shot, to every step.
+	frames      *frameBuffer
+	afterFrames bool
+	afterDelay  time.Duration
+
+	// mergeThreshold is the hash distance up to which a click or scroll
+	// counts as changing nothing, or negative to keep every step. merged
+	// counts the steps merged when the recording stopped.
+	mergeThreshold int
+	merged         int
+
+	// captureSettings is how the current or last recording is captured,
+	// as its Recording reports it.
+	captureSettings model.Capture
+
+	// Gesture state, owned by the event loop.
+	press     *pendingPress
+	lastClick *pendingPress
+	scroll    *scrollRun
+	typing    *typingRun
+}
+
+func newBackend(settings *config.Settings) Backend {
+	return Backend{
+		Input:   newInputSource(settings.InputBackend),
+		Screen:  newScreenCapturer(),
+		Windows: newWindowInspector(),
+		Hotkeys: newHotkeyListener(),
+	}
+}
+
+// NewRecorder creates a Recorder that uses the platform backend.
+func NewRecorder(settings *config.Settings) *Recorder {
+	return NewRecorderWith(settings, newBackend(settings))
+}
+
+// NewRecorderWith creates a Recorder on top of the given backend. Settings
+// are read each time a recording starts.
+func NewRecorderWith(settings *config.Settings, backend Backend) *Recorder {
+	return &Recorder{
+		steps:    make([]model.Step, 0),
+		settings: settings,
+		backend:  backend,
+	}
+}
+
+func (r *Recorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isRecording {
+		return fmt.Errorf("recording is already in progress")
+	}
+
+	denyList, err := compilePatterns(r.settings.KeystrokeDenyList)
+	if err != nil {
+		return fmt.Errorf("invalid keystroke deny list: %w", err)
+	}
+	hotkeys, err := hotkeyBindings(r.settings)
+	if err != nil {
+		return fmt.Errorf("invalid hotkey: %w", err)
+	}
+	scope, err := parseScope(r.settings)
+	if err != nil {
+		return err
+	}
+	style, err := parseHighlight(r.settings)
+	if err != nil {
+		return err
+	}
+	rules, err := compileRedactionRules(r.settings.RedactionRules)
+	if err != nil {
+		return err
+	}
+	ownWindows, err := parseOwnWindows(r.settings.OwnWindows)
+	if err != nil {
+		return err
+	}
+	r.scope = scope
+	r.highlight = style
+	r.showCursor = r.settings.ShowCursor
+	r.redactionRules = rules
+	r.ownWindows = ownWindows
+	r.hideFailed.Store(false)
+	r.cursorFailed.Store(false)
+	r.afterFrames = r.settings.CaptureAfterFrame
+	r.afterDelay = time.Duration(r.settings.AfterFrameDelay) * time.Millisecond
+	r.mergeThreshold = -1
+	if r.settings.MergeNoOpSteps {
+		r.mergeThreshold = max(r.settings.MergeThreshold, 0)
+	}
+	r.keyboard = r.settings.CaptureKeystrokes
+	r.denyList = denyList
+	r.hotkeys = hotkeys
+	r.captureSettings = model.Capture{
+		Scope:          scope.mode,
+		Region:         scope.region,
+		Keystrokes:     r.keyboard,
+		Cursor:         r.showCursor,
+		HighlightStyle: r.settings.HighlightStyle,
+		OwnWindows:     ownWindows,
+		FrameBuffer:    time.Duration(max(r.settings.FrameBufferInterval, 0)) * time.Millisecond,
+		MergeThreshold: r.mergeThreshold,
+		Redacted:       len(rules) > 0,
+	}
+	if r.afterFrames {
+		r.captureSettings.AfterFrameDelay = r.afterDelay
+	}
+
+	session, err := NewSession(r.settings)
+	if err != nil {
+		return err
+	}
+
+	events, err := r.backend.Input.Start(r.keyboard)
+	if err != nil {
+		session.Remove()
+		return err
+	}
+
+	r.done = make(chan struct{})
+	r.control = make(chan func())
+	r.session = session
+	r.journal, err = openJournal(session.Dir())
+	if err != nil {
+		log.Printf("Steps will not be saved as they are recorded: %v", err)
+	}
+	r.queues = r.startWorkers()
+	r.frames = nil
+	if interval := time.Duration(r.settings.FrameBufferInterval) * time.Millisecond; interval > 0 {
+		r.frames = r.startFrameBuffer(interval)
+	}
+	r.stats = CaptureStats{}
+	r.merged = 0
+	r.steps = make([]model.Step, 0)
+	r.press, r.lastClick, r.scroll, r.typing = nil, nil, nil, nil
+	r.stepBegun = make(chan struct{})
+	r.started, r.ended = time.Now(), time.Time{}
+	r.isRecording = true
+	r.paused = false
+	r.pauseGap = 0
+
+	go r.processEvents(events, r.control, r.done)
+	return nil
+}
+
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	if !r.isRecording {
+		r.mu.Unlock()
+		return fmt.Errorf("no recording in progress")
+	}
+	r.isRecording = false
+	r.ended = time.Now()
+	done := r.done
+	r.mu.Unlock()
+
+	// The source closes its channel once stopped; waiting for the event
+	// loop to drain means every delivered event is reflected in the steps
+	// and every screenshot has been stored.
+	err := r.backend.Input.Stop()
+	<-done
+
+	r.mu.Lock()
+	if counter, ok := r.backend.Input.(dropCounter); ok {
+		r.stats.Dropped += counter.Dropped()
+	}
+	log.Printf("Recording stopped. Captured %d steps", len(r.steps))
+	if missed := r.stats.String(); missed != "" {
+		log.Printf("Not every event was captured on time: %s", missed)
+	}
+	r.mu.Unlock()
+	return err
+}
+
+// Pause stops turning input into steps until Resume is called. A gesture
+// or typing run in progress is finished first, so nothing done while
+// paused ends up in a step.
+func (r *Recorder) Pause() error {
+	r.mu.Lock()
+	if !r.isRecording {
+		r.mu.Unlock()
+		return fmt.Errorf("no recording in progress")
+	}
+	if r.paused {
+		r.mu.Unlock()
+		return fmt.Errorf("recording is already paused")
+	}
+	r.paused = true
+	r.pausedAt = time.Now()
+	r.mu.Unlock()
+
+	r.do(r.finishGestures)
+	log.Printf("Recording paused")
+	return nil
+}
+
+func (r *Recorder) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isRecording {
+		return fmt.Errorf("no recording in progress")
+	}
+	if !r.paused {
+		return fmt.Errorf("recording is not paused")
+	}
+	r.paused = false
+	r.pauseGap += time.Since(r.pausedAt)
+	log.Printf("Recording resumed")
+	return nil
+}
+
+func (r *Recorder) IsRecording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.isRecording
+}
+
+func (r *Recorder) IsPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.isRecording && r.paused
+}
+
+// NewSession creates a working directory for screenshots next to those of
+// recordings, for steps that come from elsewhere, such as a saved bundle.
+func NewSession(settings *config.Settings) (*store.Session, error) {
+	return store.NewSession(filepath.Join(settings.OutputDir, sessionDirName))
+}
+
+// Session returns the working directory holding the screenshots of the
+// current or last recording, or nil if nothing was recorded yet. The
+// caller owns it once the recording has stopped and should remove it when
+// the steps are no longer needed.
+func (r *Recorder) Session() *store.Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session
+}
+
+// Recording returns the steps of the current or last recording, with when
+// it started and stopped and how it was captured.
+func (r *Recorder) Recording() *model.Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &model.Recording{Started: r.started, Ended: r.ended, Capture: r.captureSettings, Steps: r.steps}
+}
+
+func (r *Recorder) processEvents(events <-chan InputEvent, control <-chan func(), done chan struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				r.flushPress()
+				r.flushTyping()
+				if r.frames != nil {
+					r.frames.halt()
+				}
+				r.queues.drain()
+				r.dropIncompleteSteps()
+				if r.mergeThreshold >= 0 {
+					r.mergeNoOpSteps()
+				}
+				r.finishJournal()
+				return
+			}
+			if !r.IsPaused() {
+				r.handleEvent(ev)
+			}
+		case fn := <-control:
+			fn()
+		}
+	}
+}
+
+// do runs fn on the event loop, which owns the gesture state, and waits
+// for it to finish. It reports false if no recording is running.
+func (r *Recorder) do(fn func()) bool {
+	r.mu.Lock()
+	recording, control, done := r.isRecording, r.control, r.done
+	r.mu.Unlock()
+
+	if !recording {
+		return false
+	}
+	finished := make(chan struct{})
+	select {
+	case control <- func() { fn(); close(finished) }:
+	case <-done:
+		return false
+	}
+	<-finished
+	return true
+}
+
+// capture takes the screenshot for an event at pos in window win and
+// returns it with the area of the virtual desktop it covers.
+func (r *Recorder) capture(pos image.Point, win model.Window) (image.Image, image.Rectangle, bool) {
+	if r.scope.mode == config.ScopeAllDisplays {
+		return r.captureAllDisplays()
+	}
+
+	area, ok := r.captureArea(pos, win)
+	if !ok {
+		return nil, image.Rectangle{}, false
+	}
+
+	img, err := r.backend.Screen.Capture(area)
+	if err != nil {
+		log.Printf("Failed to capture screenshot: %v", err)
+		return nil, image.Rectangle{}, false
+	}
+	return img, area, true
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
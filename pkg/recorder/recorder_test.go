@@ -0,0 +1,523 @@
+package recorder
+
+import (
+	"image"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gustaf/go-test/pkg/annotation"
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// testSettings returns settings that keep every step and write into a
+// temporary directory.
+func testSettings(t *testing.T) *config.Settings {
+	t.Helper()
+	settings := config.DefaultSettings()
+	settings.OutputDir = t.TempDir()
+	return settings
+}
+
+// testBackend is a backend made of fakes, so tests can drive a recording.
+type testBackend struct {
+	input   *FakeInputSource
+	screen  *FakeScreenCapturer
+	windows *FakeWindowInspector
+	hotkeys *FakeHotkeyListener
+}
+
+func newTestBackend(displays ...image.Rectangle) *testBackend {
+	if len(displays) == 0 {
+		displays = []image.Rectangle{image.Rect(0, 0, 800, 600)}
+	}
+	return &testBackend{
+		input:   NewFakeInputSource(),
+		screen:  NewFakeScreenCapturer(displays...),
+		windows: NewFakeWindowInspector(),
+		hotkeys: NewFakeHotkeyListener(),
+	}
+}
+
+// record starts a recorder on b, runs input and returns the steps
+// recorded once it has stopped.
+func (b *testBackend) record(t *testing.T, settings *config.Settings, input func(r *Recorder)) []model.Step {
+	t.Helper()
+	r := NewRecorderWith(settings, Backend{
+		Input:   b.input,
+		Screen:  b.screen,
+		Windows: b.windows,
+		Hotkeys: b.hotkeys,
+	})
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	input(r)
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	return r.Recording().Steps
+}
+
+func actions(steps []model.Step) []string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Action
+	}
+	return names
+}
+
+func TestClickStep(t *testing.T) {
+	left := image.Rect(0, 0, 800, 600)
+	right := image.Rect(800, 0, 1440, 480)
+	b := newTestBackend(left, right)
+	steps := b.record(t, testSettings(t), func(*Recorder) {
+		b.input.Click(ButtonLeft, 30, 40)
+		b.input.Click(ButtonLeft, 900, 100)
+	})
+	if got, want := actions(steps), []string{ActionClick, ActionClick}; !slices.Equal(got, want) {
+		t.Fatalf("actions = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		at   image.Point
+		size image.Point
+	}{
+		{image.Pt(30, 40), left.Size()},
+		{image.Pt(900, 100), right.Size()},
+	}
+	for i, tt := range tests {
+		step := steps[i]
+		if step.Coordinates != tt.at {
+			t.Errorf("step %d at %v, want %v", i+1, step.Coordinates, tt.at)
+		}
+		if !step.Highlighted || step.Screenshot == nil {
+			t.Errorf("step %d: Highlighted = %v, Screenshot = %v, want a highlighted screenshot", i+1, step.Highlighted, step.Screenshot)
+			continue
+		}
+		if got := step.Screenshot.Bounds().Size(); got != tt.size {
+			t.Errorf("step %d: screenshot size %v, want %v", i+1, got, tt.size)
+		}
+		if len(step.Annotations) != 1 || step.Annotations[0].Kind != annotation.KindClick {
+			t.Errorf("step %d: annotations = %+v, want one click marker", i+1, step.Annotations)
+			continue
+		}
+		if got, want := step.Annotations[0].At, tt.at.Sub(step.CaptureArea.Min); got != want {
+			t.Errorf("step %d: marker at %v, want %v", i+1, got, want)
+		}
+	}
+	if got, want := b.screen.Captures(), []image.Rectangle{left, right}; !slices.Equal(got, want) {
+		t.Errorf("captures = %v, want %v", got, want)
+	}
+}
+
+func TestClicksIgnored(t *testing.T) {
+	tests := []struct {
+		name  string
+		input func(b *testBackend)
+	}{
+		{"release alone", func(b *testBackend) {
+			b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(10, 20)})
+		}},
+		{"off every display", func(b *testBackend) { b.input.Click(ButtonLeft, -10, 20) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBackend()
+			if steps := b.record(t, testSettings(t), func(*Recorder) { tt.input(b) }); len(steps) != 0 {
+				t.Errorf("got steps %q, want none", actions(steps))
+			}
+		})
+	}
+}
+
+func TestGestures(t *testing.T) {
+	scroll := func(b *testBackend, dx, dy int) {
+		b.input.Emit(InputEvent{Kind: Scroll, Position: image.Pt(200, 200), Delta: image.Pt(dx, dy)})
+	}
+	tests := []struct {
+		name  string
+		input func(b *testBackend)
+		want  []string
+	}{
+		{
+			name:  "click",
+			input: func(b *testBackend) { b.input.Click(ButtonLeft, 10, 20) },
+			want:  []string{ActionClick},
+		},
+		{
+			name: "right and middle click",
+			input: func(b *testBackend) {
+				b.input.Click(ButtonRight, 10, 20)
+				b.input.Click(ButtonMiddle, 10, 20)
+			},
+			want: []string{ActionRightClick, ActionMiddleClick},
+		},
+		{
+			name: "drag",
+			input: func(b *testBackend) {
+				b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(10, 10)})
+				b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(100, 120)})
+			},
+			want: []string{ActionDrag},
+		},
+		{
+			name: "small movement is still a click",
+			input: func(b *testBackend) {
+				b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(10, 10)})
+				b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(10+dragThreshold, 10)})
+			},
+			want: []string{ActionClick},
+		},
+		{
+			name: "press without release",
+			input: func(b *testBackend) {
+				b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(10, 10)})
+			},
+			want: []string{ActionClick},
+		},
+		{
+			name: "double click",
+			input: func(b *testBackend) {
+				b.input.Click(ButtonLeft, 10, 20)
+				b.input.Click(ButtonLeft, 11, 21)
+			},
+			want: []string{ActionDoubleClick},
+		},
+		{
+			name: "click then drag",
+			input: func(b *testBackend) {
+				b.input.Click(ButtonLeft, 10, 20)
+				b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(11, 21)})
+				b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(100, 120)})
+			},
+			want: []string{ActionClick, ActionDrag},
+		},
+		{
+			name: "clicks too far apart",
+			input: func(b *testBackend) {
+				b.input.Click(ButtonLeft, 10, 20)
+				b.input.Click(ButtonLeft, 10+2*doubleClickDistance, 20)
+			},
+			want: []string{ActionClick, ActionClick},
+		},
+		{
+			name: "clicks too far apart in time",
+			input: func(b *testBackend) {
+				at := time.Now()
+				for _, t := range []time.Time{at, at.Add(2 * doubleClickInterval)} {
+					b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(10, 20), Time: t})
+					b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(10, 20), Time: t})
+				}
+			},
+			want: []string{ActionClick, ActionClick},
+		},
+		{
+			name: "typing between clicks ends the double click",
+			input: func(b *testBackend) {
+				b.input.Click(ButtonLeft, 10, 20)
+				b.input.Type("a")
+				b.input.Click(ButtonLeft, 10, 20)
+			},
+			want: []string{ActionClick, "Type 'a'", ActionClick},
+		},
+		{
+			name: "scroll run",
+			input: func(b *testBackend) {
+				scroll(b, 0, 1)
+				scroll(b, 0, 1)
+				scroll(b, 0, 2)
+			},
+			want: []string{"Scroll down 4 notches"},
+		},
+		{
+			name: "scroll runs split by direction and clicks",
+			input: func(b *testBackend) {
+				scroll(b, 0, 1)
+				scroll(b, 0, -1)
+				b.input.Click(ButtonLeft, 10, 20)
+				scroll(b, 0, -1)
+				scroll(b, 1, 0)
+			},
+			want: []string{"Scroll down 1 notch", "Scroll up 1 notch", ActionClick, "Scroll up 1 notch", "Scroll right 1 notch"},
+		},
+		{
+			name: "typing",
+			input: func(b *testBackend) {
+				b.windows.SetFocus(Focus{ID: 1, WindowTitle: "Notes"})
+				b.input.Type("helo")
+				b.input.Key("Backspace", 0, 0)
+				b.input.Type("lo")
+				b.input.Key("Enter", 0, 0)
+			},
+			want: []string{"Type 'hello'", "Press Enter"},
+		},
+		{
+			name: "typing split by focus",
+			input: func(b *testBackend) {
+				b.windows.SetFocus(Focus{ID: 1, WindowTitle: "Form"})
+				b.input.Type("Ada")
+				b.windows.SetFocus(Focus{ID: 2, WindowTitle: "Form"})
+				b.input.Type("Lovelace")
+			},
+			want: []string{"Type 'Ada'", "Type 'Lovelace'"},
+		},
+		{
+			name: "typing ended by a click",
+			input: func(b *testBackend) {
+				b.input.Type("x")
+				b.input.Click(ButtonLeft, 10, 20)
+			},
+			want: []string{"Type 'x'", ActionClick},
+		},
+		{
+			name: "shortcut",
+			input: func(b *testBackend) {
+				b.input.Type("x")
+				b.input.Key("c", 'c', ModCtrl)
+			},
+			want: []string{"Type 'x'", "Press Ctrl+C"},
+		},
+		{
+			name: "password field is masked",
+			input: func(b *testBackend) {
+				b.windows.SetFocus(Focus{ID: 1, WindowTitle: "Accounts", Password: true})
+				b.input.Type("hunter2")
+			},
+			want: []string{"Type '" + maskedText + "'"},
+		},
+		{
+			name: "window on the deny list is masked",
+			input: func(b *testBackend) {
+				b.windows.SetFocus(Focus{ID: 1, WindowTitle: "Sign in - Example"})
+				b.input.Type("hunter2")
+			},
+			want: []string{"Type '" + maskedText + "'"},
+		},
+		{
+			name: "hotkey keys are not recorded",
+			input: func(b *testBackend) {
+				b.input.Key("s", 's', ModCtrl|ModAlt)
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := testSettings(t)
+			settings.CaptureKeystrokes = true
+			b := newTestBackend()
+			steps := b.record(t, settings, func(*Recorder) { tt.input(b) })
+			if got := actions(steps); !slices.Equal(got, tt.want) {
+				t.Errorf("actions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDragStep(t *testing.T) {
+	b := newTestBackend()
+	steps := b.record(t, testSettings(t), func(*Recorder) {
+		b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(10, 10)})
+		b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(100, 120)})
+	})
+	if len(steps) != 1 {
+		t.Fatalf("got %d steps, want 1", len(steps))
+	}
+	if drag := steps[0]; drag.Coordinates != image.Pt(10, 10) || drag.EndCoordinates != image.Pt(100, 120) {
+		t.Errorf("drag from %v to %v, want from (10,10) to (100,120)", drag.Coordinates, drag.EndCoordinates)
+	}
+	if marks := steps[0].Annotations; len(marks) != 2 || marks[0].Kind != annotation.KindClick || marks[1].Kind != annotation.KindArrow {
+		t.Errorf("drag annotations = %+v, want a click marker and an arrow", marks)
+	}
+}
+
+// TestDoubleClickShots checks that the second press of a double click
+// takes no screenshot of its own, while one that becomes a drag still gets
+// one.
+func TestDoubleClickShots(t *testing.T) {
+	tests := []struct {
+		name    string
+		release image.Point
+		want    []string
+	}{
+		{"double click", image.Pt(11, 21), []string{ActionDoubleClick}},
+		{"drag", image.Pt(100, 120), []string{ActionClick, ActionDrag}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBackend()
+			steps := b.record(t, testSettings(t), func(*Recorder) {
+				b.input.Click(ButtonLeft, 10, 20)
+				b.input.Emit(InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(11, 21)})
+				b.input.Emit(InputEvent{Kind: ButtonRelease, Button: ButtonLeft, Position: tt.release})
+			})
+			if got := actions(steps); !slices.Equal(got, tt.want) {
+				t.Fatalf("actions = %q, want %q", got, tt.want)
+			}
+			for i, step := range steps {
+				if step.Screenshot == nil {
+					t.Errorf("step %d has no screenshot", i+1)
+				}
+			}
+			if got := len(b.screen.Captures()); got != len(tt.want) {
+				t.Errorf("took %d screenshots, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestKeystrokesOff(t *testing.T) {
+	b := newTestBackend()
+	steps := b.record(t, testSettings(t), func(*Recorder) {
+		b.input.Type("secret")
+		b.input.Key("Enter", 0, 0)
+		b.input.Click(ButtonLeft, 10, 20)
+	})
+	if got, want := actions(steps), []string{ActionClick}; !slices.Equal(got, want) {
+		t.Errorf("actions = %q, want %q", got, want)
+	}
+}
+
+func TestPauseGap(t *testing.T) {
+	const gap = 50 * time.Millisecond
+
+	b := newTestBackend()
+	steps := b.record(t, testSettings(t), func(r *Recorder) {
+		b.input.Click(ButtonLeft, 10, 20)
+		if err := r.Pause(); err != nil {
+			t.Fatalf("Pause: %v", err)
+		}
+		b.input.Click(ButtonLeft, 100, 200)
+		time.Sleep(gap)
+		if err := r.Resume(); err != nil {
+			t.Fatalf("Resume: %v", err)
+		}
+		b.input.Click(ButtonRight, 10, 20)
+		b.input.Click(ButtonRight, 30, 40)
+	})
+	if got, want := actions(steps), []string{ActionClick, ActionRightClick, ActionRightClick}; !slices.Equal(got, want) {
+		t.Fatalf("actions = %q, want %q", got, want)
+	}
+	if steps[0].Paused != 0 {
+		t.Errorf("step 1: Paused = %v, want 0", steps[0].Paused)
+	}
+	if steps[1].Paused < gap {
+		t.Errorf("step 2: Paused = %v, want at least %v", steps[1].Paused, gap)
+	}
+	if steps[2].Paused != 0 {
+		t.Errorf("step 3: Paused = %v, want 0", steps[2].Paused)
+	}
+}
+
+func TestRecordingCapture(t *testing.T) {
+	settings := testSettings(t)
+	settings.CaptureScope = config.ScopeRegion
+	settings.CaptureRegion = config.Region{X: -10, Y: 20, Width: 300, Height: 200}
+	settings.CaptureKeystrokes = true
+	settings.ShowCursor = true
+	settings.HighlightStyle = "halo"
+	settings.CaptureAfterFrame = true
+	settings.AfterFrameDelay = 250
+	settings.MergeNoOpSteps = true
+	settings.MergeThreshold = 5
+	settings.RedactionRules = []config.RedactionRule{{WindowTitle: "Bank"}}
+
+	b := newTestBackend()
+	r := NewRecorderWith(settings, Backend{Input: b.input, Screen: b.screen, Windows: b.windows, Hotkeys: b.hotkeys})
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	want := model.Capture{
+		Scope:           config.ScopeRegion,
+		Region:          image.Rect(-10, 20, 290, 220),
+		Keystrokes:      true,
+		Cursor:          true,
+		HighlightStyle:  "halo",
+		OwnWindows:      settings.OwnWindows,
+		AfterFrameDelay: 250 * time.Millisecond,
+		MergeThreshold:  5,
+		Redacted:        true,
+	}
+	if got := r.Recording().Capture; got != want {
+		t.Errorf("Capture = %+v, want %+v", got, want)
+	}
+}
+
+func TestHotkeys(t *testing.T) {
+	b := newTestBackend()
+	fired := make(chan HotkeyAction)
+	steps := b.record(t, testSettings(t), func(r *Recorder) {
+		if err := r.ListenHotkeys(func(a HotkeyAction) { fired <- a }); err != nil {
+			t.Fatalf("ListenHotkeys: %v", err)
+		}
+		defer r.StopHotkeys()
+
+		press := func(action HotkeyAction, x, y int) {
+			b.hotkeys.Press(action, x, y)
+			if got := <-fired; got != action {
+				t.Fatalf("notified of %v, want %v", got, action)
+			}
+		}
+		press(HotkeyCaptureStep, 50, 60)
+		press(HotkeyPauseResume, 0, 0)
+		if !r.IsPaused() {
+			t.Errorf("not paused after the pause hotkey")
+		}
+		press(HotkeyCaptureStep, 50, 60)
+		press(HotkeyPauseResume, 0, 0)
+		if r.IsPaused() {
+			t.Errorf("still paused after the second pause hotkey")
+		}
+		press(HotkeyCaptureStep, 70, 80)
+	})
+
+	if got, want := actions(steps), []string{ActionManual, ActionManual}; !slices.Equal(got, want) {
+		t.Fatalf("actions = %q, want %q", got, want)
+	}
+	if steps[1].Coordinates != image.Pt(70, 80) {
+		t.Errorf("step 2 at %v, want (70,80)", steps[1].Coordinates)
+	}
+	if steps[0].Highlighted || len(steps[0].Annotations) != 0 {
+		t.Errorf("manual step is highlighted: %+v", steps[0].Annotations)
+	}
+	if got := len(b.hotkeys.Bindings()); got != 3 {
+		t.Errorf("got %d hotkey bindings, want 3", got)
+	}
+}
+
+func TestFakeInputEmitAfterStop(t *testing.T) {
+	f := NewFakeInputSource()
+	events, err := f.Start(false)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// A slow reader leaves the clicks waiting to send when Stop closes
+	// the channel.
+	go func() {
+		for range events {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				f.Click(ButtonLeft, 1, 1)
+			}
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := f.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"regexp"
+	"slices"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+)
+
+// redactionRule is a compiled config.RedactionRule.
+type redactionRule struct {
+	name   string
+	title  *regexp.Regexp
+	region image.Rectangle
+	method redact.Method
+}
+
+func compileRedactionRules(rules []config.RedactionRule) ([]redactionRule, error) {
+	compiled := make([]redactionRule, 0, len(rules))
+	for i, rule := range rules {
+		method, err := redact.ParseMethod(rule.Method)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %d: %w", i+1, err)
+		}
+
+		c := redactionRule{method: method}
+		switch {
+		case rule.WindowTitle != "" && rule.Region == nil:
+			if c.title, err = regexp.Compile(rule.WindowTitle); err != nil {
+				return nil, fmt.Errorf("redaction rule %d: %w", i+1, err)
+			}
+			c.name = fmt.Sprintf("title %q", rule.WindowTitle)
+		case rule.WindowTitle == "" && rule.Region != nil:
+			reg := *rule.Region
+			if reg.Width <= 0 || reg.Height <= 0 {
+				return nil, fmt.Errorf("redaction rule %d: region %s has no area", i+1, reg)
+			}
+			c.region = image.Rect(reg.X, reg.Y, reg.X+reg.Width, reg.Y+reg.Height)
+			c.name = "region " + reg.String()
+		default:
+			return nil, fmt.Errorf("redaction rule %d: set either a window title or a region", i+1)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// hasTitleRules reports whether a rule matches windows by title.
+func (r *Recorder) hasTitleRules() bool {
+	for _, rule := range r.redactionRules {
+		if rule.title != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleWindows lists the windows on screen for the title rules, or
+// returns nil when there are none to match them against.
+func (r *Recorder) visibleWindows() []model.Window {
+	if r.backend.Windows == nil || !r.hasTitleRules() {
+		return nil
+	}
+	windows, err := r.backend.Windows.Windows()
+	if err != nil {
+		log.Printf("Failed to list windows for redaction: %v", err)
+		return nil
+	}
+	return windows
+}
+
+// ruleRedactions returns the redactions the rules call for in a
+// screenshot of bounds. Title rules are matched against active, the window
+// the step happened in, and every window in windows, the windows that were
+// on screen; each match is redacted where it overlaps the screenshot,
+// whether it is in front or not.
+func (r *Recorder) ruleRedactions(bounds image.Rectangle, active model.Window, windows []model.Window) []redact.Redaction {
+	img := image.Rectangle{Max: bounds.Size()}
+	candidates := append([]model.Window{active}, windows...)
+
+	var redactions []redact.Redaction
+	add := func(area image.Rectangle, rule redactionRule) {
+		rect := area.Sub(bounds.Min).Intersect(img)
+		if rect.Empty() {
+			return
+		}
+		rd := redact.Redaction{Rect: rect, Method: rule.method, Rule: rule.name}
+		if !slices.Contains(redactions, rd) {
+			redactions = append(redactions, rd)
+		}
+	}
+	for _, rule := range r.redactionRules {
+		if rule.title == nil {
+			add(rule.region, rule)
+			continue
+		}
+		for _, win := range candidates {
+			if !win.IsZero() && rule.title.MatchString(win.Title) {
+				add(win.Bounds, rule)
+			}
+		}
+	}
+	return redactions
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"image"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// displayScale returns the scale desktops set through the Xft.dpi
+// resource. X11 has a single DPI for the whole screen, so every display
+// gets the same scale.
+func displayScale(display image.Rectangle) float64 {
+	return xftScale()
+}
+
+// xftScale reads Xft.dpi from the resource database once; a server
+// without the resource is taken to run at 96 DPI.
+var xftScale = sync.OnceValue(func() float64 {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return 1
+	}
+	defer conn.Close()
+
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	reply, err := xproto.GetProperty(conn, false, root, xproto.AtomResourceManager, xproto.AtomString, 0, 1<<16).Reply()
+	if err != nil {
+		return 1
+	}
+	for _, line := range strings.Split(string(reply.Value), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) != "Xft.dpi" {
+			continue
+		}
+		if dpi, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && dpi > 0 {
+			return dpi / 96
+		}
+	}
+	return 1
+})
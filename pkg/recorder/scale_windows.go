@@ -0,0 +1,100 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"image"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	MONITOR_DEFAULTTONEAREST = 2
+	MDT_EFFECTIVE_DPI        = 0
+	LOGPIXELSX               = 88
+
+	// DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 is the handle value -4.
+	DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 = ^uintptr(3)
+	PROCESS_PER_MONITOR_DPI_AWARE              = 2
+)
+
+var (
+	gdi32  = syscall.NewLazyDLL("gdi32.dll")
+	shcore = syscall.NewLazyDLL("shcore.dll")
+
+	monitorFromRect  = user32.NewProc("MonitorFromRect")
+	getDC            = user32.NewProc("GetDC")
+	releaseDC        = user32.NewProc("ReleaseDC")
+	getDeviceCaps    = gdi32.NewProc("GetDeviceCaps")
+	getDpiForMonitor = shcore.NewProc("GetDpiForMonitor")
+
+	setProcessDpiAwarenessContext = user32.NewProc("SetProcessDpiAwarenessContext")
+	setProcessDpiAwareness        = shcore.NewProc("SetProcessDpiAwareness")
+	setProcessDPIAware            = user32.NewProc("SetProcessDPIAware")
+	getCursorPos                  = user32.NewProc("GetCursorPos")
+)
+
+// SetDPIAware declares the process per-monitor DPI aware. It has to be
+// called at the start of main, before any window exists.
+//
+// A process that is not DPI aware sees a scaled copy of the desktop:
+// hook positions, the cursor position and monitor bounds are divided by
+// the scale of the primary display, while screen captures keep physical
+// pixels, so on a scaled display clicks and highlights land in the wrong
+// place. Declaring per-monitor awareness puts all of them in physical
+// pixels on every display. Each API is tried in turn, from Windows 10 1703
+// down to Vista; one that fails because the awareness is already set, by a
+// manifest or by the GUI toolkit, is harmless.
+func SetDPIAware() {
+	if setProcessDpiAwarenessContext.Find() == nil {
+		if ok, _, _ := setProcessDpiAwarenessContext.Call(DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2); ok != 0 {
+			return
+		}
+	}
+	if setProcessDpiAwareness.Find() == nil {
+		if ret, _, _ := setProcessDpiAwareness.Call(PROCESS_PER_MONITOR_DPI_AWARE); ret == 0 {
+			return
+		}
+	}
+	setProcessDPIAware.Call()
+}
+
+// cursorPos returns the pointer position in physical pixels on the
+// virtual desktop.
+func cursorPos() image.Point {
+	var p point
+	getCursorPos.Call(uintptr(unsafe.Pointer(&p)))
+	return image.Point{X: int(p.X), Y: int(p.Y)}
+}
+
+// displayScale returns the effective DPI of the monitor showing display
+// relative to 96 DPI. GetDpiForMonitor needs Windows 8.1; older systems
+// report the system DPI for every monitor.
+func displayScale(display image.Rectangle) float64 {
+	if getDpiForMonitor.Find() == nil {
+		r := rect{
+			Left:   int32(display.Min.X),
+			Top:    int32(display.Min.Y),
+			Right:  int32(display.Max.X),
+			Bottom: int32(display.Max.Y),
+		}
+		monitor, _, _ := monitorFromRect.Call(uintptr(unsafe.Pointer(&r)), MONITOR_DEFAULTTONEAREST)
+		var dpiX, dpiY uint32
+		ret, _, _ := getDpiForMonitor.Call(monitor, MDT_EFFECTIVE_DPI, uintptr(unsafe.Pointer(&dpiX)), uintptr(unsafe.Pointer(&dpiY)))
+		if ret == 0 && dpiX > 0 {
+			return float64(dpiX) / 96
+		}
+	}
+
+	dc, _, _ := getDC.Call(0)
+	if dc == 0 {
+		return 1
+	}
+	defer releaseDC.Call(0, dc)
+	dpi, _, _ := getDeviceCaps.Call(dc, LOGPIXELSX)
+	if dpi == 0 {
+		return 1
+	}
+	return float64(dpi) / 96
+}
@@ -0,0 +1,111 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// captureScope decides which part of the virtual desktop a screenshot
+// shows. It is fixed for the duration of a recording.
+type captureScope struct {
+	mode   string
+	region image.Rectangle
+}
+
+func parseScope(settings *config.Settings) (captureScope, error) {
+	switch settings.CaptureScope {
+	case "", config.ScopeDisplay:
+		return captureScope{mode: config.ScopeDisplay}, nil
+	case config.ScopeWindow, config.ScopeAllDisplays:
+		return captureScope{mode: settings.CaptureScope}, nil
+	case config.ScopeRegion:
+		reg := settings.CaptureRegion
+		if reg.Width <= 0 || reg.Height <= 0 {
+			return captureScope{}, fmt.Errorf("capture region %s has no area", reg)
+		}
+		return captureScope{
+			mode:   config.ScopeRegion,
+			region: image.Rect(reg.X, reg.Y, reg.X+reg.Width, reg.Y+reg.Height),
+		}, nil
+	}
+	return captureScope{}, fmt.Errorf("unknown capture scope %q", settings.CaptureScope)
+}
+
+// captureArea returns the part of the virtual desktop to capture for an
+// event at pos in window win. Windows and regions are clipped to the
+// displays; when nothing of them is on screen, the display containing pos
+// is captured instead.
+func (r *Recorder) captureArea(pos image.Point, win model.Window) (image.Rectangle, bool) {
+	displays := r.backend.Screen.Displays()
+
+	var desktop, display image.Rectangle
+	for _, bounds := range displays {
+		desktop = desktop.Union(bounds)
+		if display.Empty() && pos.In(bounds) {
+			display = bounds
+		}
+	}
+
+	var area image.Rectangle
+	switch r.scope.mode {
+	case config.ScopeWindow:
+		area = win.Bounds.Intersect(desktop)
+		if area.Empty() {
+			log.Printf("Active window is not on screen, capturing the display instead")
+		}
+	case config.ScopeRegion:
+		area = r.scope.region.Intersect(desktop)
+		if area.Empty() {
+			log.Printf("Capture region is not on screen, capturing the display instead")
+		}
+	}
+	if !area.Empty() {
+		return area, true
+	}
+
+	if display.Empty() {
+		log.Printf("Click position (%d, %d) not found in any display", pos.X, pos.Y)
+		return image.Rectangle{}, false
+	}
+	return display, true
+}
+
+// captureAllDisplays captures every display and stitches them into one
+// image covering the bounding box of the virtual desktop. Gaps between
+// displays of different sizes stay black, as does any display that could
+// not be captured.
+func (r *Recorder) captureAllDisplays() (image.Image, image.Rectangle, bool) {
+	displays := r.backend.Screen.Displays()
+
+	var desktop image.Rectangle
+	for _, bounds := range displays {
+		desktop = desktop.Union(bounds)
+	}
+	if desktop.Empty() {
+		log.Printf("No active displays to capture")
+		return nil, image.Rectangle{}, false
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, desktop.Dx(), desktop.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	captured := 0
+	for i, bounds := range displays {
+		img, err := r.backend.Screen.Capture(bounds)
+		if err != nil {
+			log.Printf("Failed to capture display %d: %v", i, err)
+			continue
+		}
+		draw.Draw(canvas, bounds.Sub(desktop.Min), img, img.Bounds().Min, draw.Src)
+		captured++
+	}
+	if captured == 0 {
+		return nil, image.Rectangle{}, false
+	}
+	return canvas, desktop, true
+}
@@ -0,0 +1,35 @@
+package recorder
+
+import (
+	"image"
+
+	"github.com/kbinani/screenshot"
+)
+
+// displayCapturer captures the active displays through kbinani/screenshot.
+type displayCapturer struct{}
+
+func newScreenCapturer() ScreenCapturer {
+	return displayCapturer{}
+}
+
+func (displayCapturer) Displays() []image.Rectangle {
+	n := screenshot.NumActiveDisplays()
+	displays := make([]image.Rectangle, n)
+	for i := range displays {
+		displays[i] = screenshot.GetDisplayBounds(i)
+	}
+	return displays
+}
+
+func (displayCapturer) Capture(bounds image.Rectangle) (image.Image, error) {
+	img, err := screenshot.CaptureRect(bounds)
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func (displayCapturer) Scale(display image.Rectangle) float64 {
+	return displayScale(display)
+}
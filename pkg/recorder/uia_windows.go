@@ -0,0 +1,135 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	COINIT_MULTITHREADED = 0x0
+	CLSCTX_INPROC_SERVER = 0x1
+
+	// Vtable slots, counted from the start of IUnknown.
+	uiaGetFocusedElement = 8
+	uiaCurrentIsPassword = 35
+	comRelease           = 2
+
+	// uiaTimeout bounds a query, so an application that does not answer
+	// UI Automation cannot hold up the recorder.
+	uiaTimeout = 200 * time.Millisecond
+)
+
+var (
+	ole32            = syscall.NewLazyDLL("ole32.dll")
+	coInitializeEx   = ole32.NewProc("CoInitializeEx")
+	coCreateInstance = ole32.NewProc("CoCreateInstance")
+
+	clsidCUIAutomation = guid{0xff48dba4, 0x60ef, 0x4201, [8]byte{0xaa, 0x87, 0x54, 0x10, 0x3e, 0xef, 0x59, 0x4e}}
+	iidIUIAutomation   = guid{0x30cbe57d, 0xd9d0, 0x452a, [8]byte{0xab, 0x13, 0x7a, 0xc5, 0xac, 0x48, 0x25, 0xee}}
+)
+
+// comObject is a COM interface pointer, seen through its vtable.
+type comObject struct {
+	vtable *[64]uintptr
+}
+
+type guid struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+// uiaQuery asks the UI Automation thread about the focused element.
+type uiaQuery chan uiaAnswer
+
+type uiaAnswer struct {
+	password bool
+	err      error
+}
+
+var (
+	uiaOnce    sync.Once
+	uiaQueries chan uiaQuery
+)
+
+// focusedIsPassword asks UI Automation whether the element with the
+// keyboard focus is a password field. Unlike the ES_PASSWORD style, this
+// covers the password fields of browsers, WPF and UWP applications.
+func focusedIsPassword() (bool, error) {
+	uiaOnce.Do(func() {
+		uiaQueries = make(chan uiaQuery)
+		go runUIA(uiaQueries)
+	})
+
+	answer := make(uiaQuery, 1)
+	select {
+	case uiaQueries <- answer:
+	case <-time.After(uiaTimeout):
+		return false, fmt.Errorf("UI Automation is busy")
+	}
+	select {
+	case a := <-answer:
+		return a.password, a.err
+	case <-time.After(uiaTimeout):
+		return false, fmt.Errorf("UI Automation did not answer in time")
+	}
+}
+
+// runUIA owns the IUIAutomation object. COM objects belong to the thread
+// that created them, so every query is answered on this locked thread.
+func runUIA(queries <-chan uiaQuery) {
+	runtime.LockOSThread()
+
+	automation, err := newUIAutomation()
+	for q := range queries {
+		if err != nil {
+			q <- uiaAnswer{err: err}
+			continue
+		}
+		password, qerr := isPasswordElement(automation)
+		q <- uiaAnswer{password: password, err: qerr}
+	}
+}
+
+func newUIAutomation() (*comObject, error) {
+	// S_FALSE means COM was already initialized on this thread.
+	if hr, _, _ := coInitializeEx.Call(0, COINIT_MULTITHREADED); int32(hr) < 0 {
+		return nil, fmt.Errorf("CoInitializeEx failed: 0x%08x", uint32(hr))
+	}
+	var automation *comObject
+	hr, _, _ := coCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidCUIAutomation)), 0, CLSCTX_INPROC_SERVER,
+		uintptr(unsafe.Pointer(&iidIUIAutomation)), uintptr(unsafe.Pointer(&automation)))
+	if int32(hr) < 0 {
+		return nil, fmt.Errorf("failed to create UI Automation: 0x%08x", uint32(hr))
+	}
+	return automation, nil
+}
+
+func isPasswordElement(automation *comObject) (bool, error) {
+	var element *comObject
+	if hr := comCall(automation, uiaGetFocusedElement, uintptr(unsafe.Pointer(&element))); int32(hr) < 0 || element == nil {
+		return false, fmt.Errorf("failed to get the focused element: 0x%08x", uint32(hr))
+	}
+	defer comCall(element, comRelease)
+
+	var password int32
+	if hr := comCall(element, uiaCurrentIsPassword, uintptr(unsafe.Pointer(&password))); int32(hr) < 0 {
+		return false, fmt.Errorf("failed to read IsPassword: 0x%08x", uint32(hr))
+	}
+	return password != 0, nil
+}
+
+// comCall calls the method in the given vtable slot of a COM object.
+func comCall(obj *comObject, slot int, args ...uintptr) uintptr {
+	ret, _, _ := syscall.SyscallN(obj.vtable[slot], append([]uintptr{uintptr(unsafe.Pointer(obj))}, args...)...)
+	return ret
+}
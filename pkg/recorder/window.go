@@ -0,0 +1,116 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+
+	"github.com/gustaf/go-test/pkg/config"
+	"github.com/gustaf/go-test/pkg/model"
+	"github.com/gustaf/go-test/pkg/redact"
+)
+
+// WindowInspector reports information about the windows on screen.
+type WindowInspector interface {
+	Focus() (Focus, error)
+	ActiveWindow() (model.Window, error)
+	// WindowAt returns the topmost top-level window containing p, or a zero
+	// Window if there is none.
+	WindowAt(p image.Point) (model.Window, error)
+	// OwnWindows returns the visible top-level windows of this process.
+	OwnWindows() ([]model.Window, error)
+	// Windows returns the visible top-level windows of every process,
+	// topmost first.
+	Windows() ([]model.Window, error)
+}
+
+// captureExcluder is implemented by inspectors that can keep the windows of
+// this process out of screen captures altogether.
+type captureExcluder interface {
+	ExcludeOwnWindows() error
+}
+
+// activeWindow queries the active window for a step. Steps are still
+// recorded when it cannot be determined, just without window details.
+func (r *Recorder) activeWindow() model.Window {
+	if r.backend.Windows == nil {
+		return model.Window{}
+	}
+	win, err := r.backend.Windows.ActiveWindow()
+	if err != nil {
+		log.Printf("Failed to query active window: %v", err)
+		return model.Window{}
+	}
+	return win
+}
+
+func parseOwnWindows(mode string) (string, error) {
+	switch mode {
+	case "":
+		return config.OwnWindowsMask, nil
+	case config.OwnWindowsShow, config.OwnWindowsMask, config.OwnWindowsHide:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown own window mode %q", mode)
+}
+
+// isOwnWindowAt reports whether p is on one of GoStep's own windows, so
+// that clicking its controls, such as "Stop Recording", does not end up in
+// the recording.
+func (r *Recorder) isOwnWindowAt(p image.Point) bool {
+	if r.backend.Windows == nil {
+		return false
+	}
+	win, err := r.backend.Windows.WindowAt(p)
+	if err != nil {
+		log.Printf("Failed to query window at (%d, %d): %v", p.X, p.Y, err)
+		return false
+	}
+	return win.PID == os.Getpid()
+}
+
+// hideOwnWindows keeps GoStep's windows out of the next capture in
+// OwnWindowsHide mode. It reports false when they are captured and have to
+// be masked by coverOwnWindows instead.
+func (r *Recorder) hideOwnWindows() bool {
+	if r.ownWindows != config.OwnWindowsHide {
+		return false
+	}
+	excluder, ok := r.backend.Windows.(captureExcluder)
+	if !ok || r.hideFailed.Load() {
+		return false
+	}
+	if err := excluder.ExcludeOwnWindows(); err != nil {
+		if !r.hideFailed.Swap(true) {
+			log.Printf("Failed to hide own windows from capture, masking them instead: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// coverOwnWindows fills GoStep's windows in img, a capture of bounds,
+// unless they are shown or were hidden from the capture.
+func (r *Recorder) coverOwnWindows(img image.Image, bounds image.Rectangle, hidden bool) image.Image {
+	if hidden || r.ownWindows == config.OwnWindowsShow || r.backend.Windows == nil {
+		return img
+	}
+	own, err := r.backend.Windows.OwnWindows()
+	if err != nil {
+		log.Printf("Failed to list own windows: %v", err)
+		return img
+	}
+
+	var masks []redact.Redaction
+	for _, win := range own {
+		rect := win.Bounds.Sub(bounds.Min).Intersect(img.Bounds())
+		if !rect.Empty() {
+			masks = append(masks, redact.Redaction{Rect: rect, Method: redact.Fill})
+		}
+	}
+	if len(masks) == 0 {
+		return img
+	}
+	return redact.Apply(img, masks)
+}
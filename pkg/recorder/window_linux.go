@@ -0,0 +1,339 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+// x11WindowInspector reads window information through EWMH properties set
+// by the window manager. X11 has no notion of a password field, so Focus
+// never reports one; the title deny list covers those windows instead.
+type x11WindowInspector struct {
+	mu   sync.Mutex
+	conn *xgb.Conn
+	// broken is set when a request failed for want of a connection rather
+	// than with an X error, such as for a window that has gone away.
+	broken bool
+	root   xproto.Window
+	atoms  map[string]xproto.Atom
+}
+
+func newWindowInspector() WindowInspector {
+	return &x11WindowInspector{atoms: make(map[string]xproto.Atom)}
+}
+
+func (w *x11WindowInspector) Focus() (Focus, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.connect(); err != nil {
+		return Focus{}, err
+	}
+
+	win, err := w.activeWindow()
+	if err != nil {
+		return Focus{}, err
+	}
+	return Focus{
+		ID:          uint64(win),
+		WindowTitle: w.title(win),
+	}, nil
+}
+
+// ActiveWindow describes the window named by _NET_ACTIVE_WINDOW. The
+// process is found through _NET_WM_PID, which clients on other hosts may
+// set too; their process name is then simply left empty.
+func (w *x11WindowInspector) ActiveWindow() (model.Window, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.connect(); err != nil {
+		return model.Window{}, err
+	}
+
+	win, err := w.activeWindow()
+	if err != nil {
+		return model.Window{}, err
+	}
+	// The input focus may be None or PointerRoot rather than a window.
+	if win <= xproto.InputFocusPointerRoot {
+		return model.Window{}, fmt.Errorf("no active window")
+	}
+
+	return w.describe(win), nil
+}
+
+// WindowAt describes the top-level client window at p.
+func (w *x11WindowInspector) WindowAt(p image.Point) (model.Window, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.connect(); err != nil {
+		return model.Window{}, err
+	}
+
+	reply, err := xproto.TranslateCoordinates(w.conn, w.root, w.root, int16(p.X), int16(p.Y)).Reply()
+	if err := w.check(err); err != nil {
+		return model.Window{}, fmt.Errorf("failed to find window at (%d, %d): %w", p.X, p.Y, err)
+	}
+	if reply.Child == 0 {
+		return model.Window{}, nil
+	}
+	return w.describe(w.client(reply.Child, 3)), nil
+}
+
+// OwnWindows describes the visible windows of this process, found through
+// _NET_WM_PID.
+func (w *x11WindowInspector) OwnWindows() ([]model.Window, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	clients, err := w.viewableClients()
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	var own []model.Window
+	for _, win := range clients {
+		if w.pid(win) == self {
+			own = append(own, w.describe(win))
+		}
+	}
+	return own, nil
+}
+
+// Windows describes the visible client windows, topmost first.
+func (w *x11WindowInspector) Windows() ([]model.Window, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+
+	clients, err := w.viewableClients()
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]model.Window, len(clients))
+	for i, win := range clients {
+		windows[i] = w.describe(win)
+	}
+	return windows, nil
+}
+
+// viewableClients returns the mapped client windows the window manager
+// lists, topmost first. _NET_CLIENT_LIST_STACKING is listed bottom to top;
+// without it, _NET_CLIENT_LIST gives the windows in no particular order.
+func (w *x11WindowInspector) viewableClients() ([]xproto.Window, error) {
+	value, err := w.property(w.root, "_NET_CLIENT_LIST_STACKING", xproto.AtomWindow)
+	if err != nil || len(value) == 0 {
+		value, err = w.property(w.root, "_NET_CLIENT_LIST", xproto.AtomWindow)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list windows: %w", err)
+	}
+
+	var clients []xproto.Window
+	for i := len(value)/4 - 1; i >= 0; i-- {
+		win := xproto.Window(binary.LittleEndian.Uint32(value[i*4:]))
+		attrs, err := xproto.GetWindowAttributes(w.conn, win).Reply()
+		if w.check(err) != nil || attrs.MapState != xproto.MapStateViewable {
+			continue
+		}
+		clients = append(clients, win)
+	}
+	return clients, nil
+}
+
+// client returns the client window at or below win: the one carrying
+// WM_STATE, looking at most depth levels into window manager frames. win
+// itself is returned when there is none.
+func (w *x11WindowInspector) client(win xproto.Window, depth int) xproto.Window {
+	if c, ok := w.findClient(win, depth); ok {
+		return c
+	}
+	return win
+}
+
+func (w *x11WindowInspector) findClient(win xproto.Window, depth int) (xproto.Window, bool) {
+	if value, err := w.property(win, "WM_STATE", xproto.AtomAny); err == nil && len(value) > 0 {
+		return win, true
+	}
+	if depth == 0 {
+		return 0, false
+	}
+	tree, err := xproto.QueryTree(w.conn, win).Reply()
+	if w.check(err) != nil {
+		return 0, false
+	}
+	// Children are listed bottom to top; the topmost one is wanted.
+	for i := len(tree.Children) - 1; i >= 0; i-- {
+		if c, ok := w.findClient(tree.Children[i], depth-1); ok {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func (w *x11WindowInspector) describe(win xproto.Window) model.Window {
+	pid := w.pid(win)
+	return model.Window{
+		Title:   w.title(win),
+		Process: processName(pid),
+		PID:     pid,
+		Bounds:  w.bounds(win),
+	}
+}
+
+// connect opens the connection on first use, and again once a request
+// found it broken. The connection is only replaced here, between calls, so
+// a call that finds it broken fails its remaining requests rather than
+// using a connection that is gone.
+func (w *x11WindowInspector) connect() error {
+	if w.conn != nil && !w.broken {
+		return nil
+	}
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.atoms = make(map[string]xproto.Atom)
+	}
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("failed to connect to X server: %w", err)
+	}
+	w.conn, w.broken = conn, false
+	w.root = xproto.Setup(conn).DefaultScreen(conn).Root
+	return nil
+}
+
+// check marks the connection broken when err is not an X error, which
+// the server sends for a bad request on a working connection. It returns
+// err.
+func (w *x11WindowInspector) check(err error) error {
+	if _, ok := err.(xgb.Error); err != nil && !ok {
+		w.broken = true
+	}
+	return err
+}
+
+// activeWindow returns the window named by _NET_ACTIVE_WINDOW, falling back
+// to the input focus when no EWMH window manager is running.
+func (w *x11WindowInspector) activeWindow() (xproto.Window, error) {
+	if value, err := w.property(w.root, "_NET_ACTIVE_WINDOW", xproto.AtomWindow); err == nil && len(value) >= 4 {
+		if win := xproto.Window(binary.LittleEndian.Uint32(value)); win != 0 {
+			return win, nil
+		}
+	}
+
+	reply, err := xproto.GetInputFocus(w.conn).Reply()
+	if err := w.check(err); err != nil {
+		return 0, fmt.Errorf("failed to query input focus: %w", err)
+	}
+	return reply.Focus, nil
+}
+
+// title returns the window title, preferring the UTF-8 _NET_WM_NAME.
+func (w *x11WindowInspector) title(win xproto.Window) string {
+	utf8String, err := w.atom("UTF8_STRING")
+	if err == nil {
+		if value, err := w.property(win, "_NET_WM_NAME", utf8String); err == nil && len(value) > 0 {
+			return string(value)
+		}
+	}
+	if value, err := w.property(win, "WM_NAME", xproto.AtomString); err == nil {
+		return string(value)
+	}
+	return ""
+}
+
+func (w *x11WindowInspector) pid(win xproto.Window) int {
+	value, err := w.property(win, "_NET_WM_PID", xproto.AtomCardinal)
+	if err != nil || len(value) < 4 {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(value))
+}
+
+// bounds returns the window in root coordinates, grown by the decorations
+// the window manager reports in _NET_FRAME_EXTENTS.
+func (w *x11WindowInspector) bounds(win xproto.Window) image.Rectangle {
+	geom, err := xproto.GetGeometry(w.conn, xproto.Drawable(win)).Reply()
+	if w.check(err) != nil {
+		return image.Rectangle{}
+	}
+	origin, err := xproto.TranslateCoordinates(w.conn, win, w.root, 0, 0).Reply()
+	if w.check(err) != nil {
+		return image.Rectangle{}
+	}
+
+	bounds := image.Rect(0, 0, int(geom.Width), int(geom.Height)).
+		Add(image.Pt(int(origin.DstX), int(origin.DstY)))
+	if value, err := w.property(win, "_NET_FRAME_EXTENTS", xproto.AtomCardinal); err == nil && len(value) >= 16 {
+		extent := func(i int) int { return int(binary.LittleEndian.Uint32(value[i*4:])) }
+		bounds.Min.X -= extent(0)
+		bounds.Max.X += extent(1)
+		bounds.Min.Y -= extent(2)
+		bounds.Max.Y += extent(3)
+	}
+	return bounds
+}
+
+// processName returns the executable name of a local process, or "" if
+// it cannot be read.
+func processName(pid int) string {
+	if pid <= 0 {
+		return ""
+	}
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		return filepath.Base(exe)
+	}
+	// exe is only readable for our own processes; comm is readable for
+	// all, but truncated to 15 characters.
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+	return ""
+}
+
+func (w *x11WindowInspector) property(win xproto.Window, name string, typ xproto.Atom) ([]byte, error) {
+	atom, err := w.atom(name)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := xproto.GetProperty(w.conn, false, win, atom, typ, 0, 1024).Reply()
+	if err := w.check(err); err != nil {
+		return nil, err
+	}
+	return reply.Value, nil
+}
+
+func (w *x11WindowInspector) atom(name string) (xproto.Atom, error) {
+	if atom, ok := w.atoms[name]; ok {
+		return atom, nil
+	}
+	reply, err := xproto.InternAtom(w.conn, false, uint16(len(name)), name).Reply()
+	if err := w.check(err); err != nil {
+		return 0, err
+	}
+	w.atoms[name] = reply.Atom
+	return reply.Atom, nil
+}
@@ -0,0 +1,293 @@
+//go:build windows
+// +build windows
+
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/gustaf/go-test/pkg/model"
+)
+
+const (
+	GWL_STYLE   = -16
+	ES_PASSWORD = 0x0020
+
+	DWMWA_EXTENDED_FRAME_BOUNDS       = 9
+	DWMWA_CLOAKED                     = 14
+	PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+
+	WDA_MONITOR            = 0x01
+	WDA_EXCLUDEFROMCAPTURE = 0x11
+)
+
+var (
+	getForegroundWindow      = user32.NewProc("GetForegroundWindow")
+	getWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	getGUIThreadInfo         = user32.NewProc("GetGUIThreadInfo")
+	getWindowTextW           = user32.NewProc("GetWindowTextW")
+	getClassNameW            = user32.NewProc("GetClassNameW")
+	getWindowLongW           = user32.NewProc("GetWindowLongW")
+	getWindowRect            = user32.NewProc("GetWindowRect")
+	enumWindows              = user32.NewProc("EnumWindows")
+	isWindowVisible          = user32.NewProc("IsWindowVisible")
+	setWindowDisplayAffinity = user32.NewProc("SetWindowDisplayAffinity")
+
+	queryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+
+	dwmapi                = syscall.NewLazyDLL("dwmapi.dll")
+	dwmGetWindowAttribute = dwmapi.NewProc("DwmGetWindowAttribute")
+)
+
+type rect struct {
+	Left, Top, Right, Bottom int32
+}
+
+type guiThreadInfo struct {
+	cbSize        uint32
+	flags         uint32
+	hwndActive    uintptr
+	hwndFocus     uintptr
+	hwndCapture   uintptr
+	hwndMenuOwner uintptr
+	hwndMoveSize  uintptr
+	hwndCaret     uintptr
+	rcCaret       rect
+}
+
+// win32WindowInspector queries the foreground window and its focused
+// control through user32.
+type win32WindowInspector struct{}
+
+func newWindowInspector() WindowInspector {
+	return win32WindowInspector{}
+}
+
+func (win32WindowInspector) Focus() (Focus, error) {
+	fg, _, _ := getForegroundWindow.Call()
+	if fg == 0 {
+		return Focus{}, fmt.Errorf("no foreground window")
+	}
+
+	focus := fg
+	tid, _, _ := getWindowThreadProcessId.Call(fg, 0)
+	info := guiThreadInfo{}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	if ok, _, _ := getGUIThreadInfo.Call(tid, uintptr(unsafe.Pointer(&info))); ok != 0 && info.hwndFocus != 0 {
+		focus = info.hwndFocus
+	}
+
+	return Focus{
+		ID:          uint64(focus),
+		WindowTitle: windowText(fg),
+		Password:    isPasswordFocus(focus),
+	}, nil
+}
+
+// isPasswordFocus reports whether typing into the focused control hwnd has
+// to be masked. UI Automation knows the password fields of every toolkit
+// that supports it. Without it, only a standard edit control can be told
+// apart from a password field, so typing anywhere else is masked.
+func isPasswordFocus(hwnd uintptr) bool {
+	if isPasswordControl(hwnd) {
+		return true
+	}
+	password, err := focusedIsPassword()
+	if err == nil {
+		return password
+	}
+	if !uiaFailed.Swap(true) {
+		log.Printf("Cannot ask UI Automation about password fields, masking typing outside plain edit controls: %v", err)
+	}
+	return !isEditControl(hwnd)
+}
+
+// uiaFailed is set once a UI Automation query has failed, so the fallback
+// is only logged once.
+var uiaFailed atomic.Bool
+
+func (win32WindowInspector) ActiveWindow() (model.Window, error) {
+	fg, _, _ := getForegroundWindow.Call()
+	if fg == 0 {
+		return model.Window{}, fmt.Errorf("no foreground window")
+	}
+
+	return describeWindow(fg, windowBounds(fg)), nil
+}
+
+// WindowAt describes the topmost visible top-level window containing p.
+func (win32WindowInspector) WindowAt(p image.Point) (model.Window, error) {
+	for _, hwnd := range topLevelWindows() {
+		bounds := windowBounds(hwnd)
+		if p.In(bounds) {
+			return describeWindow(hwnd, bounds), nil
+		}
+	}
+	return model.Window{}, nil
+}
+
+// OwnWindows describes the visible top-level windows of this process.
+func (win32WindowInspector) OwnWindows() ([]model.Window, error) {
+	var own []model.Window
+	for _, hwnd := range ownWindowHandles() {
+		own = append(own, describeWindow(hwnd, windowBounds(hwnd)))
+	}
+	return own, nil
+}
+
+// Windows describes the visible top-level windows, topmost first.
+func (win32WindowInspector) Windows() ([]model.Window, error) {
+	var windows []model.Window
+	for _, hwnd := range topLevelWindows() {
+		windows = append(windows, describeWindow(hwnd, windowBounds(hwnd)))
+	}
+	return windows, nil
+}
+
+// ExcludeOwnWindows keeps the windows of this process out of screen
+// captures. WDA_EXCLUDEFROMCAPTURE needs Windows 10 2004; older systems
+// capture the windows as black instead, which still hides their content.
+func (win32WindowInspector) ExcludeOwnWindows() error {
+	for _, hwnd := range ownWindowHandles() {
+		if ok, _, _ := setWindowDisplayAffinity.Call(hwnd, WDA_EXCLUDEFROMCAPTURE); ok != 0 {
+			continue
+		}
+		if ok, _, err := setWindowDisplayAffinity.Call(hwnd, WDA_MONITOR); ok == 0 {
+			return fmt.Errorf("failed to exclude window from capture: %v", err)
+		}
+	}
+	return nil
+}
+
+func ownWindowHandles() []uintptr {
+	self := uint32(os.Getpid())
+	var own []uintptr
+	for _, hwnd := range topLevelWindows() {
+		var pid uint32
+		getWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+		if pid == self {
+			own = append(own, hwnd)
+		}
+	}
+	return own
+}
+
+func describeWindow(hwnd uintptr, bounds image.Rectangle) model.Window {
+	var pid uint32
+	getWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+	return model.Window{
+		Title:   windowText(hwnd),
+		Process: processName(pid),
+		PID:     int(pid),
+		Bounds:  bounds,
+	}
+}
+
+// Callbacks made by syscall.NewCallback are never released, so the
+// enumeration callback is created once and collects into enumFound under
+// enumMu.
+var (
+	enumMu          sync.Mutex
+	enumFound       []uintptr
+	enumWindowsProc = syscall.NewCallback(func(hwnd, _ uintptr) uintptr {
+		enumFound = append(enumFound, hwnd)
+		return 1
+	})
+)
+
+// topLevelWindows returns the visible, uncloaked top-level windows from
+// the top of the z-order down.
+func topLevelWindows() []uintptr {
+	enumMu.Lock()
+	enumFound = nil
+	enumWindows.Call(enumWindowsProc, 0)
+	all := enumFound
+	enumFound = nil
+	enumMu.Unlock()
+
+	visible := all[:0]
+	for _, hwnd := range all {
+		if ok, _, _ := isWindowVisible.Call(hwnd); ok == 0 || isCloaked(hwnd) {
+			continue
+		}
+		visible = append(visible, hwnd)
+	}
+	return visible
+}
+
+// isCloaked reports whether DWM hides hwnd although it is visible, as it
+// does with suspended store apps and windows on other virtual desktops.
+func isCloaked(hwnd uintptr) bool {
+	var cloaked uint32
+	ret, _, _ := dwmGetWindowAttribute.Call(hwnd, DWMWA_CLOAKED, uintptr(unsafe.Pointer(&cloaked)), unsafe.Sizeof(cloaked))
+	return ret == 0 && cloaked != 0
+}
+
+// windowBounds returns the visible frame of hwnd. GetWindowRect includes
+// the invisible resize borders of Windows 10 and later, so the frame
+// reported by DWM is preferred.
+func windowBounds(hwnd uintptr) image.Rectangle {
+	var r rect
+	ret, _, _ := dwmGetWindowAttribute.Call(hwnd, DWMWA_EXTENDED_FRAME_BOUNDS, uintptr(unsafe.Pointer(&r)), unsafe.Sizeof(r))
+	if ret != 0 {
+		if ok, _, _ := getWindowRect.Call(hwnd, uintptr(unsafe.Pointer(&r))); ok == 0 {
+			return image.Rectangle{}
+		}
+	}
+	return image.Rect(int(r.Left), int(r.Top), int(r.Right), int(r.Bottom))
+}
+
+// processName returns the executable name of a process, or "" if it
+// cannot be opened.
+func processName(pid uint32) string {
+	if pid == 0 {
+		return ""
+	}
+	h, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(h)
+
+	buf := make([]uint16, syscall.MAX_PATH)
+	size := uint32(len(buf))
+	if ok, _, _ := queryFullProcessImageNameW.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); ok == 0 {
+		return ""
+	}
+	return filepath.Base(syscall.UTF16ToString(buf[:size]))
+}
+
+// isPasswordControl reports whether hwnd is a standard edit control with
+// the ES_PASSWORD style.
+func isPasswordControl(hwnd uintptr) bool {
+	if !isEditControl(hwnd) {
+		return false
+	}
+	index := int32(GWL_STYLE)
+	style, _, _ := getWindowLongW.Call(hwnd, uintptr(index))
+	return style&ES_PASSWORD != 0
+}
+
+// isEditControl reports whether hwnd is a standard Edit or RichEdit
+// control.
+func isEditControl(hwnd uintptr) bool {
+	buf := make([]uint16, 64)
+	n, _, _ := getClassNameW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	class := syscall.UTF16ToString(buf[:n])
+	return strings.EqualFold(class, "Edit") || strings.HasPrefix(strings.ToLower(class), "richedit")
+}
+
+func windowText(hwnd uintptr) string {
+	buf := make([]uint16, 512)
+	n, _, _ := getWindowTextW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return syscall.UTF16ToString(buf[:n])
+}
@@ -0,0 +1,234 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The RECORD extension answers a single EnableContext request with an
+// open-ended stream of replies. xgb pairs every reply with exactly one
+// cookie, so the data connection is driven by hand here while xgb keeps
+// handling the control connection.
+
+const (
+	recordEnableContext = 5
+
+	recordFromServer  = 0
+	recordStartOfData = 4
+	recordEndOfData   = 5
+
+	xauthFamilyLocal = 256
+	xauthFamilyWild  = 65535
+)
+
+// x11DataConn is a minimal X11 client connection used to receive RECORD data.
+type x11DataConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// recordReply is one reply of an enabled RECORD context.
+type recordReply struct {
+	Category byte
+	Data     []byte
+}
+
+// dialX11 connects to the X server named by display and completes the
+// connection setup handshake.
+func dialX11(display string) (*x11DataConn, error) {
+	if display == "" {
+		display = os.Getenv("DISPLAY")
+	}
+	network, address, host, number, err := parseDisplay(display)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %w", display, err)
+	}
+
+	authName, authData, err := readXauthority(host, number)
+	if err != nil {
+		// Servers started without access control (such as a bare Xvfb)
+		// accept connections that carry no authorization data.
+		authName, authData = "", nil
+	}
+
+	c := &x11DataConn{conn: conn, rd: bufio.NewReader(conn)}
+	if err := c.setup(authName, authData); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseDisplay splits a DISPLAY string such as ":0", "unix:1.0" or
+// "host:2" into a dialable address, the host name and the display number.
+func parseDisplay(display string) (network, address, host, number string, err error) {
+	colon := strings.LastIndex(display, ":")
+	if colon < 0 {
+		return "", "", "", "", fmt.Errorf("bad display string: %q", display)
+	}
+
+	number = display[colon+1:]
+	if dot := strings.LastIndex(number, "."); dot >= 0 {
+		number = number[:dot]
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return "", "", "", "", fmt.Errorf("bad display string: %q", display)
+	}
+
+	if strings.HasPrefix(display, "/") {
+		return "unix", display[:colon] + ":" + number, "", number, nil
+	}
+
+	host = display[:colon]
+	if slash := strings.LastIndex(host, "/"); slash >= 0 {
+		host = host[slash+1:]
+	}
+	if host == "" || host == "unix" {
+		return "unix", "/tmp/.X11-unix/X" + number, "", number, nil
+	}
+	return "tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)), host, number, nil
+}
+
+// readXauthority looks up the MIT-MAGIC-COOKIE-1 entry for a display in
+// the user's Xauthority file.
+func readXauthority(host, number string) (string, []byte, error) {
+	if host == "" || host == "localhost" {
+		name, err := os.Hostname()
+		if err != nil {
+			return "", nil, err
+		}
+		host = name
+	}
+
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil, err
+		}
+		path = home + "/.Xauthority"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var family uint16
+		if err := binary.Read(r, binary.BigEndian, &family); err != nil {
+			return "", nil, err
+		}
+		fields := make([][]byte, 4)
+		for i := range fields {
+			var n uint16
+			if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+				return "", nil, err
+			}
+			fields[i] = make([]byte, n)
+			if _, err := io.ReadFull(r, fields[i]); err != nil {
+				return "", nil, err
+			}
+		}
+		addr, disp, name, data := string(fields[0]), string(fields[1]), string(fields[2]), fields[3]
+
+		addrMatch := family == xauthFamilyWild || (family == xauthFamilyLocal && addr == host)
+		dispMatch := disp == "" || disp == number
+		if addrMatch && dispMatch && name == "MIT-MAGIC-COOKIE-1" {
+			return name, data, nil
+		}
+	}
+}
+
+// setup performs the connection setup exchange. The setup reply itself is
+// discarded; the data connection never needs anything from it.
+func (c *x11DataConn) setup(authName string, authData []byte) error {
+	buf := make([]byte, 12+pad4(len(authName))+pad4(len(authData)))
+	buf[0] = 'l'
+	binary.LittleEndian.PutUint16(buf[2:], 11)
+	binary.LittleEndian.PutUint16(buf[6:], uint16(len(authName)))
+	binary.LittleEndian.PutUint16(buf[8:], uint16(len(authData)))
+	copy(buf[12:], authName)
+	copy(buf[12+pad4(len(authName)):], authData)
+	if _, err := c.conn.Write(buf); err != nil {
+		return fmt.Errorf("failed to send connection setup: %w", err)
+	}
+
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(c.rd, head); err != nil {
+		return fmt.Errorf("failed to read connection setup: %w", err)
+	}
+	body := make([]byte, int(binary.LittleEndian.Uint16(head[6:]))*4)
+	if _, err := io.ReadFull(c.rd, body); err != nil {
+		return fmt.Errorf("failed to read connection setup: %w", err)
+	}
+
+	if head[0] != 1 {
+		reason := body
+		if int(head[1]) <= len(body) {
+			reason = body[:head[1]]
+		}
+		return fmt.Errorf("X server refused connection: %s", strings.TrimSpace(string(reason)))
+	}
+	return nil
+}
+
+// enableContext starts the delivery of recorded data for ctx on this
+// connection. opcode is the major opcode of the RECORD extension.
+func (c *x11DataConn) enableContext(opcode byte, ctx uint32) error {
+	buf := make([]byte, 8)
+	buf[0] = opcode
+	buf[1] = recordEnableContext
+	binary.LittleEndian.PutUint16(buf[2:], 2)
+	binary.LittleEndian.PutUint32(buf[4:], ctx)
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// readRecordReply blocks until the next reply of the enabled context arrives.
+func (c *x11DataConn) readRecordReply() (recordReply, error) {
+	head := make([]byte, 32)
+	if _, err := io.ReadFull(c.rd, head); err != nil {
+		return recordReply{}, err
+	}
+
+	switch head[0] {
+	case 0:
+		return recordReply{}, fmt.Errorf("X error %d on RECORD data connection", head[1])
+	case 1:
+	default:
+		return recordReply{}, errors.New("unexpected event on RECORD data connection")
+	}
+
+	data := make([]byte, int(binary.LittleEndian.Uint32(head[4:]))*4)
+	if _, err := io.ReadFull(c.rd, data); err != nil {
+		return recordReply{}, err
+	}
+	return recordReply{Category: head[1], Data: data}, nil
+}
+
+func (c *x11DataConn) Close() error {
+	return c.conn.Close()
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,500 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+func TestParseDisplay(t *testing.T) {
+	tests := []struct {
+		display                        string
+		network, address, host, number string
+		err                            bool
+	}{
+		{display: ":0", network: "unix", address: "/tmp/.X11-unix/X0", number: "0"},
+		{display: ":1.0", network: "unix", address: "/tmp/.X11-unix/X1", number: "1"},
+		{display: "unix:2", network: "unix", address: "/tmp/.X11-unix/X2", number: "2"},
+		{display: "localhost:10.0", network: "tcp", address: "localhost:6010", host: "localhost", number: "10"},
+		{display: "tcp/example.com:3", network: "tcp", address: "example.com:6003", host: "example.com", number: "3"},
+		{display: "/tmp/launch-x/org.xquartz:0", network: "unix", address: "/tmp/launch-x/org.xquartz:0", number: "0"},
+		{display: "", err: true},
+		{display: "0", err: true},
+		{display: ":x", err: true},
+		{display: ":-1", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.display, func(t *testing.T) {
+			network, address, host, number, err := parseDisplay(tt.display)
+			if tt.err {
+				if err == nil {
+					t.Errorf("parseDisplay(%q) = %q, %q, want an error", tt.display, network, address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDisplay(%q): %v", tt.display, err)
+			}
+			if network != tt.network || address != tt.address || host != tt.host || number != tt.number {
+				t.Errorf("parseDisplay(%q) = %q, %q, %q, %q, want %q, %q, %q, %q", tt.display,
+					network, address, host, number, tt.network, tt.address, tt.host, tt.number)
+			}
+		})
+	}
+}
+
+// xauthEntry is one entry of an Xauthority file.
+type xauthEntry struct {
+	family                 uint16
+	address, display, name string
+	data                   string
+}
+
+func writeXauthority(t *testing.T, entries ...xauthEntry) {
+	t.Helper()
+	var buf []byte
+	field := func(s string) {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+		buf = append(buf, s...)
+	}
+	for _, e := range entries {
+		buf = binary.BigEndian.AppendUint16(buf, e.family)
+		field(e.address)
+		field(e.display)
+		field(e.name)
+		field(e.data)
+	}
+	path := filepath.Join(t.TempDir(), "Xauthority")
+	if err := os.WriteFile(path, buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XAUTHORITY", path)
+}
+
+func TestReadXauthority(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no host name: %v", err)
+	}
+	const cookie = "MIT-MAGIC-COOKIE-1"
+
+	tests := []struct {
+		name         string
+		entries      []xauthEntry
+		host, number string
+		want         string
+	}{
+		{
+			name: "local entry for this host",
+			entries: []xauthEntry{
+				{xauthFamilyLocal, "otherhost", "0", cookie, "other"},
+				{xauthFamilyLocal, hostname, "1", cookie, "wrong display"},
+				{xauthFamilyLocal, hostname, "0", cookie, "secret"},
+			},
+			number: "0",
+			want:   "secret",
+		},
+		{
+			name:    "localhost is this host",
+			entries: []xauthEntry{{xauthFamilyLocal, hostname, "0", cookie, "secret"}},
+			host:    "localhost",
+			number:  "0",
+			want:    "secret",
+		},
+		{
+			name:    "wildcard family",
+			entries: []xauthEntry{{xauthFamilyWild, "", "2", cookie, "wild"}},
+			host:    "remote",
+			number:  "2",
+			want:    "wild",
+		},
+		{
+			name:    "empty display matches any",
+			entries: []xauthEntry{{xauthFamilyLocal, hostname, "", cookie, "any"}},
+			number:  "7",
+			want:    "any",
+		},
+		{
+			name: "other schemes are skipped",
+			entries: []xauthEntry{
+				{xauthFamilyLocal, hostname, "0", "XDM-AUTHORIZATION-1", "xdm"},
+				{xauthFamilyLocal, hostname, "0", cookie, "secret"},
+			},
+			number: "0",
+			want:   "secret",
+		},
+		{
+			name:    "no match",
+			entries: []xauthEntry{{xauthFamilyLocal, "otherhost", "0", cookie, "other"}},
+			number:  "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeXauthority(t, tt.entries...)
+			name, data, err := readXauthority(tt.host, tt.number)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("readXauthority = %q, %q, want an error", name, data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readXauthority: %v", err)
+			}
+			if name != cookie || string(data) != tt.want {
+				t.Errorf("readXauthority = %q, %q, want %q, %q", name, data, cookie, tt.want)
+			}
+		})
+	}
+}
+
+// coreEvent encodes a core protocol device event as RECORD delivers it.
+func coreEvent(code, detail byte, serverTime uint32, x, y int16) []byte {
+	buf := make([]byte, 32)
+	buf[0] = code
+	buf[1] = detail
+	binary.LittleEndian.PutUint32(buf[4:], serverTime)
+	binary.LittleEndian.PutUint16(buf[20:], uint16(x))
+	binary.LittleEndian.PutUint16(buf[22:], uint16(y))
+	return buf
+}
+
+func TestX11DecodeButtons(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want InputEvent
+		ok   bool
+	}{
+		{
+			name: "left press",
+			buf:  coreEvent(x11ButtonPress, 1, 1000, 10, 20),
+			want: InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(10, 20)},
+			ok:   true,
+		},
+		{
+			name: "right release",
+			buf:  coreEvent(x11ButtonRelease, 3, 1000, 10, 20),
+			want: InputEvent{Kind: ButtonRelease, Button: ButtonRight, Position: image.Pt(10, 20)},
+			ok:   true,
+		},
+		{
+			name: "middle press sent by a client",
+			buf:  coreEvent(x11ButtonPress|0x80, 2, 1000, 10, 20),
+			want: InputEvent{Kind: ButtonPress, Button: ButtonMiddle, Position: image.Pt(10, 20)},
+			ok:   true,
+		},
+		{
+			name: "negative position on a display left of the primary",
+			buf:  coreEvent(x11ButtonPress, 1, 1000, -1900, 5),
+			want: InputEvent{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(-1900, 5)},
+			ok:   true,
+		},
+		{
+			name: "wheel down",
+			buf:  coreEvent(x11ButtonPress, 5, 1000, 1, 2),
+			want: InputEvent{Kind: Scroll, Delta: image.Pt(0, 1), Position: image.Pt(1, 2)},
+			ok:   true,
+		},
+		{
+			name: "wheel left",
+			buf:  coreEvent(x11ButtonPress, 6, 1000, 1, 2),
+			want: InputEvent{Kind: Scroll, Delta: image.Pt(-1, 0), Position: image.Pt(1, 2)},
+			ok:   true,
+		},
+		{name: "wheel release", buf: coreEvent(x11ButtonRelease, 4, 1000, 1, 2)},
+		{name: "extra button", buf: coreEvent(x11ButtonPress, 9, 1000, 1, 2)},
+		{name: "motion", buf: coreEvent(6, 0, 1000, 1, 2)},
+		{name: "key without keymap", buf: coreEvent(x11KeyPress, 38, 1000, 1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dec x11Decoder
+			got, ok := dec.decode(tt.buf)
+			if ok != tt.ok {
+				t.Fatalf("decode = %+v, %v, want ok %v", got, ok, tt.ok)
+			}
+			got.Time = time.Time{}
+			if ok && got != tt.want {
+				t.Errorf("decode = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestX11DecodeKeys(t *testing.T) {
+	keymap := &x11Keymap{
+		minKeycode: 8,
+		perKeycode: 2,
+		keysyms: []xproto.Keysym{
+			0xffe1, 0, // 8: Shift_L
+			0x61, 0x41, // 9: a A
+			0xffe5, 0, // 10: Caps_Lock
+			0xff0d, 0, // 11: Return
+			0x31, 0x21, // 12: 1 !
+			0xffc2, 0, // 13: F5
+			0xffe3, 0, // 14: Control_L
+		},
+	}
+	dec := &x11Decoder{keymap: keymap}
+
+	// The decoder keeps the modifier state, so the keys are pressed in
+	// order.
+	tests := []struct {
+		keycode byte
+		press   bool
+		key     string
+		r       rune
+		mods    Modifier
+	}{
+		{9, true, "a", 'a', 0},
+		{8, true, "Shift", 0, ModShift},
+		{9, true, "a", 'A', ModShift},
+		{12, true, "1", '!', ModShift},
+		{8, false, "Shift", 0, 0},
+		{10, true, "CapsLock", 0, 0},
+		{10, false, "CapsLock", 0, 0},
+		{9, true, "a", 'A', 0},
+		{8, true, "Shift", 0, ModShift},
+		{9, true, "a", 'a', ModShift},
+		{8, false, "Shift", 0, 0},
+		{14, true, "Ctrl", 0, ModCtrl},
+		{11, true, "Enter", 0, ModCtrl},
+		{14, false, "Ctrl", 0, 0},
+		{13, true, "F5", 0, 0},
+	}
+	for i, tt := range tests {
+		code := byte(x11KeyRelease)
+		kind := KeyRelease
+		if tt.press {
+			code, kind = x11KeyPress, KeyPress
+		}
+		ev, ok := dec.decode(coreEvent(code, tt.keycode, 1000, 0, 0))
+		if !ok {
+			t.Fatalf("%d: keycode %d not decoded", i, tt.keycode)
+		}
+		if ev.Kind != kind || ev.Key != tt.key || ev.Rune != tt.r || ev.Modifiers != tt.mods {
+			t.Errorf("%d: keycode %d = %v %q %q %v, want %v %q %q %v", i, tt.keycode,
+				ev.Kind, ev.Key, ev.Rune, ev.Modifiers, kind, tt.key, tt.r, tt.mods)
+		}
+	}
+}
+
+// pipeConn returns a data connection whose server end is written by
+// server.
+func pipeConn(t *testing.T, server func(net.Conn)) *x11DataConn {
+	t.Helper()
+	client, srv := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		defer srv.Close()
+		server(srv)
+	}()
+	return &x11DataConn{conn: client, rd: bufio.NewReader(client)}
+}
+
+// packet builds a reply or event header followed by extra bytes, whose
+// length in 4-byte units is stored where replies and generic events keep
+// it.
+func packet(kind, detail byte, extra []byte) []byte {
+	buf := make([]byte, 32, 32+len(extra))
+	buf[0] = kind
+	buf[1] = detail
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(extra)/4))
+	return append(buf, extra...)
+}
+
+func TestReadRecordReply(t *testing.T) {
+	events := append(coreEvent(x11ButtonPress, 1, 5, 1, 2), coreEvent(x11ButtonRelease, 1, 6, 1, 2)...)
+	c := pipeConn(t, func(srv net.Conn) {
+		srv.Write(packet(1, recordStartOfData, nil))
+		srv.Write(packet(1, recordFromServer, events))
+		srv.Write(packet(1, recordEndOfData, nil))
+		srv.Write(packet(0, 8, nil))
+	})
+
+	want := []recordReply{
+		{Category: recordStartOfData, Data: []byte{}},
+		{Category: recordFromServer, Data: events},
+		{Category: recordEndOfData, Data: []byte{}},
+	}
+	for i, w := range want {
+		got, err := c.readRecordReply()
+		if err != nil {
+			t.Fatalf("reply %d: %v", i+1, err)
+		}
+		if got.Category != w.Category || string(got.Data) != string(w.Data) {
+			t.Errorf("reply %d = category %d with %d bytes, want category %d with %d bytes", i+1, got.Category, len(got.Data), w.Category, len(w.Data))
+		}
+	}
+	if _, err := c.readRecordReply(); err == nil {
+		t.Error("X error was not reported")
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	payload := make([]byte, 16)
+	binary.LittleEndian.PutUint32(payload, 0xdeadbeef)
+	c := pipeConn(t, func(srv net.Conn) {
+		srv.Write(packet(x11GenericEvent, 131, payload))
+		srv.Write(packet(1, 0, nil))
+		srv.Write(packet(x11ButtonPress, 1, nil))
+		srv.Write(packet(0, 2, nil))
+	})
+
+	tests := []struct {
+		kind byte
+		size int
+	}{
+		{x11GenericEvent, 32 + len(payload)},
+		{1, 32},
+		{x11ButtonPress, 32},
+	}
+	for i, tt := range tests {
+		got, err := c.readPacket()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i+1, err)
+		}
+		if got[0] != tt.kind || len(got) != tt.size {
+			t.Errorf("packet %d = kind %d, %d bytes, want kind %d, %d bytes", i+1, got[0], len(got), tt.kind, tt.size)
+		}
+	}
+	if _, err := c.readPacket(); err == nil {
+		t.Error("X error was not reported")
+	}
+}
+
+// xtestFakeInput injects a core input event through the XTEST extension,
+// as if it came from a real device. xgb has no binding for XTEST, so the
+// request is built by hand.
+func xtestFakeInput(t *testing.T, c *xgb.Conn, opcode, kind, detail byte, root xproto.Window, x, y int16) {
+	t.Helper()
+	const xtestFakeInputRequest = 2
+	buf := make([]byte, 36)
+	buf[0] = opcode
+	buf[1] = xtestFakeInputRequest
+	binary.LittleEndian.PutUint16(buf[2:], uint16(len(buf)/4))
+	buf[4] = kind
+	buf[5] = detail
+	binary.LittleEndian.PutUint32(buf[12:], uint32(root))
+	binary.LittleEndian.PutUint16(buf[24:], uint16(x))
+	binary.LittleEndian.PutUint16(buf[26:], uint16(y))
+
+	cookie := c.NewCookie(true, false)
+	c.NewRequest(buf, cookie)
+	if err := cookie.Check(); err != nil {
+		t.Fatalf("XTEST FakeInput: %v", err)
+	}
+}
+
+// TestX11Sources runs both X11 input sources against the server named by
+// $DISPLAY, such as Xvfb in CI, and drives them with XTEST.
+func TestX11Sources(t *testing.T) {
+	if os.Getenv("DISPLAY") == "" {
+		t.Skip("no X server; set DISPLAY, e.g. by running the tests under xvfb-run")
+	}
+	c, err := xgb.NewConn()
+	if err != nil {
+		t.Skipf("cannot connect to the X server: %v", err)
+	}
+	defer c.Close()
+	ext, err := xproto.QueryExtension(c, uint16(len("XTEST")), "XTEST").Reply()
+	if err != nil || !ext.Present {
+		t.Skip("the X server has no XTEST extension")
+	}
+	root := xproto.Setup(c).DefaultScreen(c).Root
+
+	sources := []struct {
+		name   string
+		source InputSource
+	}{
+		{"record", &recordSource{}},
+		{"xinput2", newXI2Source()},
+	}
+	for _, src := range sources {
+		t.Run(src.name, func(t *testing.T) {
+			events, err := src.source.Start(false)
+			if err != nil {
+				t.Skipf("Start: %v", err)
+			}
+
+			xtestFakeInput(t, c, ext.MajorOpcode, xproto.MotionNotify, 0, root, 40, 30)
+			xtestFakeInput(t, c, ext.MajorOpcode, xproto.ButtonPress, 1, root, 0, 0)
+			xtestFakeInput(t, c, ext.MajorOpcode, xproto.ButtonRelease, 1, root, 0, 0)
+			xtestFakeInput(t, c, ext.MajorOpcode, xproto.ButtonPress, 5, root, 0, 0)
+			xtestFakeInput(t, c, ext.MajorOpcode, xproto.ButtonRelease, 5, root, 0, 0)
+
+			want := []InputEvent{
+				{Kind: ButtonPress, Button: ButtonLeft, Position: image.Pt(40, 30)},
+				{Kind: ButtonRelease, Button: ButtonLeft, Position: image.Pt(40, 30)},
+				{Kind: Scroll, Delta: image.Pt(0, 1), Position: image.Pt(40, 30)},
+			}
+			for i, w := range want {
+				select {
+				case got := <-events:
+					got.Time = time.Time{}
+					if got != w {
+						t.Errorf("event %d = %+v, want %+v", i+1, got, w)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("event %d did not arrive", i+1)
+				}
+			}
+
+			if err := src.source.Stop(); err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			for range events {
+			}
+		})
+	}
+
+	t.Run("screen", func(t *testing.T) {
+		screen := newScreenCapturer()
+		displays := screen.Displays()
+		if len(displays) == 0 {
+			t.Fatal("no displays")
+		}
+		img, err := screen.Capture(displays[0])
+		if err != nil {
+			t.Fatalf("Capture: %v", err)
+		}
+		if img.Bounds().Size() != displays[0].Size() {
+			t.Errorf("captured %v, want the size of %v", img.Bounds(), displays[0])
+		}
+	})
+}
+
+func TestWindowInspectorCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		broken bool
+	}{
+		{"no error", nil, false},
+		{"window gone", xproto.WindowError{BadValue: 42}, false},
+		{"connection closed", io.EOF, true},
+	}
+	for _, tt := range tests {
+		w := &x11WindowInspector{}
+		if err := w.check(tt.err); err != tt.err {
+			t.Errorf("%s: check returned %v, want %v", tt.name, err, tt.err)
+		}
+		if w.broken != tt.broken {
+			t.Errorf("%s: broken = %v, want %v", tt.name, w.broken, tt.broken)
+		}
+	}
+}
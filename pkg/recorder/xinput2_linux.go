@@ -0,0 +1,241 @@
+//go:build !windows
+// +build !windows
+
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// XInput2 raw events are generic events (XGE), which carry a variable
+// length payload that xgb cannot read. Like the RECORD data connection,
+// the event connection is therefore driven by hand, while xgb handles the
+// requests that have ordinary replies.
+
+const (
+	xiQueryVersion = 47
+	xiSelectEvents = 46
+
+	xiAllMasterDevices = 1
+
+	xiRawKeyPress      = 13
+	xiRawKeyRelease    = 14
+	xiRawButtonPress   = 15
+	xiRawButtonRelease = 16
+
+	x11GenericEvent = 35
+)
+
+// xi2Source reports pointer button and key events as XInput2 raw events.
+// Raw events are delivered to the root window from every device,
+// regardless of grabs, as soon as the server processes the input.
+type xi2Source struct {
+	mu       sync.Mutex
+	stopChan chan struct{}
+	done     chan struct{}
+
+	ctrl   *xgb.Conn
+	data   *x11DataConn
+	keymap *x11Keymap
+}
+
+func newXI2Source() InputSource {
+	return &xi2Source{}
+}
+
+func (s *xi2Source) Start(keyboard bool) (<-chan InputEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan != nil {
+		return nil, fmt.Errorf("input source already started")
+	}
+
+	ctrl, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to X server: %w", err)
+	}
+
+	ext, err := xproto.QueryExtension(ctrl, uint16(len("XInputExtension")), "XInputExtension").Reply()
+	if err != nil || !ext.Present {
+		ctrl.Close()
+		return nil, fmt.Errorf("X server does not support the XInput extension")
+	}
+
+	var keymap *x11Keymap
+	mask := uint32(1<<xiRawButtonPress | 1<<xiRawButtonRelease)
+	if keyboard {
+		if keymap, err = loadKeymap(ctrl); err != nil {
+			ctrl.Close()
+			return nil, fmt.Errorf("failed to read keyboard mapping: %w", err)
+		}
+		mask |= 1<<xiRawKeyPress | 1<<xiRawKeyRelease
+	}
+
+	data, err := dialX11("")
+	if err != nil {
+		ctrl.Close()
+		return nil, fmt.Errorf("failed to open XInput2 event connection: %w", err)
+	}
+
+	root := xproto.Setup(ctrl).DefaultScreen(ctrl).Root
+	if err := data.selectRawEvents(ext.MajorOpcode, uint32(root), mask); err != nil {
+		data.Close()
+		ctrl.Close()
+		return nil, err
+	}
+
+	events := make(chan InputEvent, 64)
+	s.stopChan = make(chan struct{})
+	s.done = make(chan struct{})
+	s.ctrl, s.data, s.keymap = ctrl, data, keymap
+
+	dec := &x11Decoder{keymap: keymap}
+	go s.readEvents(events, ctrl, data, root, dec, s.stopChan, s.done)
+	return events, nil
+}
+
+func (s *xi2Source) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopChan == nil {
+		return fmt.Errorf("input source not started")
+	}
+
+	close(s.stopChan)
+	s.stopChan = nil
+
+	s.data.Close()
+	<-s.done
+	s.ctrl.Close()
+
+	s.ctrl, s.data, s.keymap = nil, nil, nil
+	return nil
+}
+
+func (s *xi2Source) readEvents(events chan<- InputEvent, ctrl *xgb.Conn, data *x11DataConn, root xproto.Window, dec *x11Decoder, stopChan, done chan struct{}) {
+	defer close(done)
+	defer close(events)
+
+	for {
+		packet, err := data.readPacket()
+		if err != nil {
+			select {
+			case <-stopChan:
+			default:
+				log.Printf("XInput2 event connection failed: %v", err)
+			}
+			return
+		}
+		if packet[0] != x11GenericEvent || len(packet) < 28 {
+			continue
+		}
+
+		evtype := binary.LittleEndian.Uint16(packet[8:])
+		serverTime := binary.LittleEndian.Uint32(packet[12:])
+		detail := binary.LittleEndian.Uint32(packet[16:])
+		if detail > 255 {
+			continue
+		}
+
+		// Raw events carry no pointer position; ask for it right away.
+		ev := InputEvent{Time: dec.clock.at(serverTime)}
+		if pointer, err := xproto.QueryPointer(ctrl, root).Reply(); err == nil {
+			ev.Position = image.Point{X: int(pointer.RootX), Y: int(pointer.RootY)}
+		}
+
+		var ok bool
+		switch evtype {
+		case xiRawButtonPress, xiRawButtonRelease:
+			ev, ok = dec.decodeButton(ev, evtype == xiRawButtonPress, byte(detail))
+		case xiRawKeyPress, xiRawKeyRelease:
+			ev, ok = dec.decodeKey(ev, evtype == xiRawKeyPress, byte(detail))
+		}
+		if !ok {
+			continue
+		}
+
+		select {
+		case events <- ev:
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// selectRawEvents negotiates XInput 2.1, the first version that delivers
+// raw events regardless of grabs, and selects the raw events in mask on
+// the root window.
+func (c *x11DataConn) selectRawEvents(opcode byte, root uint32, mask uint32) error {
+	req := make([]byte, 8)
+	req[0] = opcode
+	req[1] = xiQueryVersion
+	binary.LittleEndian.PutUint16(req[2:], 2)
+	binary.LittleEndian.PutUint16(req[4:], 2)
+	binary.LittleEndian.PutUint16(req[6:], 1)
+	if _, err := c.conn.Write(req); err != nil {
+		return fmt.Errorf("failed to query XInput version: %w", err)
+	}
+
+	reply, err := c.readPacket()
+	if err != nil {
+		return fmt.Errorf("failed to query XInput version: %w", err)
+	}
+	if reply[0] != 1 {
+		return fmt.Errorf("X server does not support XInput 2")
+	}
+	major := binary.LittleEndian.Uint16(reply[8:])
+	minor := binary.LittleEndian.Uint16(reply[10:])
+	if major < 2 || (major == 2 && minor < 1) {
+		return fmt.Errorf("X server supports XInput %d.%d, 2.1 is required", major, minor)
+	}
+
+	req = make([]byte, 20)
+	req[0] = opcode
+	req[1] = xiSelectEvents
+	binary.LittleEndian.PutUint16(req[2:], 5)
+	binary.LittleEndian.PutUint32(req[4:], root)
+	binary.LittleEndian.PutUint16(req[8:], 1) // one event mask
+	binary.LittleEndian.PutUint16(req[12:], xiAllMasterDevices)
+	binary.LittleEndian.PutUint16(req[14:], 1) // mask length in 4-byte units
+	binary.LittleEndian.PutUint32(req[16:], mask)
+	if _, err := c.conn.Write(req); err != nil {
+		return fmt.Errorf("failed to select XInput events: %w", err)
+	}
+	return nil
+}
+
+// readPacket reads the next reply, error or event, including the extra
+// payload of replies and generic events.
+func (c *x11DataConn) readPacket() ([]byte, error) {
+	head := make([]byte, 32)
+	if _, err := io.ReadFull(c.rd, head); err != nil {
+		return nil, err
+	}
+
+	switch head[0] {
+	case 0:
+		return nil, fmt.Errorf("X error %d (request %d.%d)", head[1], head[10], binary.LittleEndian.Uint16(head[8:]))
+	case 1, x11GenericEvent:
+		extra := int(binary.LittleEndian.Uint32(head[4:])) * 4
+		if extra == 0 {
+			return head, nil
+		}
+		packet := make([]byte, 32+extra)
+		copy(packet, head)
+		if _, err := io.ReadFull(c.rd, packet[32:]); err != nil {
+			return nil, err
+		}
+		return packet, nil
+	}
+	return head, nil
+}
@@ -0,0 +1,109 @@
+// Package redact hides parts of a screenshot before it leaves the
+// recording. The result is written over a copy of the pixels, so exported
+// images never contain what was redacted.
+package redact
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Method is how a redacted rectangle is hidden.
+type Method string
+
+const (
+	// Pixelate replaces the rectangle with coarse blocks of its average
+	// colour. Blocks are large enough that text cannot be recovered.
+	Pixelate Method = "pixelate"
+	// Fill paints the rectangle a solid colour.
+	Fill Method = "fill"
+)
+
+// pixelBlock is the smallest pixelation block, in pixels. Large
+// rectangles get larger blocks, so no rectangle is split into more than
+// eight blocks along its shorter side.
+const pixelBlock = 20
+
+// fillColor is used by Fill.
+var fillColor = color.RGBA{R: 40, G: 40, B: 40, A: 255}
+
+// Redaction is a rectangle to hide, in screenshot pixels.
+type Redaction struct {
+	Rect   image.Rectangle `json:"rect"`
+	Method Method          `json:"method"`
+	// Rule is set when a redaction rule from the settings added the
+	// redaction, and names the rule.
+	Rule string `json:"rule,omitempty"`
+}
+
+// ParseMethod checks a method name from the settings. An empty name
+// selects Pixelate.
+func ParseMethod(s string) (Method, error) {
+	switch Method(s) {
+	case "", Pixelate:
+		return Pixelate, nil
+	case Fill:
+		return Fill, nil
+	}
+	return "", fmt.Errorf("unknown redaction method %q", s)
+}
+
+// String describes the redaction for lists in the editor.
+func (r Redaction) String() string {
+	s := fmt.Sprintf("Redaction %dx%d at (%d, %d)", r.Rect.Dx(), r.Rect.Dy(), r.Rect.Min.X, r.Rect.Min.Y)
+	if r.Rule != "" {
+		s += " from rule " + r.Rule
+	}
+	return s
+}
+
+// Apply returns a copy of img with every redaction applied. img itself is
+// left untouched.
+func Apply(img image.Image, redactions []Redaction) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	for _, r := range redactions {
+		rect := r.Rect.Canon().Intersect(dst.Bounds())
+		if rect.Empty() {
+			continue
+		}
+		switch r.Method {
+		case Fill:
+			draw.Draw(dst, rect, image.NewUniform(fillColor), image.Point{}, draw.Src)
+		default:
+			pixelate(dst, rect)
+		}
+	}
+	return dst
+}
+
+// pixelate replaces each block of rect with its average colour.
+func pixelate(img *image.RGBA, rect image.Rectangle) {
+	block := max(pixelBlock, min(rect.Dx(), rect.Dy())/8)
+	for y := rect.Min.Y; y < rect.Max.Y; y += block {
+		for x := rect.Min.X; x < rect.Max.X; x += block {
+			b := image.Rect(x, y, x+block, y+block).Intersect(rect)
+			draw.Draw(img, b, image.NewUniform(average(img, b)), image.Point{}, draw.Src)
+		}
+	}
+}
+
+func average(img *image.RGBA, r image.Rectangle) color.RGBA {
+	var sr, sg, sb, n uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sr += uint64(img.Pix[i])
+			sg += uint64(img.Pix[i+1])
+			sb += uint64(img.Pix[i+2])
+			i += 4
+			n++
+		}
+	}
+	if n == 0 {
+		return fillColor
+	}
+	return color.RGBA{R: uint8(sr / n), G: uint8(sg / n), B: uint8(sb / n), A: 255}
+}
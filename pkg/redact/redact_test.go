@@ -0,0 +1,107 @@
+package redact
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// palette is what the test screenshot is made of. Any block of it averages
+// to a grey that is none of these colours.
+var palette = []color.RGBA{
+	{R: 250, G: 10, B: 10, A: 255},
+	{R: 10, G: 250, B: 10, A: 255},
+	{R: 10, G: 10, B: 250, A: 255},
+}
+
+func screenshot() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.SetRGBA(x, y, palette[(x+2*y)%len(palette)])
+		}
+	}
+	return img
+}
+
+func original(c color.RGBA) bool {
+	for _, p := range palette {
+		if c == p {
+			return true
+		}
+	}
+	return false
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		r      Redaction
+		hidden image.Rectangle
+	}{
+		{"pixelate", Redaction{Rect: image.Rect(10, 20, 90, 70), Method: Pixelate}, image.Rect(10, 20, 90, 70)},
+		{"default is pixelate", Redaction{Rect: image.Rect(10, 20, 90, 70)}, image.Rect(10, 20, 90, 70)},
+		{"pixelate a sliver", Redaction{Rect: image.Rect(5, 5, 8, 100), Method: Pixelate}, image.Rect(5, 5, 8, 100)},
+		{"fill", Redaction{Rect: image.Rect(10, 20, 90, 70), Method: Fill}, image.Rect(10, 20, 90, 70)},
+		{"corners swapped", Redaction{Rect: image.Rectangle{Min: image.Pt(90, 70), Max: image.Pt(10, 20)}, Method: Fill}, image.Rect(10, 20, 90, 70)},
+		{"clipped to the image", Redaction{Rect: image.Rect(150, 100, 400, 400), Method: Pixelate}, image.Rect(150, 100, 200, 150)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := screenshot()
+			out := Apply(src, []Redaction{tt.r}).(*image.RGBA)
+
+			for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+				for x := src.Rect.Min.X; x < src.Rect.Max.X; x++ {
+					got := out.RGBAAt(x, y)
+					switch {
+					case image.Pt(x, y).In(tt.hidden):
+						if original(got) {
+							t.Fatalf("pixel (%d, %d) keeps its original colour %v", x, y, got)
+						}
+						if tt.r.Method == Fill && got != fillColor {
+							t.Fatalf("pixel (%d, %d) is %v, want the fill colour", x, y, got)
+						}
+					case got != src.RGBAAt(x, y):
+						t.Fatalf("pixel (%d, %d) outside the redaction changed to %v", x, y, got)
+					}
+				}
+			}
+			if !bytes.Equal(src.Pix, screenshot().Pix) {
+				t.Error("Apply changed the screenshot it was given")
+			}
+		})
+	}
+}
+
+func TestApplyOutside(t *testing.T) {
+	src := screenshot()
+	out := Apply(src, []Redaction{{Rect: image.Rect(300, 300, 400, 400)}, {Rect: image.Rect(10, 10, 10, 50)}}).(*image.RGBA)
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		for x := src.Rect.Min.X; x < src.Rect.Max.X; x++ {
+			if out.RGBAAt(x, y) != src.RGBAAt(x, y) {
+				t.Fatalf("pixel (%d, %d) changed by a redaction that covers nothing", x, y)
+			}
+		}
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Method
+		ok   bool
+	}{
+		{"", Pixelate, true},
+		{"pixelate", Pixelate, true},
+		{"fill", Fill, true},
+		{"blur", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMethod(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseMethod(%q) = %q, %v, want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Session is the working directory of one recording. Screenshots are
+// written to it as compressed PNG files as soon as they are captured, so a
+// long recording does not hold every frame in memory. Each file is synced
+// to disk before Put returns, so it survives a crash once it is stored.
+type Session struct {
+	dir string
+
+	mu   sync.Mutex
+	next int
+}
+
+// Image is a screenshot kept on disk by a Session. Nothing is held in
+// memory; the file is decoded each time Load is called.
+type Image struct {
+	path   string
+	bounds image.Rectangle
+}
+
+var encoder = png.Encoder{CompressionLevel: png.BestSpeed}
+
+// NewSession creates a new session directory inside root.
+func NewSession(root string) (*Session, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create session root: %w", err)
+	}
+	dir, err := os.MkdirTemp(root, "session_"+time.Now().Format("2006-01-02_150405")+"_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &Session{dir: dir}, nil
+}
+
+// OpenSession reopens an existing session directory, such as one left
+// behind by a recording that did not finish. New images are numbered
+// after the ones already in it.
+func OpenSession(dir string) (*Session, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session directory: %w", err)
+	}
+	s := &Session{dir: dir}
+	for _, e := range entries {
+		var n int
+		if _, err := fmt.Sscanf(e.Name(), "shot_%04d.png", &n); err == nil {
+			s.next = max(s.next, n)
+		}
+	}
+	return s, nil
+}
+
+// Dir returns the session directory.
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// Put encodes img to a new file in the session.
+func (s *Session) Put(img image.Image) (*Image, error) {
+	s.mu.Lock()
+	s.next++
+	name := fmt.Sprintf("shot_%04d.png", s.next)
+	s.mu.Unlock()
+
+	path := filepath.Join(s.dir, name)
+	if err := writePNG(path, img); err != nil {
+		return nil, err
+	}
+	return &Image{path: path, bounds: img.Bounds()}, nil
+}
+
+// PutPNG copies an already encoded PNG image to a new file in the
+// session, without decoding and re-encoding it.
+func (s *Session) PutPNG(r io.Reader) (*Image, error) {
+	s.mu.Lock()
+	s.next++
+	name := fmt.Sprintf("shot_%04d.png", s.next)
+	s.mu.Unlock()
+
+	path := filepath.Join(s.dir, name)
+	if err := copyPNG(path, r); err != nil {
+		return nil, err
+	}
+	bounds, err := pngBounds(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return &Image{path: path, bounds: bounds}, nil
+}
+
+// Image returns the image stored in the session as name, the base name of
+// its Path.
+func (s *Session) Image(name string) (*Image, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid screenshot name %q", name)
+	}
+	path := filepath.Join(s.dir, name)
+	bounds, err := pngBounds(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{path: path, bounds: bounds}, nil
+}
+
+// Remove deletes the session directory and every image in it.
+func (s *Session) Remove() error {
+	return os.RemoveAll(s.dir)
+}
+
+// Path returns the PNG file holding the image.
+func (i *Image) Path() string {
+	return i.path
+}
+
+// Bounds returns the bounds of the image without decoding it.
+func (i *Image) Bounds() image.Rectangle {
+	return i.bounds
+}
+
+// Open returns the PNG encoded image, for copying it without decoding.
+func (i *Image) Open() (io.ReadCloser, error) {
+	f, err := os.Open(i.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open screenshot: %w", err)
+	}
+	return f, nil
+}
+
+// Load decodes the image from disk.
+func (i *Image) Load() (image.Image, error) {
+	f, err := os.Open(i.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open screenshot: %w", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot %s: %w", filepath.Base(i.path), err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create screenshot file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	if err := encoder.Encode(w, img); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	return syncClose(f)
+}
+
+func copyPNG(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create screenshot file: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	return syncClose(f)
+}
+
+// pngBounds reads the size of a PNG file from its header.
+func pngBounds(path string) (image.Rectangle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("failed to open screenshot: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := png.DecodeConfig(bufio.NewReader(f))
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("failed to decode screenshot %s: %w", filepath.Base(path), err)
+	}
+	return image.Rect(0, 0, cfg.Width, cfg.Height), nil
+}
+
+func syncClose(f *os.File) error {
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	return f.Close()
+}